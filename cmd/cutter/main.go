@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -23,11 +24,16 @@ func main() {
 	}
 
 	rootCmd.AddCommand(commands.NewDBCmd())
+	rootCmd.AddCommand(commands.NewConfigCmd())
 
 	rootCmd.SetVersionTemplate(`{{printf "cutter version %s\n" .Version}}`)
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		// 130 is the shell's status for a command stopped by Ctrl-C
+		if errors.Is(err, commands.ErrCanceled) {
+			os.Exit(130)
+		}
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,252 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+	"github.com/pierrec/lz4/v4"
+	"github.com/spf13/cobra"
+	"github.com/ulikunitz/xz"
+)
+
+// compressionOptions selects how cutter compresses a dump stream
+type compressionOptions struct {
+	// codec is a compressionCodecs key or none
+	codec string
+	// level is codec specific; 0 uses the codec's default
+	level int
+	// threads caps the compression workers; 0 uses every CPU
+	threads int
+}
+
+// compressionCodec is one --compression choice
+type compressionCodec struct {
+	ext   string
+	magic []byte
+	// maxLevel bounds --compression-level, which starts at 1
+	maxLevel int
+	// multithreaded codecs honour --compression-threads
+	multithreaded bool
+	newWriter     func(w io.Writer, level, threads int) (io.WriteCloser, error)
+	newReader     func(r io.Reader) (io.ReadCloser, error)
+}
+
+// xzDictCaps are the dictionary sizes of the xz -1 ... -9 presets
+var xzDictCaps = []int{1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20}
+
+// compressionCodecs are the stream compressors, all implemented in Go
+var compressionCodecs = map[string]compressionCodec{
+	"gzip": {
+		ext:           ".gz",
+		magic:         []byte{0x1f, 0x8b},
+		maxLevel:      9,
+		multithreaded: true,
+		newWriter: func(w io.Writer, level, threads int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = gzip.DefaultCompression
+			}
+			gz, err := pgzip.NewWriterLevel(w, level)
+			if err != nil {
+				return nil, err
+			}
+			return gz, gz.SetConcurrency(1<<20, threads)
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	"zstd": {
+		ext:           ".zst",
+		magic:         []byte{0x28, 0xb5, 0x2f, 0xfd},
+		maxLevel:      22,
+		multithreaded: true,
+		newWriter: func(w io.Writer, level, threads int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = 3
+			}
+			return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)), zstd.WithEncoderConcurrency(threads))
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			dec, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return dec.IOReadCloser(), nil
+		},
+	},
+	"lz4": {
+		ext:           ".lz4",
+		magic:         []byte{0x04, 0x22, 0x4d, 0x18},
+		maxLevel:      9,
+		multithreaded: true,
+		newWriter: func(w io.Writer, level, threads int) (io.WriteCloser, error) {
+			lw := lz4.NewWriter(w)
+			compressionLevel := lz4.Fast
+			if level > 0 {
+				compressionLevel = lz4.CompressionLevel(1 << (8 + level))
+			}
+			return lw, lw.Apply(lz4.CompressionLevelOption(compressionLevel), lz4.ConcurrencyOption(threads))
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			return io.NopCloser(lz4.NewReader(r)), nil
+		},
+	},
+	"xz": {
+		ext:      ".xz",
+		magic:    []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+		maxLevel: 9,
+		newWriter: func(w io.Writer, level, threads int) (io.WriteCloser, error) {
+			if level == 0 {
+				level = 6
+			}
+			return xz.WriterConfig{DictCap: xzDictCaps[level-1]}.NewWriter(w)
+		},
+		newReader: func(r io.Reader) (io.ReadCloser, error) {
+			xr, err := xz.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(xr), nil
+		},
+	},
+}
+
+// compressionNames returns the --compression values in sorted order
+func compressionNames() []string {
+	names := []string{"none"}
+	for name := range compressionCodecs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addCompressionFlags registers --compression, --compression-level and
+// --compression-threads
+func addCompressionFlags(cmd *cobra.Command, opts *compressionOptions) {
+	cmd.Flags().StringVar(&opts.codec, "compression", "gzip", fmt.Sprintf("Compression for plain and tar dumps (%s)", strings.Join(compressionNames(), ", ")))
+	cmd.Flags().IntVar(&opts.level, "compression-level", 0, "Compression level: gzip/lz4/xz 1-9, zstd 1-22 (default: the codec's default)")
+	cmd.Flags().IntVar(&opts.threads, "compression-threads", 0, "Compression threads for gzip, zstd and lz4 (default: all CPUs)")
+}
+
+// applyCompressFlag folds the deprecated --compress=false into --compression none
+func applyCompressFlag(cmd *cobra.Command, compress bool, opts *compressionOptions) error {
+	if !cmd.Flags().Changed("compress") || compress {
+		return nil
+	}
+	if cmd.Flags().Changed("compression") && opts.codec != "none" {
+		return fmt.Errorf("--compress=false conflicts with --compression %s", opts.codec)
+	}
+	opts.codec = "none"
+	return nil
+}
+
+// enabled reports whether the stream is compressed at all
+func (o compressionOptions) enabled() bool {
+	return o.codec != "" && o.codec != "none"
+}
+
+// ext returns the file extension of the codec
+func (o compressionOptions) ext() string {
+	return compressionCodecs[o.codec].ext
+}
+
+// validate checks the codec, level and thread count
+func (o compressionOptions) validate() error {
+	if !o.enabled() {
+		return nil
+	}
+	codec, ok := compressionCodecs[o.codec]
+	if !ok {
+		return fmt.Errorf("unsupported compression %q (use one of %s)", o.codec, strings.Join(compressionNames(), ", "))
+	}
+	if o.level < 0 || o.level > codec.maxLevel {
+		return fmt.Errorf("invalid %s compression level %d (use 1-%d)", o.codec, o.level, codec.maxLevel)
+	}
+	if o.threads < 0 {
+		return fmt.Errorf("--compression-threads must not be negative")
+	}
+	if o.threads > 1 && !codec.multithreaded {
+		return fmt.Errorf("%s compression is single-threaded; drop --compression-threads", o.codec)
+	}
+	return nil
+}
+
+// newCompressWriter wraps w in the configured compressor
+func newCompressWriter(w io.Writer, o compressionOptions) (io.WriteCloser, error) {
+	threads := o.threads
+	if threads == 0 {
+		threads = runtime.GOMAXPROCS(0)
+	}
+	cw, err := compressionCodecs[o.codec].newWriter(w, o.level, threads)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start %s compression: %v", o.codec, err)
+	}
+	return cw, nil
+}
+
+// detectCompression names the codec whose magic bytes start head, or
+// returns none
+func detectCompression(head []byte) string {
+	for name, codec := range compressionCodecs {
+		if bytes.HasPrefix(head, codec.magic) {
+			return name
+		}
+	}
+	return "none"
+}
+
+// storedCompression names the compression of a backup in store. The magic
+// bytes decide for plain files; encrypted files can only be judged by
+// their name. It returns none when nothing was recognised.
+func storedCompression(store backupStore, name string) string {
+	if plain, ok := strings.CutSuffix(name, ".age"); ok {
+		for codecName, codec := range compressionCodecs {
+			if strings.HasSuffix(plain, codec.ext) {
+				return codecName
+			}
+		}
+		return "none"
+	}
+
+	file, err := store.Open(name)
+	if err != nil {
+		return "none"
+	}
+	defer file.Close()
+
+	head := make([]byte, 6)
+	n, _ := io.ReadFull(file, head)
+	return detectCompression(head[:n])
+}
+
+// openDumpReader wraps r in a decompressor chosen by the stream's magic
+// bytes, so a dump can be restored whatever it was compressed with. It
+// returns the codec name, or none for an uncompressed stream.
+func openDumpReader(r io.Reader) (io.ReadCloser, string, error) {
+	buffered := bufio.NewReader(r)
+
+	head, err := buffered.Peek(6)
+	if err != nil && err != io.EOF {
+		return nil, "", fmt.Errorf("failed to read backup file: %v", err)
+	}
+
+	name := detectCompression(head)
+	if name == "none" {
+		return io.NopCloser(buffered), name, nil
+	}
+
+	reader, err := compressionCodecs[name].newReader(buffered)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open %s stream: %v", name, err)
+	}
+	return reader, name, nil
+}
@@ -0,0 +1,208 @@
+package commands
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompressionRoundTrip(t *testing.T) {
+	plain := []byte(strings.Repeat("INSERT INTO users VALUES (1, 'alice');\n", 1000))
+
+	for _, name := range compressionNames() {
+		for _, opts := range []compressionOptions{{codec: name}, {codec: name, level: 1, threads: 1}} {
+			t.Run(name, func(t *testing.T) {
+				var buf bytes.Buffer
+				var w io.WriteCloser = nopWriteCloser{&buf}
+				if opts.enabled() {
+					var err error
+					w, err = newCompressWriter(&buf, opts)
+					if err != nil {
+						t.Fatalf("Expected no error, got %v", err)
+					}
+				}
+				w.Write(plain)
+				if err := w.Close(); err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+
+				reader, detected, err := openDumpReader(&buf)
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				defer reader.Close()
+				if detected != name {
+					t.Errorf("Expected %s to be detected, got %s", name, detected)
+				}
+
+				got, err := io.ReadAll(reader)
+				if err != nil || !bytes.Equal(got, plain) {
+					t.Errorf("Expected the original dump back, got %d bytes (%v)", len(got), err)
+				}
+			})
+		}
+	}
+}
+
+// nopWriteCloser stands in for a compressor when compression is off
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func TestOpenDumpReader(t *testing.T) {
+	plain := []byte("CREATE TABLE users (id int);\n")
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	gz.Write(plain)
+	gz.Close()
+
+	tests := []struct {
+		name            string
+		input           []byte
+		wantCompression string
+	}{
+		{"Plain SQL", plain, "none"},
+		{"Gzip SQL", compressed.Bytes(), "gzip"},
+		{"Empty file", []byte{}, "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, compression, err := openDumpReader(bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			defer reader.Close()
+
+			if compression != tt.wantCompression {
+				t.Errorf("Expected compression=%s, got %s", tt.wantCompression, compression)
+			}
+
+			got, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("Failed to read dump: %v", err)
+			}
+
+			if len(tt.input) > 0 && !bytes.Equal(got, plain) {
+				t.Errorf("Expected %q, got %q", plain, got)
+			}
+		})
+	}
+}
+
+func TestOpenDumpReaderCorruptGzip(t *testing.T) {
+	_, _, err := openDumpReader(bytes.NewReader([]byte{0x1f, 0x8b, 0x00}))
+
+	if err == nil {
+		t.Error("Expected error for truncated gzip header")
+	}
+}
+
+func TestCompressionValidate(t *testing.T) {
+	tests := []struct {
+		opts    compressionOptions
+		wantErr string
+	}{
+		{compressionOptions{codec: "none"}, ""},
+		{compressionOptions{codec: "zstd", level: 19, threads: 8}, ""},
+		{compressionOptions{codec: "xz", level: 9}, ""},
+		{compressionOptions{codec: "bzip2"}, `unsupported compression "bzip2"`},
+		{compressionOptions{codec: "gzip", level: 10}, "invalid gzip compression level 10 (use 1-9)"},
+		{compressionOptions{codec: "zstd", level: -1}, "invalid zstd compression level"},
+		{compressionOptions{codec: "xz", threads: 4}, "xz compression is single-threaded"},
+		{compressionOptions{codec: "lz4", threads: -1}, "must not be negative"},
+	}
+
+	for _, tt := range tests {
+		err := tt.opts.validate()
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("Expected no error for %+v, got %v", tt.opts, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("Expected error %q for %+v, got %v", tt.wantErr, tt.opts, err)
+		}
+	}
+}
+
+func TestApplyCompressFlag(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{nil, "gzip", false},
+		{[]string{"--compression", "zstd"}, "zstd", false},
+		{[]string{"--compress=false"}, "none", false},
+		{[]string{"--compress=false", "--compression", "none"}, "none", false},
+		{[]string{"--compress=false", "--compression", "xz"}, "", true},
+	}
+
+	for _, tt := range tests {
+		cmd := newDBBackupCmd()
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatalf("Failed to parse flags: %v", err)
+		}
+		compress, _ := cmd.Flags().GetBool("compress")
+		codec, _ := cmd.Flags().GetString("compression")
+		opts := compressionOptions{codec: codec}
+
+		err := applyCompressFlag(cmd, compress, &opts)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Expected conflict error for %v", tt.args)
+			}
+			continue
+		}
+		if err != nil || opts.codec != tt.want {
+			t.Errorf("Expected %s for %v, got %s (%v)", tt.want, tt.args, opts.codec, err)
+		}
+	}
+}
+
+func TestStreamDumpCompressions(t *testing.T) {
+	fakeDocker(t)
+
+	for _, name := range []string{"zstd", "lz4", "xz"} {
+		t.Run(name, func(t *testing.T) {
+			store := localStore{dir: t.TempDir()}
+			stats, err := streamDump("pg_dump", commandDump(exec.Command(dockerBinary, "SELECT 1;")), store, "orders.sql"+compressionCodecs[name].ext, compressionOptions{codec: name}, nil, nil)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if stats.RawBytes != int64(len("SELECT 1;\n")) {
+				t.Errorf("Expected %d raw bytes, got %d", len("SELECT 1;\n"), stats.RawBytes)
+			}
+
+			if got := storedCompression(store, "orders.sql"+compressionCodecs[name].ext); got != name {
+				t.Errorf("Expected %s from the magic bytes, got %s", name, got)
+			}
+		})
+	}
+}
+
+func TestStoredCompression(t *testing.T) {
+	store := localStore{dir: t.TempDir()}
+	os.WriteFile(filepath.Join(store.dir, "misnamed.sql"), []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}, 0600)
+	os.WriteFile(filepath.Join(store.dir, "plain.sql.gz"), []byte("SELECT 1;"), 0600)
+	os.WriteFile(filepath.Join(store.dir, "secret.sql.xz.age"), []byte(ageHeader), 0600)
+
+	tests := map[string]string{
+		"misnamed.sql":      "zstd",
+		"plain.sql.gz":      "none",
+		"secret.sql.xz.age": "xz",
+		"missing.sql":       "none",
+	}
+	for name, want := range tests {
+		if got := storedCompression(store, name); got != want {
+			t.Errorf("Expected %s for %s, got %s", want, name, got)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/PandhuWibowo/go-devops-cutter/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func NewConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the cutter config file",
+	}
+
+	cmd.AddCommand(newConfigProfileCmd())
+
+	return cmd
+}
+
+func newConfigProfileCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage named connection profiles",
+	}
+
+	cmd.AddCommand(newConfigProfileAddCmd())
+	cmd.AddCommand(newConfigProfileListCmd())
+	cmd.AddCommand(newConfigProfileShowCmd())
+	cmd.AddCommand(newConfigProfileRemoveCmd())
+
+	return cmd
+}
+
+// loadConfig reads the config file from its default location
+func loadConfig() (*config.Config, string, error) {
+	path, err := config.DefaultPath()
+	if err != nil {
+		return nil, "", err
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return cfg, path, nil
+}
+
+func newConfigProfileAddCmd() *cobra.Command {
+	var (
+		profile config.Profile
+		force   bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "add <name>",
+		Short: "Add or replace a connection profile",
+		Args:  cobra.ExactArgs(1),
+		Example: `  # Save a profile for a database behind two bastions
+  cutter config profile add prod-orders --type postgres --host 10.0.1.50 \
+    --username orders --database orders --ssh-jump corp-bastion,vpc-bastion
+
+  # Use it
+  cutter db backup --profile prod-orders`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+
+			if profile.Type != "" {
+				if _, err := lookupDriver(profile.Type); err != nil {
+					return err
+				}
+			}
+
+			cfg, path, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			if _, exists := cfg.Profiles[name]; exists && !force {
+				return fmt.Errorf("profile %q already exists (use --force to replace it)", name)
+			}
+
+			cfg.Profiles[name] = profile
+			if err := cfg.Save(path); err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ Profile %s saved to %s\n", name, path)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&profile.Type, "type", "", typeFlagUsage())
+	cmd.Flags().StringVar(&profile.Host, "host", "", "Database host")
+	cmd.Flags().IntVar(&profile.Port, "port", 0, "Database port")
+	cmd.Flags().StringVar(&profile.Username, "username", "", "Database username")
+	cmd.Flags().StringVar(&profile.PasswordFile, "password-file", "", "Read the database password from a file")
+	cmd.Flags().StringVar(&profile.PasswordEnv, "password-env", "", "Read the database password from the named environment variable")
+	cmd.Flags().StringVar(&profile.Database, "database", "", "Database name")
+	cmd.Flags().StringVar(&profile.AuthDatabase, "auth-database", "", "MongoDB database holding the user's credentials")
+	cmd.Flags().StringVar(&profile.ReplicaSet, "replica-set", "", "MongoDB replica set name")
+	cmd.Flags().StringVar(&profile.Path, "path", "", "Database file for SQLite")
+	cmd.Flags().StringVar(&profile.SSHJump, "ssh-jump", "", sshJumpUsage)
+	cmd.Flags().StringVar(&profile.ClientImage, "client-image", "", "Client image to run instead of the one matching the server version")
+	cmd.Flags().StringVar(&profile.Container, "container", "", containerUsage)
+	cmd.Flags().StringVar(&profile.DockerNetwork, "docker-network", "", "Network to attach client containers to, such as a docker-compose one")
+	cmd.Flags().StringVar(&profile.EncryptRecipient, "encrypt-recipient", "", "Encrypt backups to this age public key or recipients file")
+	cmd.Flags().StringVar(&profile.Identity, "identity", "", "age identity file used to decrypt backups on restore")
+	cmd.Flags().StringVar(&profile.Output, "output", "", "Default backup destination: a directory or s3://bucket/prefix/")
+	cmd.Flags().StringVar(&profile.Storage.Endpoint, "s3-endpoint", "", "S3-compatible endpoint for s3:// outputs (host[:port])")
+	cmd.Flags().StringVar(&profile.Storage.Region, "s3-region", "", "Region for s3:// outputs")
+	cmd.Flags().BoolVar(&profile.Storage.Insecure, "s3-insecure", false, "Use plain HTTP for the S3 endpoint")
+	cmd.Flags().BoolVar(&force, "force", false, "Replace an existing profile")
+	cmd.MarkFlagsMutuallyExclusive("password-file", "password-env")
+
+	return cmd
+}
+
+func newConfigProfileListCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List connection profiles",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, path, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			if len(cfg.Profiles) == 0 {
+				fmt.Printf("No profiles found in %s\n", path)
+				return nil
+			}
+
+			fmt.Println("Profiles:")
+			for _, name := range cfg.ProfileNames() {
+				fmt.Printf("  - %s (%s)\n", name, describeProfile(cfg.Profiles[name]))
+			}
+			return nil
+		},
+	}
+}
+
+func newConfigProfileShowCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "show <name>",
+		Short: "Show a connection profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, _, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			p, err := cfg.Profile(args[0])
+			if err != nil {
+				return err
+			}
+
+			port := ""
+			if p.Port != 0 {
+				port = strconv.Itoa(p.Port)
+			}
+
+			fmt.Printf("Profile: %s\n", args[0])
+			fmt.Printf("  Type:     %s\n", p.Type)
+			fmt.Printf("  Host:     %s\n", p.Host)
+			fmt.Printf("  Port:     %s\n", port)
+			fmt.Printf("  Username: %s\n", p.Username)
+			if p.PasswordFile != "" {
+				fmt.Printf("  Password: from file %s\n", p.PasswordFile)
+			} else if p.PasswordEnv != "" {
+				fmt.Printf("  Password: from $%s\n", p.PasswordEnv)
+			}
+			fmt.Printf("  Database: %s\n", p.Database)
+			if p.AuthDatabase != "" {
+				fmt.Printf("  Auth DB:  %s\n", p.AuthDatabase)
+			}
+			if p.ReplicaSet != "" {
+				fmt.Printf("  Replica:  %s\n", p.ReplicaSet)
+			}
+			if p.Path != "" {
+				fmt.Printf("  Path:     %s\n", p.Path)
+			}
+			fmt.Printf("  SSH jump: %s\n", p.SSHJump)
+			if p.ClientImage != "" {
+				fmt.Printf("  Image:    %s\n", p.ClientImage)
+			}
+			if p.Container != "" {
+				fmt.Printf("  Exec in:  %s\n", p.Container)
+			}
+			if p.DockerNetwork != "" {
+				fmt.Printf("  Network:  %s\n", p.DockerNetwork)
+			}
+			if p.EncryptRecipient != "" {
+				fmt.Printf("  Encrypt:  %s\n", p.EncryptRecipient)
+			}
+			if p.Identity != "" {
+				fmt.Printf("  Identity: %s\n", p.Identity)
+			}
+			if p.Output != "" {
+				fmt.Printf("  Output:   %s\n", p.Output)
+			}
+			if p.Storage.Endpoint != "" {
+				fmt.Printf("  Storage:  %s\n", p.Storage.Endpoint)
+			}
+			return nil
+		},
+	}
+}
+
+func newConfigProfileRemoveCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove <name>",
+		Short: "Remove a connection profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, path, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			if _, err := cfg.Profile(args[0]); err != nil {
+				return err
+			}
+
+			delete(cfg.Profiles, args[0])
+			if err := cfg.Save(path); err != nil {
+				return err
+			}
+
+			fmt.Printf("✓ Profile %s removed\n", args[0])
+			return nil
+		},
+	}
+}
+
+// describeProfile renders a profile as a one-line connection summary
+func describeProfile(p config.Profile) string {
+	summary := p.Type
+	if summary == "" {
+		summary = "any"
+	}
+
+	target := p.Host
+	if p.Port != 0 {
+		target = fmt.Sprintf("%s:%d", target, p.Port)
+	}
+	if p.Username != "" {
+		target = p.Username + "@" + target
+	}
+	if p.Database != "" {
+		target += "/" + p.Database
+	}
+	if target != "" {
+		summary += " " + target
+	}
+	if p.SSHJump != "" {
+		summary += " via " + p.SSHJump
+	}
+	return summary
+}
@@ -0,0 +1,105 @@
+package commands
+
+import (
+	"io"
+	"testing"
+
+	"github.com/PandhuWibowo/go-devops-cutter/internal/config"
+)
+
+// runConfigCmd executes the config command tree with args
+func runConfigCmd(args ...string) error {
+	cmd := NewConfigCmd()
+	cmd.SetArgs(args)
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	return cmd.Execute()
+}
+
+func TestNewConfigCmd(t *testing.T) {
+	cmd := NewConfigCmd()
+
+	if cmd.Use != "config" {
+		t.Errorf("Expected Use 'config', got '%s'", cmd.Use)
+	}
+
+	profileCmd, _, err := cmd.Find([]string{"profile"})
+	if err != nil || profileCmd.Name() != "profile" {
+		t.Fatalf("Expected 'profile' subcommand, got %v", err)
+	}
+
+	want := map[string]bool{"add": false, "list": false, "show": false, "remove": false}
+	for _, sub := range profileCmd.Commands() {
+		want[sub.Name()] = true
+	}
+	for name, found := range want {
+		if !found {
+			t.Errorf("Expected 'profile %s' subcommand to exist", name)
+		}
+	}
+}
+
+func TestConfigProfileLifecycle(t *testing.T) {
+	path := writeTestConfig(t, map[string]config.Profile{})
+
+	err := runConfigCmd("profile", "add", "prod-orders",
+		"--type", "postgres", "--host", "10.0.1.50", "--port", "5433",
+		"--username", "orders", "--database", "orders", "--ssh-jump", "bastion")
+	if err != nil {
+		t.Fatalf("Failed to add profile: %v", err)
+	}
+
+	cfg, _ := config.Load(path)
+	p, err := cfg.Profile("prod-orders")
+	if err != nil {
+		t.Fatalf("Expected saved profile, got %v", err)
+	}
+	if p.Host != "10.0.1.50" || p.Port != 5433 || p.SSHJump != "bastion" {
+		t.Errorf("Unexpected saved profile %+v", p)
+	}
+
+	if err := runConfigCmd("profile", "add", "prod-orders", "--host", "other"); err == nil {
+		t.Error("Expected error when adding an existing profile without --force")
+	}
+	if err := runConfigCmd("profile", "add", "prod-orders", "--host", "other", "--force"); err != nil {
+		t.Errorf("Expected --force to replace profile, got %v", err)
+	}
+
+	if err := runConfigCmd("profile", "list"); err != nil {
+		t.Errorf("Expected list to succeed, got %v", err)
+	}
+	if err := runConfigCmd("profile", "show", "prod-orders"); err != nil {
+		t.Errorf("Expected show to succeed, got %v", err)
+	}
+
+	if err := runConfigCmd("profile", "remove", "prod-orders"); err != nil {
+		t.Fatalf("Failed to remove profile: %v", err)
+	}
+	cfg, _ = config.Load(path)
+	if len(cfg.Profiles) != 0 {
+		t.Errorf("Expected no profiles after remove, got %v", cfg.ProfileNames())
+	}
+
+	if err := runConfigCmd("profile", "remove", "prod-orders"); err == nil {
+		t.Error("Expected error removing a missing profile")
+	}
+}
+
+func TestConfigProfileAddInvalidType(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{})
+
+	err := runConfigCmd("profile", "add", "bad", "--type", "oracle")
+	if err == nil || err.Error() != "unsupported database type: oracle" {
+		t.Errorf("Expected unsupported type error, got %v", err)
+	}
+}
+
+func TestDescribeProfile(t *testing.T) {
+	got := describeProfile(config.Profile{
+		Type: "postgres", Host: "db", Port: 5432, Username: "app", Database: "orders", SSHJump: "bastion",
+	})
+	want := "postgres app@db:5432/orders via bastion"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
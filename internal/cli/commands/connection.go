@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// connectionOptions are the connection settings shared by db backup and
+// db restore
+type connectionOptions struct {
+	dbType        string
+	host          string
+	port          int
+	username      string
+	password      string
+	passwords     passwordOptions
+	dsn           string
+	sslMode       string
+	mongo         mongoOptions
+	database      string
+	path          string
+	sshJump       string
+	execContainer string
+	profile       string
+	clientImage   string
+	runtime       string
+	container     containerSettings
+	// images is resolved from clientImage and the config file by prepare
+	images clientImages
+}
+
+// addConnectionFlags registers the connection flags on cmd, describing
+// --database with databaseUsage
+func addConnectionFlags(cmd *cobra.Command, o *connectionOptions, databaseUsage string) {
+	cmd.Flags().StringVar(&o.dbType, "type", "postgres", typeFlagUsage())
+	cmd.Flags().StringVar(&o.host, "host", "localhost", "Database host")
+	cmd.Flags().IntVar(&o.port, "port", 0, "Database port (default: the engine's standard port)")
+	cmd.Flags().StringVar(&o.username, "username", "", "Database username")
+	addPasswordFlags(cmd, &o.passwords)
+	addDSNFlag(cmd, &o.dsn)
+	addMongoFlags(cmd, &o.mongo)
+	cmd.Flags().StringVar(&o.database, "database", "", databaseUsage)
+	cmd.Flags().StringVar(&o.sshJump, "ssh-jump", "", sshJumpUsage)
+	cmd.Flags().StringVar(&o.execContainer, "container", "", containerUsage)
+	addClientImageFlag(cmd, &o.clientImage)
+	addRuntimeFlag(cmd, &o.runtime)
+	addContainerFlags(cmd, &o.container)
+	addProfileFlag(cmd, &o.profile)
+}
+
+// prepare completes the connection from a DSN, the environment, a profile
+// and the defaults, checks it and resolves the password. It returns the
+// driver of the database type.
+func (o *connectionOptions) prepare(cmd *cobra.Command) (BackupDriver, error) {
+	dsn, err := applyDSN(cmd, o.dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyConnectionDefaults(cmd, o.profile); err != nil {
+		return nil, err
+	}
+	driver, err := lookupDriver(o.dbType)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireFlags(cmd, requiredFlags(driver)...); err != nil {
+		return nil, err
+	}
+	if o.port == 0 {
+		o.port = driver.DefaultPort()
+	}
+	if err := o.mongo.check(driver); err != nil {
+		return nil, err
+	}
+	if err := checkPath(driver, o.path); err != nil {
+		return nil, err
+	}
+	if err := checkRuntime(o.runtime); err != nil {
+		return nil, err
+	}
+	if err := o.container.check(); err != nil {
+		return nil, err
+	}
+	if err := o.clientOptions(driver).check(); err != nil {
+		return nil, err
+	}
+	if o.images, err = loadClientImages(o.clientImage, driver); err != nil {
+		return nil, err
+	}
+	if dsn != nil {
+		o.sslMode = dsn.SSLMode
+	}
+
+	if dsn != nil && dsn.Password != "" {
+		o.password = dsn.Password
+	} else {
+		o.password, err = resolvePassword(o.passwords, driver, o.connParams())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return driver, nil
+}
+
+func (o connectionOptions) connParams() ConnParams {
+	return ConnParams{
+		Host:     o.host,
+		Port:     o.port,
+		Username: o.username,
+		Password: o.password,
+		Database: o.database,
+		Path:     o.path,
+		SSLMode:  o.sslMode,
+
+		AuthDatabase: o.mongo.authDatabase,
+		ReplicaSet:   o.mongo.replicaSet,
+	}
+}
+
+// clientOptions returns where d's client tools run. An explicit
+// --client-image or --docker-network asks for a container even when local
+// tools exist.
+func (o connectionOptions) clientOptions(d BackupDriver) clientOptions {
+	return clientOptions{sshJump: o.sshJump, runtime: o.runtime, image: o.images.initial(d),
+		forceContainer: o.clientImage != "" || o.container.network != "",
+		execContainer:  o.execContainer, container: o.container}
+}
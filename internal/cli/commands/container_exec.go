@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// containerUsage describes --container
+const containerUsage = "Run the client tools inside this running container, such as the database's own, instead of a new one"
+
+// containerExecClient runs database client tools inside an existing
+// container, usually the database's own, through the Docker Engine API or
+// the container CLI's exec. The password reaches them through the
+// environment, never the command line.
+type containerExecClient struct {
+	runtime   containerRuntime
+	container string
+	env       []string
+	// version is the dump tool's --version line
+	version string
+	// ctx, when set, ends running tools' sessions once it is canceled
+	ctx context.Context
+}
+
+// openContainerExec returns a client running d's tools in the running
+// container named container after checking that the dump tool is there
+func openContainerExec(ctx context.Context, d BackupDriver, p ConnParams, rt containerRuntime, container string) (containerExecClient, error) {
+	client := containerExecClient{runtime: rt, container: container, ctx: ctx}
+	if err := client.checkRunning(); err != nil {
+		return client, err
+	}
+
+	if p.Password != "" && d.PasswordEnv() != "" {
+		password := p.Password
+		if encoder, ok := d.(passwordEncoder); ok {
+			password = encoder.encodePassword(password)
+		}
+		client.env = []string{d.PasswordEnv() + "=" + password}
+	}
+
+	if runner, ok := d.(localRunner); ok {
+		tool := runner.localTools()[0]
+		out, err := client.query([]string{tool, "--version"})
+		if err != nil {
+			return client, fmt.Errorf("%s cannot run in container %s: %v", tool, container, err)
+		}
+		client.version, _, _ = strings.Cut(out, "\n")
+	}
+	return client, nil
+}
+
+// checkRunning fails unless the container exists and is running
+func (c containerExecClient) checkRunning() error {
+	var running bool
+	if c.runtime.engine != nil {
+		var err error
+		running, err = c.runtime.engine.inspectContainer(c.context(), c.container)
+		var status *engineError
+		if errors.As(err, &status) && status.status == http.StatusNotFound {
+			return fmt.Errorf("container %s not found", c.container)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to inspect container %s: %v", c.container, err)
+		}
+	} else {
+		var stderr bytes.Buffer
+		cmd := exec.Command(c.runtime.binary, "inspect", "--format", "{{.State.Running}}", c.container)
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return fmt.Errorf("container %s not found: %s", c.container, strings.TrimSpace(stderr.String()))
+		}
+		running = strings.TrimSpace(string(out)) == "true"
+	}
+
+	if !running {
+		return fmt.Errorf("container %s is not running", c.container)
+	}
+	return nil
+}
+
+func (c containerExecClient) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// query runs a client command and returns its trimmed stdout
+func (c containerExecClient) query(args []string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	if err := c.start(args, nil, &stdout, &stderr); err != nil {
+		return "", fmt.Errorf("%s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// run executes a client command with stdin attached to input
+func (c containerExecClient) run(args []string, input io.Reader) error {
+	return c.start(args, input, os.Stdout, os.Stderr)
+}
+
+// dump runs a client command with its stdout connected to stdout
+func (c containerExecClient) dump(args []string, stdout io.Writer) error {
+	return c.start(args, nil, stdout, os.Stderr)
+}
+
+// withImage returns c unchanged: the container's own tools are used
+func (c containerExecClient) withImage(image string) dumpClient {
+	return c
+}
+
+// clientImage is empty: no client container is started
+func (c containerExecClient) clientImage() string {
+	return ""
+}
+
+// start runs args in the container, streaming input to its stdin when not
+// nil. The engine cannot kill an exec, so canceling ends the session and
+// the tool stops at its next read or write.
+func (c containerExecClient) start(args []string, input io.Reader, stdout, stderr io.Writer) error {
+	if c.runtime.engine == nil {
+		return c.cliExec(args, input, stdout, stderr)
+	}
+
+	ctx := c.context()
+	api := c.runtime.engine
+	id, err := api.createExec(ctx, c.container, engineExecConfig{
+		Cmd:          args,
+		Env:          c.env,
+		AttachStdin:  input != nil,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to exec in container %s: %v", c.container, err)
+	}
+
+	conn, stream, err := api.startExec(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to exec in container %s: %v", c.container, err)
+	}
+	defer conn.Close()
+	if err := copyStreams(ctx, conn, stream, input, stdout, stderr); err != nil {
+		return err
+	}
+
+	status, err := api.execExitCode(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to inspect exec: %v", err)
+	}
+	if status != 0 {
+		return fmt.Errorf("exit status %d", status)
+	}
+	return nil
+}
+
+// cliExec runs args with the container CLI's exec. Each variable is passed
+// by name so that its value is read from the CLI's own environment.
+func (c containerExecClient) cliExec(args []string, input io.Reader, stdout, stderr io.Writer) error {
+	execArgs := []string{"exec"}
+	if input != nil {
+		execArgs = append(execArgs, "-i")
+	}
+	for _, variable := range c.env {
+		name, _, _ := strings.Cut(variable, "=")
+		execArgs = append(execArgs, "-e", name)
+	}
+	execArgs = append(execArgs, c.container)
+	execArgs = append(execArgs, args...)
+
+	var cmd *exec.Cmd
+	if c.ctx == nil {
+		cmd = exec.Command(c.runtime.binary, execArgs...)
+	} else {
+		cmd = exec.CommandContext(c.ctx, c.runtime.binary, execArgs...)
+	}
+	cmd.Env = append(os.Environ(), c.env...)
+	if input != nil {
+		cmd.Stdin = input
+	}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	return cmd.Run()
+}
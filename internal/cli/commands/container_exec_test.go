@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenClientExecsInContainer(t *testing.T) {
+	e := newFakeEngine(t)
+	e.running["shop-db-1"] = true
+	fakePostgresTools(t, "16.2", "16.2")
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "app", Password: "s3cr3t", Database: "orders"}
+
+	client, conn, cleanup, err := openClient(context.Background(), postgresDriver{}, p,
+		clientOptions{runtime: "auto", image: "postgres:15-alpine", execContainer: "shop-db-1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer cleanup()
+
+	exec, ok := client.(containerExecClient)
+	if !ok {
+		t.Fatalf("Expected a container exec client, got %T", client)
+	}
+	if exec.version != "pg_dump (PostgreSQL) 16.2" || conn != p {
+		t.Errorf("Expected the container's pg_dump and unchanged params, got %q and %+v", exec.version, conn)
+	}
+	if client.clientImage() != "" {
+		t.Errorf("Expected no client image, got %q", client.clientImage())
+	}
+
+	got, err := client.query([]string{"sh", "-c", `printf %s "$PGPASSWORD"`})
+	if err != nil || got != "s3cr3t" {
+		t.Errorf("Expected the password in the exec env, got %q (%v)", got, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := exec.start([]string{"tr", "a-z", "A-Z"}, strings.NewReader("restore me"), &stdout, &stderr); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stdout.String() != "RESTORE ME" {
+		t.Errorf("Expected stdin piped through, got %q", stdout.String())
+	}
+
+	_, err = client.query([]string{"sh", "-c", "echo denied >&2; exit 2"})
+	if err == nil || err.Error() != "sh failed: exit status 2: denied" {
+		t.Errorf("Expected the exit status and stderr, got %v", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.created) != 0 {
+		t.Errorf("Expected no client containers, got %d", len(e.created))
+	}
+}
+
+func TestOpenContainerExecErrors(t *testing.T) {
+	e := newFakeEngine(t)
+	e.running["stopped-db"] = false
+	e.running["bare"] = true
+	t.Setenv("PATH", t.TempDir())
+	rt := containerRuntime{name: "docker", engine: e.api}
+	p := ConnParams{Host: "localhost", Database: "orders"}
+
+	tests := []struct {
+		container string
+		err       string
+	}{
+		{"missing-db", "container missing-db not found"},
+		{"stopped-db", "container stopped-db is not running"},
+		{"bare", "pg_dump cannot run in container bare"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.container, func(t *testing.T) {
+			_, err := openContainerExec(context.Background(), postgresDriver{}, p, rt, tt.container)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("Expected %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestContainerExecClientCLI(t *testing.T) {
+	noEngineAPI(t)
+	log := filepath.Join(t.TempDir(), "args")
+	fakeTools(t, map[string]string{
+		"podman": `echo "$@" >> ` + log + `
+case "$1" in
+  --version) echo 'podman version 5.2.2' ;;
+  info) echo 'false|false' ;;
+  inspect) echo true ;;
+  exec)
+    shift
+    while [ "${1#-}" != "$1" ]; do [ "$1" = -e ] && shift; shift; done
+    shift
+    exec "$@" ;;
+esac`,
+	})
+	fakePostgresTools(t, "16.2", "16.2")
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "app", Password: "s3cr3t", Database: "orders"}
+
+	client, _, cleanup, err := openClient(context.Background(), postgresDriver{}, p,
+		clientOptions{runtime: "podman", execContainer: "shop-db-1"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer cleanup()
+
+	got, err := client.query([]string{"sh", "-c", `printf %s "$PGPASSWORD"`})
+	if err != nil || got != "s3cr3t" {
+		t.Errorf("Expected the password in the exec env, got %q (%v)", got, err)
+	}
+
+	args, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatalf("Failed to read podman arguments: %v", err)
+	}
+	if !strings.Contains(string(args), "exec -e PGPASSWORD shop-db-1 pg_dump --version") {
+		t.Errorf("Expected the password passed by name, got:\n%s", args)
+	}
+	if strings.Contains(string(args), "s3cr3t") {
+		t.Errorf("Expected the password kept off the command line, got:\n%s", args)
+	}
+}
+
+func TestOpenClientDockerNetwork(t *testing.T) {
+	newFakeEngine(t)
+	p := ConnParams{Host: "db", Port: 5432, Username: "app", Database: "orders"}
+
+	client, conn, cleanup, err := openClient(context.Background(), postgresDriver{}, p,
+		clientOptions{runtime: "docker", image: "postgres:16-alpine", container: containerSettings{network: "shop_default"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer cleanup()
+
+	engine, ok := client.(engineClient)
+	if !ok {
+		t.Fatalf("Expected an engine client, got %T", client)
+	}
+	if engine.spec.network != "shop_default" || conn.Host != "db" {
+		t.Errorf("Expected the compose network and service name, got %q and %s", engine.spec.network, conn.Host)
+	}
+
+	_, _, _, err = openClient(context.Background(), sqliteDriver{}, ConnParams{Path: "/srv/app.db"},
+		clientOptions{runtime: "docker", container: containerSettings{network: "shop_default"}})
+	if err == nil || err.Error() != "--docker-network does not apply to SQLite files" {
+		t.Errorf("Expected SQLite to refuse a network, got %v", err)
+	}
+}
+
+func TestClientOptionsCheck(t *testing.T) {
+	network := containerSettings{network: "shop_default"}
+	tests := []struct {
+		name string
+		opts clientOptions
+		err  string
+	}{
+		{"Plain", clientOptions{runtime: "auto"}, ""},
+		{"Container", clientOptions{runtime: "auto", execContainer: "db"}, ""},
+		{"Network", clientOptions{runtime: "docker", container: network}, ""},
+		{"Both", clientOptions{runtime: "auto", execContainer: "db", container: network}, "cannot be combined"},
+		{"Container over SSH", clientOptions{runtime: "auto", execContainer: "db", sshJump: "bastion"}, "cannot be combined with --ssh-jump"},
+		{"Network over SSH", clientOptions{runtime: "docker", sshJump: "bastion", container: network}, "cannot be combined with --ssh-jump"},
+		{"Container locally", clientOptions{runtime: "local", execContainer: "db"}, "need a container runtime"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.check()
+			if tt.err == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
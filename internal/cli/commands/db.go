@@ -1,13 +1,15 @@
 package commands
 
 import (
+	"context"
 	"fmt"
-	"net"
+	"io"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"path"
+	"strings"
 	"time"
 
+	"filippo.io/age"
 	"github.com/spf13/cobra"
 )
 
@@ -18,333 +20,352 @@ func NewDBCmd() *cobra.Command {
 	}
 
 	cmd.AddCommand(newDBBackupCmd())
+	cmd.AddCommand(newDBRestoreCmd())
 	cmd.AddCommand(newDBListCmd())
+	cmd.AddCommand(newDBVerifyCmd())
+	cmd.AddCommand(newDBPruneCmd())
 
 	return cmd
 }
 
+// backupOptions holds everything needed to dump a database to a local file
+type backupOptions struct {
+	connectionOptions
+	output   string
+	compress bool
+	// compression replaces compress, which only survives as --compress=false
+	compression compressionOptions
+	encrypt     encryptOptions
+	storage     storageOptions
+	filters     DumpFilters
+	format      string
+	jobs        int
+	unpack      bool
+	// progress is a --progress value until runDBBackup resolves it to
+	// line, json or none
+	progress string
+	// toolVersion is the cutter version recorded in the manifest
+	toolVersion string
+}
+
 func newDBBackupCmd() *cobra.Command {
-	var (
-		host     string
-		port     int
-		username string
-		password string
-		database string
-		dbType   string
-		output   string
-		compress bool
-		sshJump  string
-	)
+	var opts backupOptions
 
 	cmd := &cobra.Command{
 		Use:   "backup",
 		Short: "Backup database directly to local machine",
-		Example: `  # Direct PostgreSQL backup
+		Example: `  # Direct PostgreSQL backup (prompts for the password)
   cutter db backup --type postgres --host localhost --port 5432 \
-    --username myuser --password mypass --database mydb
+    --username myuser --database mydb
 
-  # Backup via SSH jump host
+  # Backup via SSH jump host with the password read from a file
   cutter db backup --type postgres --host 10.0.1.10 --port 5432 \
-    --username myuser --password mypass --database mydb \
+    --username myuser --password-file ~/.secrets/mydb --database mydb \
     --ssh-jump user@jumphost.com
 
+  # Backup from a connection URI with TLS required
+  cutter db backup --dsn 'postgres://myuser@db.example.com:5432/mydb?sslmode=require'
+
   # Backup with custom output
   cutter db backup --type postgres --host localhost --database mydb \
-    --output ~/backups/mydb.sql.gz`,
+    --output ~/backups/mydb.sql.gz
+
+  # Schema only, for a migration review
+  cutter db backup --profile prod-orders --schema-only
+
+  # Everything except the large audit tables
+  cutter db backup --profile prod-orders --exclude-table 'audit_*' --exclude-table events
+
+  # Encrypted backup that only the holder of the age key can read
+  cutter db backup --profile prod-orders \
+    --encrypt-recipient age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+
+  # Consistent online copy of a SQLite file on a remote host
+  cutter db backup --type sqlite --path /srv/app/data.db --ssh-jump deploy@app1
+
+  # Backup using a saved connection profile
+  cutter db backup --profile prod-orders`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runDBBackup(dbType, host, port, username, password, database, output, compress, sshJump)
+			if _, err := opts.prepare(cmd); err != nil {
+				return err
+			}
+
+			if err := applyCompressFlag(cmd, opts.compress, &opts.compression); err != nil {
+				return err
+			}
+
+			opts.toolVersion = cmd.Root().Version
+			return runDBBackup(opts)
 		},
 	}
 
-	cmd.Flags().StringVar(&dbType, "type", "postgres", "Database type (postgres, mysql)")
-	cmd.Flags().StringVar(&host, "host", "localhost", "Database host")
-	cmd.Flags().IntVar(&port, "port", 5432, "Database port")
-	cmd.Flags().StringVar(&username, "username", "", "Database username")
-	cmd.Flags().StringVar(&password, "password", "", "Database password")
-	cmd.Flags().StringVar(&database, "database", "", "Database name")
-	cmd.Flags().StringVar(&output, "output", "", "Output file path (default: auto-generated)")
-	cmd.Flags().BoolVar(&compress, "compress", true, "Compress with gzip")
-	cmd.Flags().StringVar(&sshJump, "ssh-jump", "", "SSH jump host (user@host)")
-
-	cmd.MarkFlagRequired("database")
-	cmd.MarkFlagRequired("username")
+	addConnectionFlags(cmd, &opts.connectionOptions, "Database name")
+	cmd.Flags().StringVar(&opts.path, "path", "", "Database file for SQLite; on the last --ssh-jump hop when one is given")
+	cmd.Flags().StringVar(&opts.output, "output", "", "Output file, directory or s3://bucket/prefix/ (default: auto-generated name in the current directory)")
+	cmd.Flags().BoolVar(&opts.compress, "compress", true, "Compress the backup")
+	cmd.Flags().MarkDeprecated("compress", "use --compression none to disable compression")
+	addCompressionFlags(cmd, &opts.compression)
+	cmd.Flags().StringVar(&opts.format, "format", "", "Dump format: plain, custom, directory or tar for PostgreSQL, archive for MongoDB (default: plain, or archive for MongoDB)")
+	cmd.Flags().IntVar(&opts.jobs, "jobs", 1, "Parallel dump jobs (--format directory)")
+	cmd.Flags().BoolVar(&opts.unpack, "unpack", false, "Leave a directory-format dump as a folder instead of packing it into a tar file (local output only)")
+	addFilterFlags(cmd, &opts.filters)
+	addProgressFlag(cmd, &opts.progress)
+	addEncryptFlags(cmd, &opts.encrypt)
+	addStorageFlags(cmd, &opts.storage)
 
 	return cmd
 }
 
-func runDBBackup(dbType, host string, port int, username, password, database, output string, compress bool, sshJump string) error {
-	// Generate output filename if not provided
-	if output == "" {
-		timestamp := time.Now().Format("20060102_150405")
-		output = fmt.Sprintf("%s_%s.sql", database, timestamp)
-		if compress {
-			output += ".gz"
-		}
+func runDBBackup(opts backupOptions) error {
+	driver, err := lookupDriver(opts.dbType)
+	if err != nil {
+		return err
 	}
-
-	fmt.Printf("Starting backup for %s database: %s\n", dbType, database)
-	fmt.Printf("Host: %s:%d\n", host, port)
-	fmt.Printf("Output: %s\n", output)
-
-	var err error
-	switch dbType {
-	case "postgres":
-		err = backupPostgres(host, port, username, password, database, output, compress, sshJump)
-	case "mysql":
-		err = backupMySQL(host, port, username, password, database, output, compress, sshJump)
-	default:
-		return fmt.Errorf("unsupported database type: %s", dbType)
+	if opts.format == "" {
+		opts.format = driver.Formats()[0]
 	}
 
+	if err := opts.filters.validate(); err != nil {
+		return err
+	}
+	if err := opts.compression.validate(); err != nil {
+		return err
+	}
+	opts.progress, err = resolveProgressMode(opts.progress)
 	if err != nil {
-		return fmt.Errorf("backup failed: %v", err)
+		return err
 	}
-
-	// Get file size
-	fileInfo, err := os.Stat(output)
-	if err == nil {
-		size := float64(fileInfo.Size()) / (1024 * 1024) // MB
-		fmt.Printf("\n✓ Backup completed successfully!\n")
-		fmt.Printf("  File: %s\n", output)
-		fmt.Printf("  Size: %.2f MB\n", size)
+	if err := checkDumpFormat(driver, opts); err != nil {
+		return err
 	}
 
-	return nil
-}
-
-func backupPostgres(host string, port int, username, password, database, output string, compress bool, sshJump string) error {
-	// Check if Docker is available
-	if _, err := exec.LookPath("docker"); err != nil {
-		return fmt.Errorf("docker is not installed")
+	recipients, err := resolveRecipients(opts.encrypt)
+	if err != nil {
+		return err
 	}
 
-	// Handle SSH jump host if provided
-	var tunnel *sshTunnel
-	var err error
-	originalHost := host
-	originalPort := port
-
-	if sshJump != "" {
-		// Create SSH tunnel
-		tunnel, err = createSSHTunnel(sshJump, host, port)
-		if err != nil {
-			return fmt.Errorf("SSH tunnel failed: %v", err)
-		}
-		defer tunnel.close()
-
-		// Update host and port to use tunnel
-		host = "host.docker.internal"
-		port = tunnel.localPort
+	store, name, err := openStore(opts.output, opts.storage)
+	if err != nil {
+		return err
 	}
 
-	fmt.Println("Using Docker PostgreSQL client...")
-
-	// Build pg_dump command
-	var pgDumpCmd string
-	var dockerOpts string
-
-	if sshJump != "" {
-		// When using tunnel, connect through localhost via host.docker.internal
-		pgDumpCmd = fmt.Sprintf("PGPASSWORD=%s pg_dump -h host.docker.internal -p %d -U %s %s",
-			password, port, username, database)
-		// Add host mapping for Linux compatibility
-		dockerOpts = "--add-host=host.docker.internal:host-gateway"
-	} else {
-		// Direct connection using host network
-		pgDumpCmd = fmt.Sprintf("PGPASSWORD=%s pg_dump -h %s -p %d -U %s %s",
-			password, originalHost, originalPort, username, database)
-		dockerOpts = "--network host"
+	// Snapshots of a whole server, such as Redis RDB files, have no
+	// database and are named after the host; file databases after the file
+	source := opts.database
+	_, isFile := driver.(fileDriver)
+	switch {
+	case isFile:
+		source = strings.TrimSuffix(path.Base(opts.path), path.Ext(opts.path))
+	case source == "":
+		source = opts.host
 	}
 
-	var cmdStr string
-
-	if compress {
-		// Use Docker to run pg_dump and compress
-		cmdStr = fmt.Sprintf(
-			`docker run --rm %s postgres:15-alpine sh -c "%s" | gzip > %s`,
-			dockerOpts, pgDumpCmd, output,
-		)
-	} else {
-		// Use Docker to run pg_dump without compression
-		cmdStr = fmt.Sprintf(
-			`docker run --rm %s postgres:15-alpine sh -c "%s" > %s`,
-			dockerOpts, pgDumpCmd, output,
-		)
+	// Generate output filename if not provided
+	if name == "" {
+		timestamp := time.Now().Format("20060102_150405")
+		name = safeFilename(source) + "_" + timestamp +
+			backupExtension(opts.format, opts.compression, !opts.unpack, len(recipients) > 0)
 	}
 
-	cmd := exec.Command("bash", "-c", cmdStr)
-	cmd.Stderr = os.Stderr
-
-	return cmd.Run()
-}
-
-func backupMySQL(host string, port int, username, password, database, output string, compress bool, sshJump string) error {
-	// Check if Docker is available
-	if _, err := exec.LookPath("docker"); err != nil {
-		return fmt.Errorf("docker is not installed")
+	fmt.Printf("Starting backup for %s database: %s\n", opts.dbType, source)
+	if isFile {
+		fmt.Printf("File: %s\n", opts.path)
+	} else {
+		fmt.Printf("Host: %s:%d\n", opts.host, opts.port)
 	}
-
-	// Handle SSH jump host if provided
-	var tunnel *sshTunnel
-	var err error
-	originalHost := host
-	originalPort := port
-
-	if sshJump != "" {
-		// Create SSH tunnel
-		tunnel, err = createSSHTunnel(sshJump, host, port)
-		if err != nil {
-			return fmt.Errorf("SSH tunnel failed: %v", err)
-		}
-		defer tunnel.close()
-
-		// Update host and port to use tunnel
-		host = "host.docker.internal"
-		port = tunnel.localPort
+	fmt.Printf("Output: %s\n", store.Location(name))
+	if len(recipients) > 0 {
+		fmt.Println("Encryption: age")
 	}
 
-	fmt.Println("Using Docker MySQL client...")
+	ctx, stop := interruptContext()
+	defer stop()
 
-	// Build mysqldump command
-	var mysqldumpCmd string
-	var dockerOpts string
-
-	if sshJump != "" {
-		// When using tunnel, connect through localhost via host.docker.internal
-		mysqldumpCmd = fmt.Sprintf("mysqldump -h host.docker.internal -P %d -u %s -p%s %s",
-			port, username, password, database)
-		// Add host mapping for Linux compatibility
-		dockerOpts = "--add-host=host.docker.internal:host-gateway"
-	} else {
-		// Direct connection using host network
-		mysqldumpCmd = fmt.Sprintf("mysqldump -h %s -P %d -u %s -p%s %s",
-			originalHost, originalPort, username, password, database)
-		dockerOpts = "--network host"
+	manifest, err := backupWithDriver(ctx, driver, opts, store, name, recipients)
+	if err != nil {
+		if ctx.Err() != nil {
+			// streamDump has already removed the partial output
+			return fmt.Errorf("backup %w, no file was written", context.Cause(ctx))
+		}
+		return fmt.Errorf("backup failed: %v", err)
 	}
 
-	var cmdStr string
-
-	if compress {
-		cmdStr = fmt.Sprintf(
-			`docker run --rm %s mysql:8 sh -c "%s" | gzip > %s`,
-			dockerOpts, mysqldumpCmd, output,
-		)
-	} else {
-		cmdStr = fmt.Sprintf(
-			`docker run --rm %s mysql:8 sh -c "%s" > %s`,
-			dockerOpts, mysqldumpCmd, output,
-		)
+	if err := writeManifest(store, name, manifest); err != nil {
+		return err
 	}
 
-	cmd := exec.Command("bash", "-c", cmdStr)
-	cmd.Stderr = os.Stderr
+	size := float64(manifest.Size) / (1024 * 1024) // MB
+	fmt.Printf("\n✓ Backup completed successfully!\n")
+	fmt.Printf("  File:     %s\n", store.Location(name))
+	fmt.Printf("  Size:     %.2f MB\n", size)
+	fmt.Printf("  SHA-256:  %s\n", manifest.SHA256)
+	fmt.Printf("  Manifest: %s\n", store.Location(manifestPath(name)))
 
-	return cmd.Run()
+	return nil
 }
 
-func newDBListCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:   "list",
-		Short: "List backup files in current directory",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			files, _ := filepath.Glob("*.sql*")
-			if len(files) == 0 {
-				fmt.Println("No backup files found in current directory")
-				return nil
-			}
-
-			fmt.Println("Backup files:")
-			for _, file := range files {
-				info, _ := os.Stat(file)
-				size := float64(info.Size()) / (1024 * 1024)
-				fmt.Printf("  - %s (%.2f MB)\n", file, size)
-			}
-			return nil
-		},
+// backupWithDriver runs the driver's dump command through its client,
+// streams the output, optionally compressed and encrypted, to name in store
+// and returns the manifest describing the result. Canceling ctx stops the
+// client container and discards the partial output.
+func backupWithDriver(ctx context.Context, d BackupDriver, opts backupOptions, store backupStore, name string, recipients []age.Recipient) (*backupManifest, error) {
+	manifest := &backupManifest{
+		Format:      manifestFormat,
+		File:        name,
+		Encrypted:   len(recipients) > 0,
+		StartedAt:   time.Now().UTC(),
+		Engine:      d.Name(),
+		ToolVersion: opts.toolVersion,
+		Host:        opts.host,
+		Port:        opts.port,
+		Database:    opts.database,
+		Path:        opts.path,
+		Username:    opts.username,
+		SSHJump:     opts.sshJump,
+	}
+	stream := streamCompression(opts.format, opts.compression)
+	manifest.setCompression(opts.format, opts.compression)
+	manifest.DumpFormat = opts.format
+	if opts.format == "directory" {
+		manifest.Jobs = opts.jobs
+	}
+	if !opts.filters.isEmpty() {
+		filters := opts.filters
+		manifest.Filters = &filters
 	}
-}
-
-// sshTunnel represents an active SSH tunnel
-type sshTunnel struct {
-	cmd       *exec.Cmd
-	localPort int
-}
 
-// findAvailablePort finds an available local port
-func findAvailablePort() (int, error) {
-	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	client, conn, cleanup, err := openClient(ctx, d, opts.connParams(), opts.clientOptions(d))
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	defer listener.Close()
-
-	addr := listener.Addr().(*net.TCPAddr)
-	return addr.Port, nil
-}
-
-// createSSHTunnel creates an SSH tunnel to the database through a jump host
-func createSSHTunnel(sshJump, dbHost string, dbPort int) (*sshTunnel, error) {
-	// Validate SSH jump host format (user@host or user@host:port)
-	if sshJump == "" {
-		return nil, fmt.Errorf("SSH jump host cannot be empty")
+	defer cleanup()
+
+	client, manifest.ServerVersion, manifest.ClientImage = matchClientImage(client, d, conn, opts.images)
+	switch c := client.(type) {
+	case localClient:
+		manifest.ClientTool = c.version
+	case containerExecClient:
+		manifest.ClientTool, manifest.ClientContainer = c.version, c.container
+	}
+	if _, ok := d.(fileDriver); ok {
+		// A file database has no server address
+		manifest.Host, manifest.Port = "", 0
 	}
 
-	// Find available local port
-	localPort, err := findAvailablePort()
+	filters, err := d.ResolveFilters(client.query, conn, opts.filters)
 	if err != nil {
-		return nil, fmt.Errorf("failed to find available port: %v", err)
+		return nil, err
 	}
 
-	// Build SSH tunnel command
-	// Format: ssh -f -N -L local_port:db_host:db_port user@jumphost
-	tunnelSpec := fmt.Sprintf("%d:%s:%d", localPort, dbHost, dbPort)
-
-	fmt.Printf("Creating SSH tunnel through %s...\n", sshJump)
-	fmt.Printf("  Local port %d -> %s:%d\n", localPort, dbHost, dbPort)
-
-	cmd := exec.Command("ssh",
-		"-f",                             // Run in background
-		"-N",                             // Don't execute remote command
-		"-o", "ExitOnForwardFailure=yes", // Exit if tunnel fails
-		"-o", "StrictHostKeyChecking=no", // Don't prompt for host key
-		"-L", tunnelSpec, // Local port forwarding
-		sshJump, // Jump host
-	)
+	format := DumpFormat{Name: opts.format, Jobs: opts.jobs, Compress: opts.compression.enabled()}
+	manifest.Command = d.DumpCommand(conn, filters, format)
+	dump := func(stdout io.Writer) error {
+		return client.dump(manifest.Command, stdout)
+	}
 
-	cmd.Stderr = os.Stderr
+	var progress *progressReporter
+	if opts.progress == "line" || opts.progress == "json" {
+		// The size of a filtered dump has little to do with the database's
+		var total int64
+		if filters.isEmpty() {
+			if size, err := d.DatabaseSize(client.query, conn); err == nil {
+				total = size
+			}
+		}
+		progress = newProgressReporter(opts.progress, total)
+	}
 
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("failed to start SSH tunnel: %v", err)
+	var stats dumpStats
+	if opts.unpack {
+		stats, err = streamDumpDir(manifest.Command[0], dump, store.Location(name), progress)
+	} else {
+		stats, err = streamDump(manifest.Command[0], dump, store, name, stream, recipients, progress)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Wait a moment for tunnel to establish
-	time.Sleep(2 * time.Second)
+	manifest.FinishedAt = time.Now().UTC()
+	manifest.SHA256 = stats.SHA256
+	manifest.Size = stats.FileBytes
+	manifest.UncompressedSize = stats.RawBytes
+	return manifest, nil
+}
 
-	tunnel := &sshTunnel{
-		cmd:       cmd,
-		localPort: localPort,
-	}
+func newDBListCmd() *cobra.Command {
+	var (
+		storage storageOptions
+		profile string
+	)
 
-	fmt.Println("✓ SSH tunnel established")
+	cmd := &cobra.Command{
+		Use:   "list [location]",
+		Short: "List backup files in a directory or bucket prefix",
+		Args:  cobra.MaximumNArgs(1),
+		Example: `  # Backups in the current directory
+  cutter db list
+
+  # Backups uploaded to object storage
+  cutter db list s3://backups/orders/ --s3-endpoint minio.internal:9000`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := applyConnectionDefaults(cmd, profile); err != nil {
+				return err
+			}
 
-	return tunnel, nil
-}
+			location, err := defaultLocation(profile)
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				location = args[0]
+			}
+			if !isObjectStorage(location) && !strings.HasSuffix(location, "/") {
+				location += string(os.PathSeparator)
+			}
 
-// close terminates the SSH tunnel
-func (t *sshTunnel) close() error {
-	if t.cmd == nil || t.cmd.Process == nil {
-		return nil
-	}
+			store, _, err := openStore(location, storage)
+			if err != nil {
+				return err
+			}
+			files, err := store.List()
+			if err != nil {
+				return err
+			}
 
-	fmt.Println("Closing SSH tunnel...")
+			var backups []storedBackup
+			for _, file := range files {
+				if isBackupName(file.Name) {
+					backups = append(backups, file)
+				}
+			}
+			if len(backups) == 0 {
+				fmt.Printf("No backup files found in %s\n", store.Location(""))
+				return nil
+			}
 
-	// Kill the SSH process
-	if err := t.cmd.Process.Kill(); err != nil {
-		return fmt.Errorf("failed to kill SSH tunnel: %v", err)
+			fmt.Println("Backup files:")
+			for _, file := range backups {
+				size := float64(file.Size) / (1024 * 1024)
+				details := fmt.Sprintf("%.2f MB", size)
+				if label := formatLabel(file.Name); label != "plain" {
+					details += ", " + label
+				}
+				if backupFormat(file.Name) != "directory" || strings.Contains(file.Name, ".dir.tar") {
+					if compression := storedCompression(store, file.Name); compression != "none" {
+						details += ", " + compression
+					}
+				}
+				if strings.HasSuffix(file.Name, ".age") {
+					details += ", encrypted"
+				}
+				fmt.Printf("  - %s (%s)\n", file.Name, details)
+			}
+			return nil
+		},
 	}
 
-	// Wait for process to exit
-	_ = t.cmd.Wait()
+	addStorageFlags(cmd, &storage)
+	addProfileFlag(cmd, &profile)
 
-	return nil
+	return cmd
 }
@@ -0,0 +1,248 @@
+package commands
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// restoreOptions holds everything needed to replay a dump into a database
+type restoreOptions struct {
+	connectionOptions
+	input        string
+	createDB     bool
+	dropExisting bool
+	yes          bool
+	identity     string
+}
+
+func newDBRestoreCmd() *cobra.Command {
+	var opts restoreOptions
+
+	cmd := &cobra.Command{
+		Use:   "restore <file>",
+		Short: "Restore a backup file into a database",
+		Args:  cobra.ExactArgs(1),
+		Example: `  # Restore a compressed PostgreSQL backup
+  cutter db restore mydb_20240101_120000.sql.gz --type postgres \
+    --host localhost --username myuser --password-env MYDB_PASSWORD --database mydb
+
+  # Restore into a fresh database via SSH jump host
+  cutter db restore mydb.sql --type mysql --host 10.0.1.10 --port 3306 \
+    --username root --database mydb --create-db \
+    --ssh-jump user@jumphost.com
+
+  # Replace an existing database without prompting
+  cutter db restore mydb.sql.gz --host localhost --username myuser \
+    --database mydb --drop-existing --yes
+
+  # Restore an encrypted backup with an age identity file
+  cutter db restore mydb.sql.gz.age --profile staging-orders \
+    --identity ~/.config/cutter/age-key.txt
+
+  # Restore using a saved connection profile
+  cutter db restore mydb.sql.gz --profile staging-orders`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, err := opts.prepare(cmd); err != nil {
+				return err
+			}
+
+			opts.input = args[0]
+			return runDBRestore(opts)
+		},
+	}
+
+	addConnectionFlags(cmd, &opts.connectionOptions, "Target database name")
+	cmd.Flags().BoolVar(&opts.createDB, "create-db", false, "Create the target database if it does not exist")
+	cmd.Flags().BoolVar(&opts.dropExisting, "drop-existing", false, "Drop and recreate the target database before restoring")
+	cmd.Flags().BoolVarP(&opts.yes, "yes", "y", false, "Skip confirmation prompts")
+	cmd.Flags().StringVar(&opts.identity, "identity", "", "age identity file for decrypting key-encrypted backups")
+
+	cmd.MarkFlagsMutuallyExclusive("create-db", "drop-existing")
+
+	return cmd
+}
+
+func runDBRestore(opts restoreOptions) error {
+	driver, err := lookupDriver(opts.dbType)
+	if err != nil {
+		return err
+	}
+
+	if backupFormat(opts.input) == "directory" {
+		return fmt.Errorf("%s is a directory-format dump; unpack it and run pg_restore --format=directory --jobs N on the folder", opts.input)
+	}
+
+	file, err := os.Open(opts.input)
+	if err != nil {
+		return fmt.Errorf("failed to open backup file: %v", err)
+	}
+	defer file.Close()
+
+	decrypted, encrypted, err := openDecryptingReader(file, opts.identity)
+	if err != nil {
+		return err
+	}
+
+	reader, compression, err := openDumpReader(decrypted)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	dump, format, err := detectArchive(reader)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "rdb":
+		return fmt.Errorf("%s is a Redis snapshot, which cannot be restored over a connection; copy it to the server's data directory as dump.rdb and restart Redis", opts.input)
+	case "sqlite":
+		return fmt.Errorf("%s is a SQLite database file; decompress it and copy it over the database while the application is stopped", opts.input)
+	}
+	if !slices.Contains(driver.Formats(), format) {
+		return fmt.Errorf("%s is a %s-format dump, which %s cannot restore", opts.input, format, driver.DisplayName())
+	}
+
+	fmt.Printf("Starting restore for %s database: %s\n", opts.dbType, opts.database)
+	fmt.Printf("Host: %s:%d\n", opts.host, opts.port)
+	fmt.Printf("Input: %s", opts.input)
+	var layers []string
+	if encrypted {
+		layers = append(layers, "age")
+	}
+	if compression != "none" {
+		layers = append(layers, compression)
+	}
+	if len(layers) > 0 {
+		fmt.Printf(" (%s)", strings.Join(layers, ", "))
+	}
+	if format != "plain" {
+		fmt.Printf(" [%s format]", format)
+	}
+	fmt.Println()
+
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if err := restoreWithDriver(ctx, driver, opts, dump, format); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("restore %w; database %s may be partly restored", context.Cause(ctx), opts.database)
+		}
+		return fmt.Errorf("restore failed: %v", err)
+	}
+
+	fmt.Printf("\n✓ Restore completed successfully!\n")
+	fmt.Printf("  Database: %s\n", opts.database)
+
+	return nil
+}
+
+// restoreWithDriver prepares the target database and streams dump, in
+// format, into it. Canceling ctx stops the client and its container.
+func restoreWithDriver(ctx context.Context, d BackupDriver, opts restoreOptions, dump io.Reader, format string) error {
+	client, conn, cleanup, err := openClient(ctx, d, opts.connParams(), opts.clientOptions(d))
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	client, _, _ = matchClientImage(client, d, conn, opts.images)
+
+	switch {
+	case opts.dropExisting:
+		ok, err := confirmUnlessCanceled(ctx, opts.yes, fmt.Sprintf("This will DROP database %s on %s:%d. Continue?", opts.database, opts.host, opts.port))
+		if err != nil || !ok {
+			return errRestoreAborted(err)
+		}
+		fmt.Printf("Dropping database %s...\n", opts.database)
+		if err := d.CreateDatabase(client.query, conn, true); err != nil {
+			return err
+		}
+	case opts.createDB:
+		fmt.Printf("Creating database %s if needed...\n", opts.database)
+		if err := d.CreateDatabase(client.query, conn, false); err != nil {
+			return err
+		}
+		// The database may have existed already
+		if err := confirmEmpty(ctx, d, client, conn, opts); err != nil {
+			return err
+		}
+	default:
+		if err := confirmEmpty(ctx, d, client, conn, opts); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println("Restoring dump...")
+	return client.run(d.RestoreCommand(conn, format), dump)
+}
+
+// confirmEmpty asks before restoring into a database that already contains
+// tables
+func confirmEmpty(ctx context.Context, d BackupDriver, client dumpClient, conn ConnParams, opts restoreOptions) error {
+	tables, err := d.CountTables(client.query, conn)
+	if err != nil {
+		return err
+	}
+	if tables > 0 {
+		ok, err := confirmUnlessCanceled(ctx, opts.yes, fmt.Sprintf("Database %s already contains %d tables. Restore into it anyway?", opts.database, tables))
+		if err != nil || !ok {
+			return errRestoreAborted(err)
+		}
+	}
+	return nil
+}
+
+func errRestoreAborted(err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("restore aborted by user")
+}
+
+// confirmUnlessCanceled asks question on stdin like confirm, but gives up
+// once ctx is canceled so that Ctrl-C at the prompt stops the restore
+func confirmUnlessCanceled(ctx context.Context, assumeYes bool, question string) (bool, error) {
+	type answer struct {
+		ok  bool
+		err error
+	}
+	answers := make(chan answer, 1)
+	go func() {
+		ok, err := confirm(os.Stdin, assumeYes, question)
+		answers <- answer{ok, err}
+	}()
+
+	select {
+	case a := <-answers:
+		return a.ok, a.err
+	case <-ctx.Done():
+		return false, context.Cause(ctx)
+	}
+}
+
+// confirm prints question and waits for a yes/no answer on in.
+// It returns true immediately when assumeYes is set.
+func confirm(in io.Reader, assumeYes bool, question string) (bool, error) {
+	if assumeYes {
+		return true, nil
+	}
+
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, fmt.Errorf("failed to read confirmation: %v", err)
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
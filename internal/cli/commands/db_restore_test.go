@@ -0,0 +1,219 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewDBRestoreCmd(t *testing.T) {
+	cmd := newDBRestoreCmd()
+
+	if cmd.Name() != "restore" {
+		t.Errorf("Expected name 'restore', got '%s'", cmd.Name())
+	}
+
+	expectedFlags := []string{
+		"type", "host", "port", "username", "password",
+		"database", "ssh-jump", "create-db", "drop-existing", "yes",
+	}
+
+	for _, flagName := range expectedFlags {
+		if cmd.Flags().Lookup(flagName) == nil {
+			t.Errorf("Expected flag '%s' to exist", flagName)
+		}
+	}
+
+	if def := cmd.Flags().Lookup("create-db").DefValue; def != "false" {
+		t.Errorf("Expected default create-db 'false', got '%s'", def)
+	}
+}
+
+func TestDBRestoreCmdRequiresFile(t *testing.T) {
+	cmd := newDBRestoreCmd()
+	cmd.SetArgs([]string{"--database", "testdb", "--username", "test"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err == nil {
+		t.Error("Expected error when no backup file is given")
+	}
+}
+
+func TestDBRestoreCmdCreateAndDropExclusive(t *testing.T) {
+	cmd := newDBRestoreCmd()
+	cmd.SetArgs([]string{
+		"backup.sql", "--database", "testdb", "--username", "test",
+		"--create-db", "--drop-existing",
+	})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err == nil {
+		t.Error("Expected error when --create-db and --drop-existing are combined")
+	}
+}
+
+func TestRunDBRestoreInvalidType(t *testing.T) {
+	err := runDBRestore(restoreOptions{connectionOptions: connectionOptions{dbType: "invalid", database: "testdb"}, input: "backup.sql"})
+
+	if err == nil {
+		t.Fatal("Expected error for invalid database type, got nil")
+	}
+
+	expectedError := "unsupported database type: invalid"
+	if err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%s'", expectedError, err.Error())
+	}
+}
+
+func TestRunDBRestoreMissingFile(t *testing.T) {
+	err := runDBRestore(restoreOptions{
+		connectionOptions: connectionOptions{dbType: "postgres", database: "testdb"},
+		input:             filepath.Join(t.TempDir(), "missing.sql"),
+	})
+
+	if err == nil || !strings.Contains(err.Error(), "failed to open backup file") {
+		t.Errorf("Expected open error, got %v", err)
+	}
+}
+
+func TestRunDBRestoreDirectoryDump(t *testing.T) {
+	err := runDBRestore(restoreOptions{connectionOptions: connectionOptions{dbType: "postgres", database: "testdb"}, input: "orders_20240101_120000.dir.tar"})
+
+	if err == nil || !strings.Contains(err.Error(), "pg_restore --format=directory") {
+		t.Errorf("Expected directory-format error, got %v", err)
+	}
+}
+
+func TestRunDBRestoreArchiveForMySQL(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "orders.dump")
+	os.WriteFile(input, []byte("PGDMP\x01\x0e\x00"), 0600)
+
+	err := runDBRestore(restoreOptions{connectionOptions: connectionOptions{dbType: "mysql", database: "testdb"}, input: input})
+	if err == nil || !strings.Contains(err.Error(), "custom-format dump, which MySQL cannot restore") {
+		t.Errorf("Expected unsupported format error, got %v", err)
+	}
+}
+
+func TestRunDBRestoreRedisSnapshot(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "cache_20240101_120000.rdb")
+	os.WriteFile(input, []byte("REDIS0011\xfa"), 0600)
+
+	err := runDBRestore(restoreOptions{connectionOptions: connectionOptions{dbType: "redis"}, input: input})
+	if err == nil || !strings.Contains(err.Error(), "copy it to the server's data directory as dump.rdb") {
+		t.Errorf("Expected snapshot restore error, got %v", err)
+	}
+}
+
+func TestRunDBRestoreSQLiteFile(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "data_20240101_120000.sqlite")
+	os.WriteFile(input, []byte("SQLite format 3\x00\x10\x00"), 0600)
+
+	err := runDBRestore(restoreOptions{connectionOptions: connectionOptions{dbType: "sqlite"}, input: input})
+	if err == nil || !strings.Contains(err.Error(), "copy it over the database while the application is stopped") {
+		t.Errorf("Expected SQLite restore error, got %v", err)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		assumeYes bool
+		want      bool
+	}{
+		{"Yes", "y\n", false, true},
+		{"Full yes", "YES\n", false, true},
+		{"No", "n\n", false, false},
+		{"Empty answer", "\n", false, false},
+		{"No input", "", false, false},
+		{"Assume yes", "", true, true},
+	}
+
+	// Silence the prompt text
+	stdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer func() { os.Stdout = stdout }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := confirm(strings.NewReader(tt.input), tt.assumeYes, "Continue?")
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRestoreWithDriverCreateDBConfirmsExistingTables(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "restored")
+	fakeTools(t, map[string]string{
+		"pg_dump":    "echo 'pg_dump (PostgreSQL) 16.2'",
+		"pg_restore": "exit 0",
+		"psql": `case "$*" in
+  *information_schema.tables*) echo 3 ;;
+  *pg_database*) echo 1 ;;
+  *server_version*) echo 16.2 ;;
+  *) cat > ` + log + ` ;;
+esac`,
+	})
+
+	// Answer no to the prompt and silence its text
+	answers, err := os.CreateTemp(t.TempDir(), "stdin")
+	if err != nil {
+		t.Fatalf("Failed to create stdin: %v", err)
+	}
+	answers.WriteString("n\n")
+	answers.Seek(0, io.SeekStart)
+	stdin, stdout := os.Stdin, os.Stdout
+	os.Stdin = answers
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer func() { os.Stdin, os.Stdout = stdin, stdout }()
+
+	opts := restoreOptions{createDB: true, connectionOptions: connectionOptions{dbType: "postgres",
+		host: "localhost", port: 5432, username: "app", database: "orders", runtime: "local"}}
+	err = restoreWithDriver(context.Background(), postgresDriver{}, opts, strings.NewReader("SELECT 1;"), "plain")
+	if err == nil || err.Error() != "restore aborted by user" {
+		t.Errorf("Expected the restore into a non-empty database to be refused, got %v", err)
+	}
+	if _, err := os.Stat(log); err == nil {
+		t.Error("Expected nothing restored without confirmation")
+	}
+
+	opts.yes = true
+	if err := restoreWithDriver(context.Background(), postgresDriver{}, opts, strings.NewReader("SELECT 1;"), "plain"); err != nil {
+		t.Fatalf("Expected --yes to restore, got %v", err)
+	}
+	if data, _ := os.ReadFile(log); string(data) != "SELECT 1;" {
+		t.Errorf("Expected the dump restored, got %q", data)
+	}
+}
+
+func TestConfirmUnlessCanceled(t *testing.T) {
+	// A prompt nobody answers
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	defer writer.Close()
+	stdin, stdout := os.Stdin, os.Stdout
+	os.Stdin = reader
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer func() { os.Stdin, os.Stdout = stdin, stdout }()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	time.AfterFunc(100*time.Millisecond, func() { cancel(ErrCanceled) })
+	ok, err := confirmUnlessCanceled(ctx, false, "Continue?")
+	if ok || !errors.Is(err, ErrCanceled) {
+		t.Errorf("Expected the prompt to give up on cancel, got %v, %v", ok, err)
+	}
+}
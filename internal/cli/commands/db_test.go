@@ -20,20 +20,32 @@ func TestNewDBCmd(t *testing.T) {
 	}
 
 	// Check that subcommands are added
-	if len(cmd.Commands()) != 2 {
-		t.Errorf("Expected 2 subcommands, got %d", len(cmd.Commands()))
+	if len(cmd.Commands()) != 5 {
+		t.Errorf("Expected 5 subcommands, got %d", len(cmd.Commands()))
 	}
 
 	// Verify subcommands exist
 	hasBackup := false
 	hasList := false
+	hasRestore := false
+	hasVerify := false
+	hasPrune := false
 	for _, subcmd := range cmd.Commands() {
 		if subcmd.Use == "backup" {
 			hasBackup = true
 		}
-		if subcmd.Use == "list" {
+		if subcmd.Name() == "list" {
 			hasList = true
 		}
+		if subcmd.Name() == "restore" {
+			hasRestore = true
+		}
+		if subcmd.Name() == "verify" {
+			hasVerify = true
+		}
+		if subcmd.Name() == "prune" {
+			hasPrune = true
+		}
 	}
 
 	if !hasBackup {
@@ -42,6 +54,15 @@ func TestNewDBCmd(t *testing.T) {
 	if !hasList {
 		t.Error("Expected 'list' subcommand to exist")
 	}
+	if !hasRestore {
+		t.Error("Expected 'restore' subcommand to exist")
+	}
+	if !hasVerify {
+		t.Error("Expected 'verify' subcommand to exist")
+	}
+	if !hasPrune {
+		t.Error("Expected 'prune' subcommand to exist")
+	}
 }
 
 func TestNewDBBackupCmd(t *testing.T) {
@@ -57,7 +78,9 @@ func TestNewDBBackupCmd(t *testing.T) {
 	// Test that flags exist
 	expectedFlags := []string{
 		"type", "host", "port", "username", "password",
-		"database", "output", "compress", "ssh-jump",
+		"database", "path", "output", "compress", "ssh-jump", "progress",
+		"client-image", "runtime", "pull-policy", "memory", "cpus",
+		"container", "docker-network",
 	}
 
 	for _, flagName := range expectedFlags {
@@ -79,8 +102,8 @@ func TestNewDBBackupCmd(t *testing.T) {
 	}
 
 	portFlag := flags.Lookup("port")
-	if portFlag.DefValue != "5432" {
-		t.Errorf("Expected default port '5432', got '%s'", portFlag.DefValue)
+	if portFlag.DefValue != "0" {
+		t.Errorf("Expected default port '0' (engine default), got '%s'", portFlag.DefValue)
 	}
 
 	compressFlag := flags.Lookup("compress")
@@ -92,11 +115,11 @@ func TestNewDBBackupCmd(t *testing.T) {
 func TestNewDBListCmd(t *testing.T) {
 	cmd := newDBListCmd()
 
-	if cmd.Use != "list" {
-		t.Errorf("Expected Use 'list', got '%s'", cmd.Use)
+	if cmd.Use != "list [location]" {
+		t.Errorf("Expected Use 'list [location]', got '%s'", cmd.Use)
 	}
 
-	if cmd.Short != "List backup files in current directory" {
+	if cmd.Short != "List backup files in a directory or bucket prefix" {
 		t.Errorf("Expected Short description, got '%s'", cmd.Short)
 	}
 }
@@ -151,7 +174,17 @@ func TestDBListCmdWithFiles(t *testing.T) {
 }
 
 func TestRunDBBackupInvalidType(t *testing.T) {
-	err := runDBBackup("invalid", "localhost", 5432, "user", "pass", "testdb", "", true, "")
+	err := runDBBackup(backupOptions{
+		connectionOptions: connectionOptions{
+			dbType:   "invalid",
+			host:     "localhost",
+			port:     5432,
+			username: "user",
+			password: "pass",
+			database: "testdb",
+		},
+		compress: true,
+	})
 
 	if err == nil {
 		t.Error("Expected error for invalid database type, got nil")
@@ -226,38 +259,6 @@ func TestDBCommandStructure(t *testing.T) {
 	}
 }
 
-func TestFindAvailablePort(t *testing.T) {
-	port, err := findAvailablePort()
-
-	if err != nil {
-		t.Fatalf("Expected no error finding available port, got: %v", err)
-	}
-
-	if port <= 0 || port > 65535 {
-		t.Errorf("Expected valid port number (1-65535), got: %d", port)
-	}
-}
-
-func TestFindAvailablePortUniqueness(t *testing.T) {
-	// Call findAvailablePort multiple times and ensure we get different ports
-	ports := make(map[int]bool)
-
-	for i := 0; i < 5; i++ {
-		port, err := findAvailablePort()
-		if err != nil {
-			t.Fatalf("Expected no error, got: %v", err)
-		}
-		ports[port] = true
-	}
-
-	// We should have gotten at least a few different ports
-	// (Note: it's theoretically possible to get the same port twice if it's released
-	// and immediately reassigned, but highly unlikely in practice)
-	if len(ports) < 3 {
-		t.Logf("Warning: Got fewer unique ports than expected: %d", len(ports))
-	}
-}
-
 func TestCreateSSHTunnelEmptyJumpHost(t *testing.T) {
 	_, err := createSSHTunnel("", "localhost", 5432)
 
@@ -327,3 +328,22 @@ func TestSSHJumpFlagDefault(t *testing.T) {
 		t.Errorf("Expected empty default ssh-jump value, got '%s'", sshJump)
 	}
 }
+
+func TestConnectionClientOptions(t *testing.T) {
+	opts := connectionOptions{sshJump: "bastion", runtime: "auto"}
+	if got := opts.clientOptions(postgresDriver{}); got != (clientOptions{sshJump: "bastion", runtime: "auto", image: "postgres:15-alpine"}) {
+		t.Errorf("Unexpected client options %+v", got)
+	}
+
+	opts.clientImage = "registry.local/postgres:16"
+	opts.images = clientImages{override: opts.clientImage}
+	if got := opts.clientOptions(postgresDriver{}); got.runtime != "auto" || !got.forceContainer || got.image != "registry.local/postgres:16" {
+		t.Errorf("Expected --client-image to select a container of any engine, got %+v", got)
+	}
+
+	opts.clientImage, opts.images = "", clientImages{}
+	opts.container.network = "shop_default"
+	if got := opts.clientOptions(postgresDriver{}); got.runtime != "auto" || !got.forceContainer {
+		t.Errorf("Expected --docker-network to select a container of any engine, got %+v", got)
+	}
+}
@@ -0,0 +1,385 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// dockerBinary is the docker CLI, which dockerClient runs unless told
+// to use another container CLI
+var dockerBinary = "docker"
+
+// passwordEncoder is implemented by drivers whose client tools need the
+// password in the environment in another form than the plain value
+type passwordEncoder interface {
+	encodePassword(password string) string
+}
+
+// dumpClient runs a driver's client tools: in a container next to the
+// network, or on the host holding a file database
+type dumpClient interface {
+	// query runs a client command and returns its trimmed stdout
+	query(args []string) (string, error)
+	// run executes a client command with stdin attached to input
+	run(args []string, input io.Reader) error
+	// dump runs a client command with its stdout connected to stdout
+	dump(args []string, stdout io.Writer) error
+	// withImage returns the client running later commands from image
+	withImage(image string) dumpClient
+	// clientImage returns the image commands run from, or "" when the
+	// tools are not containerized
+	clientImage() string
+}
+
+// dockerClient runs database client tools inside a throwaway container.
+// The password is handed to docker through a private --env-file rather
+// than the command line.
+type dockerClient struct {
+	// binary is the container CLI; empty means dockerBinary
+	binary  string
+	image   string
+	opts    []string
+	envFile string
+	// ctx, when set, stops running containers once it is canceled
+	ctx context.Context
+}
+
+// containerSpec is how a client container is set up apart from its image
+// and command
+type containerSpec struct {
+	// network is the network mode, such as host or none; empty is the
+	// engine's default
+	network string
+	// addHosts are extra host:address lines for the container's /etc/hosts
+	addHosts []string
+	// noEntrypoint clears the image's entrypoint so the command runs as given
+	noEntrypoint bool
+	// mounts are directories bind-mounted at the same path
+	mounts []string
+	// memory is a limit in bytes and cpus a number of CPUs; 0 is unlimited
+	memory int64
+	cpus   float64
+}
+
+// cliArgs renders s as container CLI run options
+func (s containerSpec) cliArgs() []string {
+	var args []string
+	if s.network != "" {
+		args = append(args, "--network", s.network)
+	}
+	for _, host := range s.addHosts {
+		args = append(args, "--add-host="+host)
+	}
+	if s.noEntrypoint {
+		args = append(args, "--entrypoint=")
+	}
+	for _, dir := range s.mounts {
+		args = append(args, "--mount", "type=bind,"+csvField("source="+dir)+","+csvField("target="+dir))
+	}
+	if s.memory > 0 {
+		args = append(args, "--memory", strconv.FormatInt(s.memory, 10))
+	}
+	if s.cpus > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(s.cpus, 'f', -1, 64))
+	}
+	return args
+}
+
+// csvField quotes one field of a --mount value, which is parsed as CSV, so
+// commas and quotes in a path stay part of it
+func csvField(field string) string {
+	return `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+}
+
+// clientOptions selects where a driver's client tools run
+type clientOptions struct {
+	sshJump string
+	// runtime is a --runtime value
+	runtime string
+	// forceContainer skips local tools under --runtime auto while still
+	// detecting the container engine
+	forceContainer bool
+	// image is the client image containers start from
+	image string
+	// execContainer is a running container to exec the tools in instead
+	execContainer string
+	// container holds the network, pull policy and resource limits of
+	// client containers
+	container containerSettings
+}
+
+// check rejects --container and --docker-network where they cannot work
+func (o clientOptions) check() error {
+	switch {
+	case o.execContainer != "" && o.container.network != "":
+		return fmt.Errorf("--container and --docker-network cannot be combined")
+	case (o.execContainer != "" || o.container.network != "") && o.sshJump != "":
+		return fmt.Errorf("--container and --docker-network cannot be combined with --ssh-jump; point DOCKER_HOST at the remote engine instead")
+	case (o.execContainer != "" || o.container.network != "") && o.runtime == "local":
+		return fmt.Errorf("--container and --docker-network need a container runtime, not --runtime local")
+	}
+	return nil
+}
+
+// openClient prepares a client for d, opening an SSH tunnel through
+// opts.sshJump when set. With opts.execContainer the tools run inside that
+// container instead. Depending on opts.runtime the tools run from the
+// local PATH or in containers of the detected container runtime, which
+// start from opts.image until the client is switched with withImage; the
+// Docker engine is driven through its API when its socket answers. File
+// databases behind sshJump are instead read by tools running on the last
+// hop. The returned params address the database from where the tools run,
+// and cleanup closes the SSH connection and removes the env file. Canceling
+// ctx stops any running client tool.
+func openClient(ctx context.Context, d BackupDriver, p ConnParams, opts clientOptions) (dumpClient, ConnParams, func(), error) {
+	if opts.execContainer != "" {
+		rt, err := detectContainerRuntime(opts.runtime)
+		if err != nil {
+			return nil, p, nil, err
+		}
+		client, err := openContainerExec(ctx, d, p, rt, opts.execContainer)
+		if err != nil {
+			return nil, p, nil, err
+		}
+		fmt.Printf("Using %s tools in %s container %s (%s)...\n", d.DisplayName(), rt.displayName(), opts.execContainer, client.version)
+		return client, p, func() {}, nil
+	}
+
+	file, isFile := d.(fileDriver)
+	if isFile && opts.container.network != "" {
+		return nil, p, nil, fmt.Errorf("--docker-network does not apply to %s files", d.DisplayName())
+	}
+	if isFile && opts.sshJump != "" {
+		host, err := openSSHHost(ctx, opts.sshJump)
+		if err != nil {
+			return nil, p, nil, fmt.Errorf("SSH connection failed: %v", err)
+		}
+		fmt.Printf("Using %s tools on the remote host...\n", d.DisplayName())
+		return host, p, host.close, nil
+	}
+
+	if isFile {
+		path, err := filepath.Abs(p.Path)
+		if err != nil {
+			return nil, p, nil, fmt.Errorf("invalid --path: %v", err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, p, nil, fmt.Errorf("cannot read database file: %v", err)
+		}
+		p.Path = path
+	}
+
+	var tunnel *sshTunnel
+	if opts.sshJump != "" {
+		if strings.Contains(p.Host, ",") {
+			return nil, p, nil, fmt.Errorf("--ssh-jump needs a single host, got %s; name one replica set member", p.Host)
+		}
+
+		var err error
+		tunnel, err = createSSHTunnel(opts.sshJump, p.Host, p.Port)
+		if err != nil {
+			return nil, p, nil, fmt.Errorf("SSH tunnel failed: %v", err)
+		}
+		p.Host = "127.0.0.1"
+		p.Port = tunnel.localPort
+		p.Tunneled = true
+	}
+	closeTunnel := func() {
+		if tunnel != nil {
+			tunnel.close()
+		}
+	}
+
+	var localErr error
+	if (opts.runtime == "auto" && !opts.forceContainer) || opts.runtime == "local" {
+		local, err := openLocalClient(ctx, d, p)
+		switch {
+		case err == nil:
+			fmt.Printf("Using local %s client (%s)...\n", d.DisplayName(), local.version)
+			return local, p, closeTunnel, nil
+		case opts.runtime == "local":
+			closeTunnel()
+			return nil, p, nil, fmt.Errorf("--runtime local: %v", err)
+		case !errors.Is(err, errLocalToolsMissing):
+			fmt.Fprintf(os.Stderr, "Warning: %v; using a container instead\n", err)
+		}
+		localErr = err
+	}
+
+	rt, err := detectContainerRuntime(opts.runtime)
+	if err != nil {
+		closeTunnel()
+		if localErr != nil {
+			return nil, p, nil, fmt.Errorf("%v and the local client cannot be used: %v", err, localErr)
+		}
+		return nil, p, nil, err
+	}
+
+	var spec containerSpec
+	switch {
+	case isFile:
+		spec = file.containerOptions(p)
+	case opts.container.network != "":
+		// The database is addressed by its name on that network
+		spec.network = opts.container.network
+	default:
+		spec, p = rt.network(p)
+	}
+	spec.memory, _ = parseMemory(opts.container.memory)
+	spec.cpus = opts.container.cpus
+
+	password := p.Password
+	if encoder, ok := d.(passwordEncoder); ok && password != "" {
+		password = encoder.encodePassword(password)
+	}
+
+	fmt.Printf("Using %s %s client...\n", rt.displayName(), d.DisplayName())
+
+	if rt.engine != nil {
+		client := newEngineClient(ctx, rt.engine, opts.image, spec, opts.container.pullPolicy)
+		if password != "" {
+			client.env = []string{d.PasswordEnv() + "=" + password}
+		}
+		return client, p, closeTunnel, nil
+	}
+
+	client := dockerClient{binary: rt.binary, image: opts.image, opts: spec.cliArgs(), ctx: ctx}
+	if opts.container.pullPolicy != "" {
+		client.opts = append(client.opts, "--pull="+opts.container.pullPolicy)
+	}
+	if password != "" {
+		envFile, err := writeEnvFile(d.PasswordEnv(), password)
+		if err != nil {
+			closeTunnel()
+			return nil, p, nil, err
+		}
+		client.envFile = envFile
+	}
+
+	cleanup := func() {
+		closeTunnel()
+		if client.envFile != "" {
+			os.Remove(client.envFile)
+		}
+	}
+
+	return client, p, cleanup, nil
+}
+
+// cli returns the container CLI c runs
+func (c dockerClient) cli() string {
+	if c.binary == "" {
+		return dockerBinary
+	}
+	return c.binary
+}
+
+func (c dockerClient) command(interactive bool, args []string) *exec.Cmd {
+	dockerArgs := []string{"run", "--rm"}
+	if interactive {
+		dockerArgs = append(dockerArgs, "-i")
+	}
+
+	// A named container can be removed when ctx is canceled
+	var name string
+	if c.ctx != nil {
+		name = containerName()
+		dockerArgs = append(dockerArgs, "--name", name)
+	}
+
+	dockerArgs = append(dockerArgs, c.opts...)
+	if c.envFile != "" {
+		dockerArgs = append(dockerArgs, "--env-file", c.envFile)
+	}
+	dockerArgs = append(dockerArgs, c.image)
+	dockerArgs = append(dockerArgs, args...)
+
+	if c.ctx == nil {
+		return exec.Command(c.cli(), dockerArgs...)
+	}
+
+	cmd := exec.CommandContext(c.ctx, c.cli(), dockerArgs...)
+	cmd.Cancel = func() error {
+		// Killing the docker CLI alone would leave the dump running in
+		// the container, so remove the container first
+		exec.Command(c.cli(), "rm", "--force", name).Run()
+		return cmd.Process.Kill()
+	}
+	return cmd
+}
+
+// containerName returns a unique name for a client container
+func containerName() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return "cutter-" + hex.EncodeToString(b)
+}
+
+// query runs a client command and returns its trimmed stdout
+func (c dockerClient) query(args []string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := c.command(false, args)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// run executes a client command with stdin attached to input
+func (c dockerClient) run(args []string, input io.Reader) error {
+	cmd := c.command(true, args)
+	cmd.Stdin = input
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// withImage returns a copy of c running image
+func (c dockerClient) withImage(image string) dumpClient {
+	c.image = image
+	return c
+}
+
+// clientImage returns the image c runs
+func (c dockerClient) clientImage() string {
+	return c.image
+}
+
+// dump runs a client command with its stdout connected to stdout
+func (c dockerClient) dump(args []string, stdout io.Writer) error {
+	cmd := c.command(false, args)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// parseSizeResult parses the byte count printed by a size query
+func parseSizeResult(out string) (int64, error) {
+	var n int64
+	if _, err := fmt.Sscan(strings.TrimSpace(out), &n); err != nil {
+		return 0, fmt.Errorf("unexpected size %q", out)
+	}
+	return n, nil
+}
+
+// parseCount parses the single integer printed by a count query
+func parseCount(out string) (int, error) {
+	var n int
+	if _, err := fmt.Sscan(strings.TrimSpace(out), &n); err != nil {
+		return 0, fmt.Errorf("unexpected count %q", out)
+	}
+	return n, nil
+}
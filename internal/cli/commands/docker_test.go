@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDockerClientCommand(t *testing.T) {
+	client := dockerClient{
+		image:   "postgres:15-alpine",
+		opts:    []string{"--network", "host"},
+		envFile: "/tmp/cutter-env-123",
+	}
+
+	cmd := client.command(true, []string{"psql", "-d", "my db"})
+
+	want := []string{"docker", "run", "--rm", "-i", "--network", "host", "--env-file", "/tmp/cutter-env-123",
+		"postgres:15-alpine", "psql", "-d", "my db"}
+	if strings.Join(cmd.Args, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected args %v, got %v", want, cmd.Args)
+	}
+}
+
+func TestDockerClientCommandNamesCancelableContainers(t *testing.T) {
+	client := dockerClient{image: "mysql:8", ctx: context.Background()}
+
+	args := client.command(false, []string{"mysql"}).Args
+	if len(args) < 5 || args[3] != "--name" || !strings.HasPrefix(args[4], "cutter-") {
+		t.Fatalf("Expected a --name cutter-... option, got %v", args)
+	}
+	if other := client.command(false, []string{"mysql"}).Args[4]; other == args[4] {
+		t.Errorf("Expected a new container name per command, got %s twice", other)
+	}
+}
+
+func TestDockerClientCommandWithoutPassword(t *testing.T) {
+	client := dockerClient{image: "mysql:8"}
+
+	cmd := client.command(false, []string{"mysql"})
+
+	for _, arg := range cmd.Args {
+		if arg == "--env-file" {
+			t.Errorf("Expected no env file without a password, got %v", cmd.Args)
+		}
+	}
+}
+
+func TestContainerSpecCLIArgs(t *testing.T) {
+	spec := containerSpec{addHosts: []string{"host.docker.internal:host-gateway"}, memory: 512 << 20, cpus: 1.5}
+	want := "--add-host=host.docker.internal:host-gateway --memory 536870912 --cpus 1.5"
+	if got := strings.Join(spec.cliArgs(), " "); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if got := (containerSpec{}).cliArgs(); len(got) != 0 {
+		t.Errorf("Expected no options, got %v", got)
+	}
+}
+
+func TestParseSizeResult(t *testing.T) {
+	if got, err := parseSizeResult(" 8589934592\n"); err != nil || got != 8<<30 {
+		t.Errorf("Expected 8589934592, got %d (%v)", got, err)
+	}
+	if _, err := parseSizeResult("NULL"); err == nil {
+		t.Error("Expected an error for a non-numeric size")
+	}
+}
+
+func TestParseCount(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{" 42\n", 42, false},
+		{"", 0, true},
+		{"abc", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseCount(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseCount(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseCount(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConnParams describes how to reach a database server
+type ConnParams struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	Database string
+	// SSLMode is a libpq style TLS mode (disable ... verify-full); empty
+	// leaves the client tool's default
+	SSLMode string
+	// AuthDatabase and ReplicaSet are MongoDB's authentication database
+	// and replica set name; Host may then list several seed hosts
+	AuthDatabase string
+	ReplicaSet   string
+	// Path is the database file of file-based engines such as SQLite
+	Path string
+	// Tunneled is set when Host and Port point at an SSH tunnel rather
+	// than the server itself
+	Tunneled bool
+}
+
+// DumpFormat selects the dump tool's output format
+type DumpFormat struct {
+	// Name is plain, custom, directory, tar, archive or rdb
+	Name string
+	// Jobs is the number of parallel dump workers (directory format)
+	Jobs int
+	// Compress asks formats that compress internally to do so; plain and
+	// tar dumps are compressed by cutter instead
+	Compress bool
+}
+
+// queryFunc runs a client command inside the driver's client image and
+// returns its trimmed stdout
+type queryFunc func(args []string) (string, error)
+
+// BackupDriver knows how to dump and restore one database engine.
+// Drivers register themselves from an init function in their own file,
+// and the db commands derive their flags and validation from the registry.
+type BackupDriver interface {
+	// Name is the value accepted by --type
+	Name() string
+	// DisplayName is the human readable engine name
+	DisplayName() string
+	DefaultPort() int
+	ClientImage() string
+	// Formats are the --format values the dump tool supports; the first is the default
+	Formats() []string
+	// PasswordEnv is the environment variable the client tools read the password from
+	PasswordEnv() string
+	// StoredPassword looks p up in the engine's own credential file
+	StoredPassword(p ConnParams) (string, bool)
+	// ResolveFilters checks f against the engine and expands any patterns
+	// its dump tool cannot match itself into concrete table names
+	ResolveFilters(query queryFunc, p ConnParams, f DumpFilters) (DumpFilters, error)
+	// DumpCommand returns the argv that writes a dump of p.Database,
+	// narrowed by f, to stdout in format. A directory-format dump is
+	// written to stdout as a tar stream of the directory.
+	DumpCommand(p ConnParams, f DumpFilters, format DumpFormat) []string
+	// RestoreCommand returns the argv that replays a dump in format read from stdin
+	RestoreCommand(p ConnParams, format string) []string
+	// ServerVersion returns the version reported by the server
+	ServerVersion(query queryFunc, p ConnParams) (string, error)
+	// DatabaseSize estimates the size of p.Database in bytes
+	DatabaseSize(query queryFunc, p ConnParams) (int64, error)
+	// CountTables returns the number of user tables in p.Database
+	CountTables(query queryFunc, p ConnParams) (int, error)
+	// CreateDatabase creates p.Database, dropping it first when drop is set
+	CreateDatabase(query queryFunc, p ConnParams, drop bool) error
+}
+
+// flagRequirer is implemented by drivers that need other connection flags
+// than the database and username every other engine requires
+type flagRequirer interface {
+	requiredFlags() []string
+}
+
+// requiredFlags returns the connection flags d cannot work without
+func requiredFlags(d BackupDriver) []string {
+	if r, ok := d.(flagRequirer); ok {
+		return r.requiredFlags()
+	}
+	return []string{"database", "username"}
+}
+
+// fileDriver is implemented by drivers for databases that are a single
+// file rather than a server. Behind --ssh-jump their tools run on the last
+// hop, which holds the file; otherwise in a container that mounts it.
+type fileDriver interface {
+	// containerOptions sets up the client container with access to
+	// p.Path, which is absolute
+	containerOptions(p ConnParams) containerSpec
+}
+
+var drivers = map[string]BackupDriver{}
+
+// registerDriver makes a driver available to the db commands
+func registerDriver(d BackupDriver) {
+	if _, exists := drivers[d.Name()]; exists {
+		panic(fmt.Sprintf("driver %q registered twice", d.Name()))
+	}
+	drivers[d.Name()] = d
+}
+
+// lookupDriver returns the registered driver for dbType
+func lookupDriver(dbType string) (BackupDriver, error) {
+	d, ok := drivers[dbType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+	return d, nil
+}
+
+// driverNames returns the registered driver names in sorted order
+func driverNames() []string {
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// typeFlagUsage builds the --type help text from the registered drivers
+func typeFlagUsage() string {
+	return fmt.Sprintf("Database type (%s)", strings.Join(driverNames(), ", "))
+}
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+)
+
+func init() {
+	registerDriver(mysqlDriver{mariadb: true})
+}
+
+// mariadbSSLOptions translates libpq style TLS modes to MariaDB client
+// options, which have no --ssl-mode
+var mariadbSSLOptions = map[string][]string{
+	"disable":     {"--skip-ssl"},
+	"require":     {"--ssl"},
+	"verify-ca":   {"--ssl", "--ssl-verify-server-cert"},
+	"verify-full": {"--ssl", "--ssl-verify-server-cert"},
+}
+
+// isMariaDBVersion reports whether a VERSION() result comes from MariaDB,
+// such as 10.11.6-MariaDB-1:10.11.6+maria~ubu2204
+func isMariaDBVersion(serverVersion string) bool {
+	return strings.Contains(strings.ToLower(serverVersion), "mariadb")
+}
+
+// mariadbImageVersion picks the mariadb image of the server's
+// major.minor release. Releases before 10.5 lack the mariadb-dump name,
+// so they keep the default image, which dumps them as well.
+func mariadbImageVersion(serverVersion string) (string, string, bool) {
+	if !isMariaDBVersion(serverVersion) {
+		return "", "", false
+	}
+	major, minor, ok := parseVersion(serverVersion)
+	if !ok || major*100+minor < 1005 {
+		return "", "", false
+	}
+	version := fmt.Sprintf("%d.%d", major, minor)
+	return version, "mariadb:" + version, true
+}
@@ -0,0 +1,266 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	registerDriver(mongoDriver{})
+}
+
+// mongoOptions are the connection settings only MongoDB has
+type mongoOptions struct {
+	authDatabase string
+	replicaSet   string
+}
+
+// addMongoFlags registers --auth-database and --replica-set
+func addMongoFlags(cmd *cobra.Command, opts *mongoOptions) {
+	cmd.Flags().StringVar(&opts.authDatabase, "auth-database", "", "MongoDB database holding the user's credentials (default: admin)")
+	cmd.Flags().StringVar(&opts.replicaSet, "replica-set", "", "MongoDB replica set name; --host may then list several seed hosts")
+}
+
+// check rejects the MongoDB settings for other engines
+func (o mongoOptions) check(d BackupDriver) error {
+	if d.Name() == "mongodb" {
+		return nil
+	}
+	if o.authDatabase != "" {
+		return fmt.Errorf("--auth-database is only supported for MongoDB")
+	}
+	if o.replicaSet != "" {
+		return fmt.Errorf("--replica-set is only supported for MongoDB")
+	}
+	return nil
+}
+
+// mongoDriver dumps with mongodump --archive and restores with mongorestore.
+// The connection is passed as a password-less --uri; the password travels
+// through the environment and a private --config file written by a wrapper
+// shell, so it never appears in an argv.
+type mongoDriver struct{}
+
+func (mongoDriver) Name() string        { return "mongodb" }
+func (mongoDriver) DisplayName() string { return "MongoDB" }
+func (mongoDriver) DefaultPort() int    { return 27017 }
+func (mongoDriver) ClientImage() string { return "mongo:7" }
+func (mongoDriver) PasswordEnv() string { return "CUTTER_MONGO_PASSWORD" }
+func (mongoDriver) Formats() []string   { return []string{"archive"} }
+
+// StoredPassword always misses: MongoDB has no client credential file
+func (mongoDriver) StoredPassword(p ConnParams) (string, bool) {
+	return "", false
+}
+
+// encodePassword stores the password as a JSON string, which is also a
+// valid YAML scalar for the --config file and parses with JSON.parse in
+// mongosh
+func (mongoDriver) encodePassword(password string) string {
+	encoded, _ := json.Marshal(password)
+	return string(encoded)
+}
+
+// ResolveFilters expands the collection patterns, since mongodump only
+// takes exact names, and turns an include list into excludes because
+// mongodump dumps at most one --collection
+func (d mongoDriver) ResolveFilters(query queryFunc, p ConnParams, f DumpFilters) (DumpFilters, error) {
+	switch {
+	case f.SchemaOnly || f.DataOnly:
+		return f, fmt.Errorf("--schema-only and --data-only are not supported for MongoDB")
+	case len(f.Schemas) > 0:
+		return f, fmt.Errorf("--schema is not supported for MongoDB")
+	case len(f.IncludeTables) == 0 && len(f.ExcludeTables) == 0:
+		return f, nil
+	}
+
+	collections, err := d.listCollections(query, p)
+	if err != nil {
+		return f, fmt.Errorf("failed to list collections: %v", err)
+	}
+
+	resolved := f
+	resolved.ExcludeTables = matchTables(collections, f.ExcludeTables)
+	if len(f.IncludeTables) > 0 {
+		included := matchTables(collections, f.IncludeTables)
+		if len(included) == 0 {
+			return f, fmt.Errorf("no collections in %s match --include-table %s", p.Database, strings.Join(f.IncludeTables, ", "))
+		}
+		resolved.IncludeTables = nil
+		for _, collection := range collections {
+			if len(matchTables([]string{collection}, included)) == 0 {
+				resolved.ExcludeTables = append(resolved.ExcludeTables, collection)
+			}
+		}
+	}
+	return resolved, nil
+}
+
+func (d mongoDriver) DumpCommand(p ConnParams, f DumpFilters, format DumpFormat) []string {
+	args := []string{"mongodump", "--uri=" + mongoURI(p), "--db=" + p.Database, "--archive"}
+	for _, collection := range f.ExcludeTables {
+		args = append(args, "--excludeCollection="+collection)
+	}
+	return d.withPassword(p, args)
+}
+
+// RestoreCommand renames every namespace in the archive into p.Database,
+// so a dump can be restored under a different database name
+func (d mongoDriver) RestoreCommand(p ConnParams, format string) []string {
+	target := strings.NewReplacer(`\`, `\\`, `$`, `\$`).Replace(p.Database)
+	return d.withPassword(p, []string{"mongorestore", "--uri=" + mongoURI(p), "--archive",
+		"--nsFrom=$db$.$collection$", "--nsTo=" + target + ".$collection$"})
+}
+
+func (d mongoDriver) ServerVersion(query queryFunc, p ConnParams) (string, error) {
+	return query(d.eval(p, "print(db.version())"))
+}
+
+// imageVersion picks the image of the server's major version. Images
+// before 6 lack mongosh, so older servers keep the default image.
+func (mongoDriver) imageVersion(serverVersion string) (string, string, bool) {
+	major, _, ok := parseVersion(serverVersion)
+	if !ok || major < 6 {
+		return "", "", false
+	}
+	version := strconv.Itoa(major)
+	return version, "mongo:" + version, true
+}
+
+func (mongoDriver) localTools() []string {
+	return []string{"mongodump", "mongosh", "mongorestore"}
+}
+
+// localToolFits accepts the database tools from 100.0 on, which are
+// released separately and support every server; older tools shipped with
+// the server and must be at least its release
+func (mongoDriver) localToolFits(tool, server string) bool {
+	version := toolVersion(tool)
+	if versionAtLeast(version, 100, 0) {
+		return true
+	}
+	major, minor, ok := parseVersion(server)
+	return ok && versionAtLeast(version, major, minor)
+}
+
+func (d mongoDriver) CountTables(query queryFunc, p ConnParams) (int, error) {
+	collections, err := d.listCollections(query, p)
+	if err != nil {
+		return 0, err
+	}
+	return len(collections), nil
+}
+
+func (d mongoDriver) DatabaseSize(query queryFunc, p ConnParams) (int64, error) {
+	out, err := query(d.eval(p, "print(db.stats().dataSize)"))
+	if err != nil {
+		return 0, err
+	}
+	return parseSizeResult(out)
+}
+
+// CreateDatabase only has work to do when dropping: MongoDB creates a
+// database with its first collection
+func (d mongoDriver) CreateDatabase(query queryFunc, p ConnParams, drop bool) error {
+	if !drop {
+		return nil
+	}
+	_, err := query(d.eval(p, "db.dropDatabase()"))
+	return err
+}
+
+// listCollections returns the user collections of p.Database
+func (d mongoDriver) listCollections(query queryFunc, p ConnParams) ([]string, error) {
+	out, err := query(d.eval(p, `db.getCollectionNames().filter(c => !c.startsWith("system.")).sort().forEach(c => print(c))`))
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// eval builds a mongosh invocation running script against p.Database.
+// mongosh has no --config, so the script adds the password from the
+// environment to the URI itself.
+func (d mongoDriver) eval(p ConnParams, script string) []string {
+	uri, _ := json.Marshal(mongoURI(p))
+	database, _ := json.Marshal(p.Database)
+
+	connect := fmt.Sprintf("let uri = %s;", uri)
+	if p.Password != "" && p.Username != "" {
+		connect += fmt.Sprintf(` uri = uri.replace("@", ":" + encodeURIComponent(JSON.parse(process.env.%s)) + "@");`, d.PasswordEnv())
+	}
+	connect += fmt.Sprintf(" db = connect(uri).getSiblingDB(%s);", database)
+
+	return []string{"mongosh", "--quiet", "--nodb", "--eval", connect + " " + script}
+}
+
+// withPassword wraps a mongodump or mongorestore argv in a shell that
+// writes the password to a temporary --config file first, which is removed
+// however the tool ends. The argv is passed as positional parameters, so
+// none of it is interpreted by the shell.
+func (d mongoDriver) withPassword(p ConnParams, args []string) []string {
+	if p.Password == "" {
+		return args
+	}
+	script := fmt.Sprintf(`umask 077 && config=$(mktemp) || exit 1
+trap 'rm -f "$config"' EXIT
+trap 'exit 1' HUP INT TERM
+printf 'password: %%s\n' "$%s" > "$config" && "$@" --config="$config"`, d.PasswordEnv())
+	return append([]string{"sh", "-c", script, args[0]}, args...)
+}
+
+// mongoURI builds a mongodb:// URI without password or database for p.
+// Host may be a comma-separated replica set seed list; hosts without a
+// port get p.Port. Through an SSH tunnel the single tunneled member is
+// addressed directly, since the set's own member addresses are unreachable.
+func mongoURI(p ConnParams) string {
+	var hosts []string
+	for _, host := range strings.Split(p.Host, ",") {
+		host = strings.TrimSpace(host)
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), strconv.Itoa(p.Port))
+		}
+		hosts = append(hosts, host)
+	}
+
+	u := url.URL{Scheme: "mongodb", Host: strings.Join(hosts, ","), Path: "/"}
+	if p.Username != "" {
+		u.User = url.User(p.Username)
+	}
+
+	query := url.Values{}
+	if p.Username != "" {
+		authSource := p.AuthDatabase
+		if authSource == "" {
+			authSource = "admin"
+		}
+		query.Set("authSource", authSource)
+	}
+	if p.Tunneled {
+		query.Set("directConnection", "true")
+	} else if p.ReplicaSet != "" {
+		query.Set("replicaSet", p.ReplicaSet)
+	}
+	for key, value := range mongoTLSOptions[p.SSLMode] {
+		query.Set(key, value)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// mongoTLSOptions translates libpq style TLS modes to URI options
+var mongoTLSOptions = map[string]map[string]string{
+	"disable":     {"tls": "false"},
+	"require":     {"tls": "true", "tlsInsecure": "true"},
+	"verify-ca":   {"tls": "true", "tlsAllowInvalidHostnames": "true"},
+	"verify-full": {"tls": "true"},
+}
@@ -0,0 +1,289 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerDriver(mysqlDriver{})
+}
+
+// mysqlDriver dumps with mysqldump and restores with the mysql client.
+// With mariadb set it is the MariaDB driver, which runs MariaDB's own
+// mariadb-dump and mariadb tools from the mariadb images instead.
+type mysqlDriver struct {
+	mariadb bool
+}
+
+func (d mysqlDriver) Name() string {
+	if d.mariadb {
+		return "mariadb"
+	}
+	return "mysql"
+}
+
+func (d mysqlDriver) DisplayName() string {
+	if d.mariadb {
+		return "MariaDB"
+	}
+	return "MySQL"
+}
+
+func (d mysqlDriver) ClientImage() string {
+	if d.mariadb {
+		return "mariadb:11"
+	}
+	return "mysql:8"
+}
+
+func (mysqlDriver) DefaultPort() int    { return 3306 }
+func (mysqlDriver) PasswordEnv() string { return "MYSQL_PWD" }
+func (mysqlDriver) Formats() []string   { return []string{"plain"} }
+
+// tool returns the program MariaDB names mysql or mysqldump; recent
+// mariadb images no longer ship the MySQL names
+func (d mysqlDriver) tool(program string) string {
+	if !d.mariadb {
+		return program
+	}
+	return map[string]string{"mysql": "mariadb", "mysqldump": "mariadb-dump"}[program]
+}
+
+// ResolveFilters expands the table patterns, since mysqldump only takes
+// exact table names. --schema is rejected: in MySQL the database is the schema.
+func (d mysqlDriver) ResolveFilters(query queryFunc, p ConnParams, f DumpFilters) (DumpFilters, error) {
+	if len(f.Schemas) > 0 {
+		return f, fmt.Errorf("--schema is not supported for %s; the database is the schema", d.DisplayName())
+	}
+	if len(f.IncludeTables) == 0 && len(f.ExcludeTables) == 0 {
+		return f, nil
+	}
+
+	tables, err := d.listTables(query, p)
+	if err != nil {
+		return f, fmt.Errorf("failed to list tables: %v", err)
+	}
+
+	resolved := f
+	resolved.ExcludeTables = matchTables(tables, f.ExcludeTables)
+	if len(f.IncludeTables) > 0 {
+		resolved.IncludeTables = nil
+		for _, table := range matchTables(tables, f.IncludeTables) {
+			if len(matchTables([]string{table}, f.ExcludeTables)) == 0 {
+				resolved.IncludeTables = append(resolved.IncludeTables, table)
+			}
+		}
+		if len(resolved.IncludeTables) == 0 {
+			return f, fmt.Errorf("no tables in %s match --include-table %s", p.Database, strings.Join(f.IncludeTables, ", "))
+		}
+		// The included tables already leave the excluded ones out
+		resolved.ExcludeTables = nil
+	}
+	return resolved, nil
+}
+
+func (d mysqlDriver) DumpCommand(p ConnParams, f DumpFilters, format DumpFormat) []string {
+	args := d.connArgs("mysqldump", p)
+	if f.SchemaOnly {
+		args = append(args, "--no-data")
+	}
+	if f.DataOnly {
+		args = append(args, "--no-create-info", "--skip-triggers")
+	}
+	for _, table := range f.ExcludeTables {
+		args = append(args, "--ignore-table="+p.Database+"."+table)
+	}
+	args = append(args, "--", p.Database)
+	return append(args, f.IncludeTables...)
+}
+
+func (d mysqlDriver) RestoreCommand(p ConnParams, format string) []string {
+	return d.mysql(p, "--database="+p.Database)
+}
+
+func (d mysqlDriver) ServerVersion(query queryFunc, p ConnParams) (string, error) {
+	return query(d.mysql(p, "-N", "-B", "-e", "SELECT VERSION()"))
+}
+
+// imageVersion picks the image of the server's major.minor release. A
+// MariaDB server behind --type mysql keeps the default image, as does a
+// MySQL server behind --type mariadb.
+func (d mysqlDriver) imageVersion(serverVersion string) (string, string, bool) {
+	if d.mariadb {
+		return mariadbImageVersion(serverVersion)
+	}
+	if isMariaDBVersion(serverVersion) {
+		return "", "", false
+	}
+	major, minor, ok := parseVersion(serverVersion)
+	if !ok || major*100+minor < 507 {
+		return "", "", false
+	}
+	version := fmt.Sprintf("%d.%d", major, minor)
+	return version, "mysql:" + version, true
+}
+
+func (d mysqlDriver) localTools() []string {
+	return []string{d.tool("mysqldump"), d.tool("mysql")}
+}
+
+// localToolFits requires a dump tool from the server's own product. MySQL
+// tools must also share the server's major version, as mysqldump 8 fails
+// against 5.7, and be at least its minor release; MariaDB tools must be
+// at least the server's release.
+func (d mysqlDriver) localToolFits(tool, server string) bool {
+	if isMariaDBVersion(tool) != d.mariadb || isMariaDBVersion(server) != d.mariadb {
+		return false
+	}
+	major, minor, ok := parseVersion(server)
+	if !ok {
+		return false
+	}
+	version := toolVersion(tool)
+	if !d.mariadb {
+		if toolMajor, _, _ := parseVersion(version); toolMajor != major {
+			return false
+		}
+	}
+	return versionAtLeast(version, major, minor)
+}
+
+func (d mysqlDriver) CountTables(query queryFunc, p ConnParams) (int, error) {
+	out, err := query(d.mysql(p, "-N", "-B", "-e",
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_schema = "+mysqlLiteral(p.Database)))
+	if err != nil {
+		return 0, err
+	}
+	return parseCount(out)
+}
+
+func (d mysqlDriver) DatabaseSize(query queryFunc, p ConnParams) (int64, error) {
+	out, err := query(d.mysql(p, "-N", "-B", "-e",
+		"SELECT COALESCE(SUM(data_length), 0) FROM information_schema.tables WHERE table_schema = "+mysqlLiteral(p.Database)))
+	if err != nil {
+		return 0, err
+	}
+	return parseSizeResult(out)
+}
+
+// listTables returns the base tables and views of p.Database
+func (d mysqlDriver) listTables(query queryFunc, p ConnParams) ([]string, error) {
+	out, err := query(d.mysql(p, "-N", "-B", "-e",
+		"SELECT table_name FROM information_schema.tables WHERE table_schema = "+mysqlLiteral(p.Database)+" ORDER BY table_name"))
+	if err != nil {
+		return nil, err
+	}
+	if out == "" {
+		return nil, nil
+	}
+	return strings.Split(out, "\n"), nil
+}
+
+// mysqlLiteral quotes value as a SQL string literal
+func mysqlLiteral(value string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(value, `\`, `\\`), "'", "''") + "'"
+}
+
+func (d mysqlDriver) CreateDatabase(query queryFunc, p ConnParams, drop bool) error {
+	quoted := "`" + strings.ReplaceAll(p.Database, "`", "``") + "`"
+
+	stmt := "CREATE DATABASE IF NOT EXISTS " + quoted
+	if drop {
+		stmt = "DROP DATABASE IF EXISTS " + quoted + "; CREATE DATABASE " + quoted
+	}
+
+	_, err := query(d.mysql(p, "-e", stmt))
+	return err
+}
+
+// StoredPassword reads the password option from ~/.my.cnf
+func (mysqlDriver) StoredPassword(p ConnParams) (string, bool) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+
+	file, err := os.Open(filepath.Join(home, ".my.cnf"))
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	return lookupMyCnfPassword(file)
+}
+
+// lookupMyCnfPassword returns the password from the client groups of an
+// option file, including MariaDB's; later groups override earlier ones
+// the same way the client tools read them
+func lookupMyCnfPassword(r io.Reader) (string, bool) {
+	var password string
+	found := false
+	inClientGroup := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group := strings.ToLower(strings.TrimSpace(line[1 : len(line)-1]))
+			inClientGroup = slices.Contains(myCnfClientGroups, group)
+			continue
+		}
+		if !inClientGroup {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) != "password" {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		password, found = value, true
+	}
+	return password, found
+}
+
+// mysql builds a mysql client invocation with extra appended
+func (d mysqlDriver) mysql(p ConnParams, extra ...string) []string {
+	return append(d.connArgs("mysql", p), extra...)
+}
+
+// connArgs returns program with its connection options. Values are
+// attached with = so one starting with - is never read as an option.
+func (d mysqlDriver) connArgs(program string, p ConnParams) []string {
+	args := []string{d.tool(program), "--host=" + p.Host, "--port=" + strconv.Itoa(p.Port), "--user=" + p.Username}
+	if d.mariadb {
+		return append(args, mariadbSSLOptions[p.SSLMode]...)
+	}
+	if mode, ok := mysqlSSLModes[p.SSLMode]; ok {
+		args = append(args, "--ssl-mode="+mode)
+	}
+	return args
+}
+
+// myCnfClientGroups are the option file groups the client tools read
+var myCnfClientGroups = []string{"client", "mysql", "mysqldump", "client-mariadb", "mariadb", "mariadb-dump"}
+
+// mysqlSSLModes translates libpq style TLS modes to MySQL --ssl-mode values
+var mysqlSSLModes = map[string]string{
+	"disable":     "DISABLED",
+	"allow":       "PREFERRED",
+	"prefer":      "PREFERRED",
+	"require":     "REQUIRED",
+	"verify-ca":   "VERIFY_CA",
+	"verify-full": "VERIFY_IDENTITY",
+}
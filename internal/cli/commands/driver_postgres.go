@@ -0,0 +1,251 @@
+package commands
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerDriver(postgresDriver{})
+}
+
+// postgresDriver dumps with pg_dump and restores with psql
+type postgresDriver struct{}
+
+func (postgresDriver) Name() string        { return "postgres" }
+func (postgresDriver) DisplayName() string { return "PostgreSQL" }
+func (postgresDriver) DefaultPort() int    { return 5432 }
+func (postgresDriver) ClientImage() string { return "postgres:15-alpine" }
+func (postgresDriver) PasswordEnv() string { return "PGPASSWORD" }
+func (postgresDriver) Formats() []string {
+	return []string{"plain", "custom", "directory", "tar"}
+}
+
+// ResolveFilters passes f through; pg_dump matches the patterns itself
+func (postgresDriver) ResolveFilters(query queryFunc, p ConnParams, f DumpFilters) (DumpFilters, error) {
+	return f, nil
+}
+
+func (postgresDriver) DumpCommand(p ConnParams, f DumpFilters, format DumpFormat) []string {
+	args := append([]string{"pg_dump"}, pgConnArgs(p, p.Database)...)
+	switch format.Name {
+	case "custom", "directory":
+		args = append(args, "--format="+format.Name)
+		if !format.Compress {
+			args = append(args, "--compress=0")
+		}
+	case "tar":
+		args = append(args, "--format=tar")
+	}
+	if format.Name == "directory" && format.Jobs > 1 {
+		args = append(args, "--jobs="+strconv.Itoa(format.Jobs))
+	}
+	if f.SchemaOnly {
+		args = append(args, "--schema-only")
+	}
+	if f.DataOnly {
+		args = append(args, "--data-only")
+	}
+	for _, schema := range f.Schemas {
+		args = append(args, "--schema="+schema)
+	}
+	for _, table := range f.IncludeTables {
+		args = append(args, "--table="+table)
+	}
+	for _, table := range f.ExcludeTables {
+		args = append(args, "--exclude-table="+table)
+	}
+	// Fail instead of writing an empty dump when an include pattern
+	// matches nothing
+	if len(f.Schemas) > 0 || len(f.IncludeTables) > 0 {
+		args = append(args, "--strict-names")
+	}
+
+	if format.Name == "directory" {
+		// pg_dump cannot write a directory to stdout, so pack it with tar.
+		// The arguments reach pg_dump as "$@", never through the shell
+		// parser, and the directory is private to this dump and removed
+		// however it ends.
+		script := `dir=$(mktemp -d) || exit 1
+trap 'rm -rf "$dir"' EXIT
+trap 'exit 1' HUP INT TERM
+pg_dump "$@" --file="$dir/dump" && tar -C "$dir/dump" -cf - .`
+		return append([]string{"sh", "-c", script}, args...)
+	}
+	return args
+}
+
+func (d postgresDriver) RestoreCommand(p ConnParams, format string) []string {
+	switch format {
+	case "custom", "tar":
+		return append([]string{"pg_restore", "--exit-on-error", "--format=" + format}, pgConnArgs(p, p.Database)...)
+	}
+	return d.psql(p, p.Database, "-q")
+}
+
+func (d postgresDriver) ServerVersion(query queryFunc, p ConnParams) (string, error) {
+	return query(d.psql(p, p.Database, "-tAc", "SHOW server_version"))
+}
+
+// imageVersion picks the image of the server's major version, since
+// pg_dump refuses servers newer than itself. The default image dumps
+// anything older than PostgreSQL 10.
+func (postgresDriver) imageVersion(serverVersion string) (string, string, bool) {
+	major, _, ok := parseVersion(serverVersion)
+	if !ok || major < 10 {
+		return "", "", false
+	}
+	version := strconv.Itoa(major)
+	return version, "postgres:" + version + "-alpine", true
+}
+
+func (postgresDriver) localTools() []string {
+	return []string{"pg_dump", "psql", "pg_restore"}
+}
+
+// localToolFits requires pg_dump to be at least the server's major version
+func (postgresDriver) localToolFits(tool, server string) bool {
+	major, _, ok := parseVersion(server)
+	return ok && versionAtLeast(toolVersion(tool), major, 0)
+}
+
+func (d postgresDriver) DatabaseSize(query queryFunc, p ConnParams) (int64, error) {
+	out, err := query(d.psql(p, p.Database, "-tAc", "SELECT pg_database_size(current_database())"))
+	if err != nil {
+		return 0, err
+	}
+	return parseSizeResult(out)
+}
+
+func (d postgresDriver) CountTables(query queryFunc, p ConnParams) (int, error) {
+	out, err := query(d.psql(p, p.Database, "-tAc",
+		"SELECT count(*) FROM information_schema.tables WHERE table_schema NOT IN ('pg_catalog', 'information_schema')"))
+	if err != nil {
+		return 0, err
+	}
+	return parseCount(out)
+}
+
+func (d postgresDriver) CreateDatabase(query queryFunc, p ConnParams, drop bool) error {
+	quoted := `"` + strings.ReplaceAll(p.Database, `"`, `""`) + `"`
+
+	if drop {
+		if _, err := query(d.psql(p, "postgres", "-c", "DROP DATABASE IF EXISTS "+quoted)); err != nil {
+			return err
+		}
+	} else {
+		literal := "'" + strings.ReplaceAll(p.Database, "'", "''") + "'"
+		exists, err := query(d.psql(p, "postgres", "-tAc", "SELECT 1 FROM pg_database WHERE datname = "+literal))
+		if err != nil {
+			return err
+		}
+		if exists != "" {
+			return nil
+		}
+	}
+
+	_, err := query(d.psql(p, "postgres", "-c", "CREATE DATABASE "+quoted))
+	return err
+}
+
+// StoredPassword reads $PGPASSFILE or ~/.pgpass
+func (postgresDriver) StoredPassword(p ConnParams) (string, bool) {
+	path := os.Getenv("PGPASSFILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", false
+		}
+		path = filepath.Join(home, ".pgpass")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer file.Close()
+
+	return lookupPgpass(file, p)
+}
+
+// lookupPgpass returns the password of the first hostname:port:database:username:password
+// line matching p, where * matches anything and \ escapes : and \
+func lookupPgpass(r io.Reader, p ConnParams) (string, bool) {
+	want := []string{p.Host, strconv.Itoa(p.Port), p.Database, p.Username}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := splitPgpassLine(line)
+		if len(fields) != 5 {
+			continue
+		}
+
+		matched := true
+		for i, value := range want {
+			if fields[i] != "*" && fields[i] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return fields[4], true
+		}
+	}
+	return "", false
+}
+
+// splitPgpassLine splits on unescaped colons and removes the escapes
+func splitPgpassLine(line string) []string {
+	var fields []string
+	var current strings.Builder
+
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line):
+			i++
+			current.WriteByte(line[i])
+		case line[i] == ':' && len(fields) < 4:
+			fields = append(fields, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(line[i])
+		}
+	}
+	return append(fields, current.String())
+}
+
+// psql builds a psql invocation against database that stops on the first error
+func (postgresDriver) psql(p ConnParams, database string, extra ...string) []string {
+	args := append([]string{"psql", "-v", "ON_ERROR_STOP=1"}, pgConnArgs(p, database)...)
+	return append(args, extra...)
+}
+
+// pgConnArgs returns the connection options for database. Values are
+// attached with = and the database goes through a conninfo string, so a
+// name starting with - or containing = is never read as an option.
+func pgConnArgs(p ConnParams, database string) []string {
+	conninfo := "dbname=" + pgConnValue(database)
+	if p.SSLMode != "" {
+		conninfo += " sslmode=" + pgConnValue(p.SSLMode)
+	}
+	return []string{
+		"--host=" + p.Host,
+		"--port=" + strconv.Itoa(p.Port),
+		"--username=" + p.Username,
+		"--dbname=" + conninfo,
+	}
+}
+
+// pgConnValue quotes a conninfo value, escaping \ and '
+func pgConnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerDriver(redisDriver{})
+}
+
+// redisDriver exports an RDB snapshot with redis-cli --rdb, which asks the
+// server for a full resync the way a replica does. A snapshot covers every
+// logical database, so --database is only used for the key count.
+type redisDriver struct{}
+
+func (redisDriver) Name() string        { return "redis" }
+func (redisDriver) DisplayName() string { return "Redis" }
+func (redisDriver) DefaultPort() int    { return 6379 }
+func (redisDriver) ClientImage() string { return "redis:7-alpine" }
+func (redisDriver) Formats() []string   { return []string{"rdb"} }
+
+// PasswordEnv is read by redis-cli in place of -a; with --user it is the
+// password of that ACL user
+func (redisDriver) PasswordEnv() string { return "REDISCLI_AUTH" }
+
+// requiredFlags is empty: the default user needs no name and a snapshot
+// is not tied to a database
+func (redisDriver) requiredFlags() []string { return nil }
+
+// StoredPassword always misses: redis-cli has no credential file
+func (redisDriver) StoredPassword(p ConnParams) (string, bool) {
+	return "", false
+}
+
+// ResolveFilters rejects every filter, since a snapshot is all or nothing
+func (redisDriver) ResolveFilters(query queryFunc, p ConnParams, f DumpFilters) (DumpFilters, error) {
+	if !f.isEmpty() {
+		return f, fmt.Errorf("Redis snapshots cannot be filtered")
+	}
+	return f, nil
+}
+
+func (redisDriver) DumpCommand(p ConnParams, f DumpFilters, format DumpFormat) []string {
+	return append(redisConnArgs(p), "--rdb", "-")
+}
+
+// RestoreCommand returns nil: an RDB file can only be loaded by copying it
+// to the server and restarting it, so runDBRestore rejects snapshots
+func (redisDriver) RestoreCommand(p ConnParams, format string) []string {
+	return nil
+}
+
+func (redisDriver) ServerVersion(query queryFunc, p ConnParams) (string, error) {
+	out, err := query(append(redisConnArgs(p), "INFO", "server"))
+	if err != nil {
+		return "", err
+	}
+	return redisInfoField(out, "redis_version")
+}
+
+// imageVersion picks the image of the server's major version, so
+// redis-cli understands the server's replication protocol
+func (redisDriver) imageVersion(serverVersion string) (string, string, bool) {
+	major, _, ok := parseVersion(serverVersion)
+	if !ok || major < 6 {
+		return "", "", false
+	}
+	version := strconv.Itoa(major)
+	return version, "redis:" + version + "-alpine", true
+}
+
+func (redisDriver) localTools() []string {
+	return []string{"redis-cli"}
+}
+
+// localToolFits requires redis-cli 6 or newer for ACL users and TLS; --rdb
+// copies the snapshot bytes without reading them, so any server works
+func (redisDriver) localToolFits(tool, server string) bool {
+	return versionAtLeast(toolVersion(tool), 6, 0)
+}
+
+// DatabaseSize reports the server's dataset memory, which is a rough upper
+// bound for the snapshot since RDB encodes values compactly
+func (redisDriver) DatabaseSize(query queryFunc, p ConnParams) (int64, error) {
+	out, err := query(append(redisConnArgs(p), "INFO", "memory"))
+	if err != nil {
+		return 0, err
+	}
+	size, err := redisInfoField(out, "used_memory_dataset")
+	if err != nil {
+		return 0, err
+	}
+	return parseSizeResult(size)
+}
+
+// CountTables counts the keys of the selected logical database
+func (redisDriver) CountTables(query queryFunc, p ConnParams) (int, error) {
+	args := redisConnArgs(p)
+	if p.Database != "" {
+		args = append(args, "-n", p.Database)
+	}
+	out, err := query(append(args, "DBSIZE"))
+	if err != nil {
+		return 0, err
+	}
+	return parseCount(out)
+}
+
+func (redisDriver) CreateDatabase(query queryFunc, p ConnParams, drop bool) error {
+	return fmt.Errorf("Redis databases cannot be created or dropped")
+}
+
+// redisInfoField returns the value of key in INFO output
+func redisInfoField(info, key string) (string, error) {
+	for _, line := range strings.Split(info, "\n") {
+		if value, ok := strings.CutPrefix(strings.TrimSpace(line), key+":"); ok {
+			return value, nil
+		}
+	}
+	return "", fmt.Errorf("%s missing from INFO output", key)
+}
+
+// redisConnArgs returns redis-cli with its connection options. redis-cli
+// takes the value of -h, -p and --user from the next argument, so values
+// starting with - are not read as options.
+func redisConnArgs(p ConnParams) []string {
+	args := []string{"redis-cli", "-h", p.Host, "-p", strconv.Itoa(p.Port)}
+	if p.Username != "" {
+		args = append(args, "--user", p.Username)
+	}
+	switch p.SSLMode {
+	case "require":
+		args = append(args, "--tls", "--insecure")
+	case "verify-ca", "verify-full":
+		args = append(args, "--tls")
+	}
+	return args
+}
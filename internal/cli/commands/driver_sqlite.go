@@ -0,0 +1,134 @@
+package commands
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	registerDriver(sqliteDriver{})
+}
+
+// sqliteBusyTimeout is how long, in milliseconds, the backup waits for a
+// writer holding the database lock
+const sqliteBusyTimeout = 10000
+
+// sqliteDriver copies a SQLite file with the sqlite3 shell's .backup, which
+// uses the online backup API, so a database being written to is captured
+// consistently instead of copied mid-transaction. The tools run where the
+// file is: on the last --ssh-jump hop, or in a container that mounts it.
+type sqliteDriver struct{}
+
+func (sqliteDriver) Name() string        { return "sqlite" }
+func (sqliteDriver) DisplayName() string { return "SQLite" }
+func (sqliteDriver) DefaultPort() int    { return 0 }
+func (sqliteDriver) ClientImage() string { return "keinos/sqlite3:latest" }
+func (sqliteDriver) Formats() []string   { return []string{"sqlite"} }
+
+// PasswordEnv is empty: SQLite files are protected by file permissions only
+func (sqliteDriver) PasswordEnv() string { return "" }
+
+// requiredFlags asks for the file instead of a server login
+func (sqliteDriver) requiredFlags() []string { return []string{"path"} }
+
+// StoredPassword always misses: SQLite has no credentials
+func (sqliteDriver) StoredPassword(p ConnParams) (string, bool) {
+	return "", false
+}
+
+// containerOptions bind-mounts the file's directory at the same path. The
+// mount is writable because readers of a WAL database need its -shm file,
+// and the image's sqlite3 entrypoint is cleared so the argv runs as given.
+func (sqliteDriver) containerOptions(p ConnParams) containerSpec {
+	return containerSpec{network: "none", noEntrypoint: true, mounts: []string{filepath.Dir(p.Path)}}
+}
+
+// ResolveFilters rejects every filter, since a backup copies the whole file
+func (sqliteDriver) ResolveFilters(query queryFunc, p ConnParams, f DumpFilters) (DumpFilters, error) {
+	if !f.isEmpty() {
+		return f, fmt.Errorf("SQLite backups cannot be filtered")
+	}
+	return f, nil
+}
+
+// DumpCommand backs the database up to a temporary file next to the tools
+// and streams that file, which is removed however the command ends
+func (sqliteDriver) DumpCommand(p ConnParams, f DumpFilters, format DumpFormat) []string {
+	script := fmt.Sprintf(`[ -f "$1" ] || { echo "$1: no such database file" >&2; exit 1; }
+tmp=$(mktemp) || exit 1
+trap 'rm -f "$tmp"' EXIT
+trap 'exit 1' HUP INT TERM
+sqlite3 -bail -cmd '.timeout %d' "$1" ".backup '$tmp'" && cat "$tmp"`, sqliteBusyTimeout)
+	return []string{"sh", "-c", script, "sqlite3", sqlitePath(p.Path)}
+}
+
+// RestoreCommand returns nil: a SQLite backup is the database file itself,
+// so runDBRestore leaves putting it in place to the operator
+func (sqliteDriver) RestoreCommand(p ConnParams, format string) []string {
+	return nil
+}
+
+// ServerVersion reports the version of the sqlite3 shell that takes the
+// backup, since there is no server
+func (sqliteDriver) ServerVersion(query queryFunc, p ConnParams) (string, error) {
+	out, err := query([]string{"sqlite3", "-version"})
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("unexpected sqlite3 version %q", out)
+	}
+	return fields[0], nil
+}
+
+func (sqliteDriver) localTools() []string {
+	return []string{"sqlite3"}
+}
+
+// localToolFits accepts any SQLite 3 shell: there is no server, and the
+// file format is readable by every release
+func (sqliteDriver) localToolFits(tool, server string) bool {
+	major, _, ok := parseVersion(toolVersion(tool))
+	return ok && major == 3
+}
+
+// DatabaseSize is the size of the main database file
+func (sqliteDriver) DatabaseSize(query queryFunc, p ConnParams) (int64, error) {
+	out, err := query([]string{"sh", "-c", `wc -c < "$1"`, "wc", sqlitePath(p.Path)})
+	if err != nil {
+		return 0, err
+	}
+	return parseSizeResult(out)
+}
+
+func (sqliteDriver) CountTables(query queryFunc, p ConnParams) (int, error) {
+	out, err := query([]string{"sqlite3", "-readonly", sqlitePath(p.Path),
+		"SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"})
+	if err != nil {
+		return 0, err
+	}
+	return parseCount(out)
+}
+
+func (sqliteDriver) CreateDatabase(query queryFunc, p ConnParams, drop bool) error {
+	return fmt.Errorf("SQLite databases are files and cannot be created or dropped")
+}
+
+// checkPath rejects --path for engines that are not file databases
+func checkPath(d BackupDriver, path string) error {
+	if _, ok := d.(fileDriver); ok || path == "" {
+		return nil
+	}
+	return fmt.Errorf("--path is only supported for SQLite")
+}
+
+// sqlitePath keeps a file name from being read as an sqlite3 option or a
+// file: URI
+func sqlitePath(path string) string {
+	if strings.HasPrefix(path, "-") || strings.HasPrefix(path, "file:") {
+		return "./" + path
+	}
+	return path
+}
@@ -0,0 +1,804 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLookupDriver(t *testing.T) {
+	for _, name := range []string{"postgres", "mysql", "mariadb", "mongodb", "redis", "sqlite"} {
+		d, err := lookupDriver(name)
+		if err != nil {
+			t.Fatalf("Expected driver %s to be registered, got %v", name, err)
+		}
+		if d.Name() != name {
+			t.Errorf("Expected driver name %s, got %s", name, d.Name())
+		}
+	}
+
+	_, err := lookupDriver("oracle")
+	if err == nil || err.Error() != "unsupported database type: oracle" {
+		t.Errorf("Expected unsupported type error, got %v", err)
+	}
+}
+
+func TestDriverNamesSorted(t *testing.T) {
+	names := driverNames()
+
+	for i := 1; i < len(names); i++ {
+		if names[i-1] > names[i] {
+			t.Errorf("Expected sorted driver names, got %v", names)
+		}
+	}
+}
+
+func TestTypeFlagUsage(t *testing.T) {
+	usage := typeFlagUsage()
+
+	for _, name := range driverNames() {
+		if !strings.Contains(usage, name) {
+			t.Errorf("Expected usage %q to mention %s", usage, name)
+		}
+	}
+}
+
+func TestRegisterDriverTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic when registering a driver twice")
+		}
+	}()
+
+	registerDriver(postgresDriver{})
+}
+
+func TestDriverDefaults(t *testing.T) {
+	tests := []struct {
+		driver      BackupDriver
+		port        int
+		image       string
+		passwordEnv string
+	}{
+		{postgresDriver{}, 5432, "postgres:15-alpine", "PGPASSWORD"},
+		{mysqlDriver{}, 3306, "mysql:8", "MYSQL_PWD"},
+		{mysqlDriver{mariadb: true}, 3306, "mariadb:11", "MYSQL_PWD"},
+		{mongoDriver{}, 27017, "mongo:7", "CUTTER_MONGO_PASSWORD"},
+		{redisDriver{}, 6379, "redis:7-alpine", "REDISCLI_AUTH"},
+		{sqliteDriver{}, 0, "keinos/sqlite3:latest", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.driver.Name(), func(t *testing.T) {
+			if tt.driver.DefaultPort() != tt.port {
+				t.Errorf("Expected port %d, got %d", tt.port, tt.driver.DefaultPort())
+			}
+			if tt.driver.ClientImage() != tt.image {
+				t.Errorf("Expected image %s, got %s", tt.image, tt.driver.ClientImage())
+			}
+			if tt.driver.PasswordEnv() != tt.passwordEnv {
+				t.Errorf("Expected password env %s, got %s", tt.passwordEnv, tt.driver.PasswordEnv())
+			}
+		})
+	}
+}
+
+func TestDriverDumpCommand(t *testing.T) {
+	p := ConnParams{Host: "db.internal", Port: 6543, Username: "app", Password: "secret", Database: "orders"}
+
+	tests := []struct {
+		driver BackupDriver
+		want   string
+	}{
+		{postgresDriver{}, "pg_dump --host=db.internal --port=6543 --username=app --dbname=dbname='orders'"},
+		{mysqlDriver{}, "mysqldump --host=db.internal --port=6543 --user=app -- orders"},
+		{mysqlDriver{mariadb: true}, "mariadb-dump --host=db.internal --port=6543 --user=app -- orders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.driver.Name(), func(t *testing.T) {
+			got := strings.Join(tt.driver.DumpCommand(p, DumpFilters{}, DumpFormat{Name: "plain"}), " ")
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+			if strings.Contains(got, p.Password) {
+				t.Error("Password must not appear in dump command")
+			}
+		})
+	}
+}
+
+func TestDriverDumpCommandSSLMode(t *testing.T) {
+	p := ConnParams{Host: "db.internal", Port: 6543, Username: "app", Database: "orders", SSLMode: "verify-full"}
+
+	tests := []struct {
+		driver BackupDriver
+		want   string
+	}{
+		{postgresDriver{}, "pg_dump --host=db.internal --port=6543 --username=app --dbname=dbname='orders' sslmode='verify-full'"},
+		{mysqlDriver{}, "mysqldump --host=db.internal --port=6543 --user=app --ssl-mode=VERIFY_IDENTITY -- orders"},
+		{mysqlDriver{mariadb: true}, "mariadb-dump --host=db.internal --port=6543 --user=app --ssl --ssl-verify-server-cert -- orders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.driver.Name(), func(t *testing.T) {
+			got := strings.Join(tt.driver.DumpCommand(p, DumpFilters{}, DumpFormat{Name: "plain"}), " ")
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDriverCommandsKeepHostileValuesInOneArgument(t *testing.T) {
+	hostile := []string{
+		`-oProxyCommand=touch /tmp/pwned`,
+		`it's "quoted"`,
+		"with space",
+		"$(reboot); rm -rf /",
+		`back\slash`,
+	}
+
+	for _, name := range driverNames() {
+		d, _ := lookupDriver(name)
+		for _, value := range hostile {
+			p := ConnParams{Host: value, Port: 5432, Username: value, Database: value}
+
+			for _, args := range [][]string{d.DumpCommand(p, DumpFilters{}, DumpFormat{Name: "plain"}), d.RestoreCommand(p, "plain")} {
+				for i, arg := range args[min(1, len(args)):] {
+					// redis-cli has no --option=value form, but always takes
+					// the next argument as the option's value
+					if name == "redis" && strings.HasPrefix(args[i], "-") {
+						continue
+					}
+					if arg == value && args[i] != "--" {
+						t.Errorf("%s: expected %q to be attached to an option or follow --, got %q", name, value, args)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestMySQLDumpEndsOptions(t *testing.T) {
+	args := (mysqlDriver{}).DumpCommand(ConnParams{Host: "h", Port: 3306, Username: "u", Database: "--all-databases"}, DumpFilters{}, DumpFormat{Name: "plain"})
+
+	if len(args) < 2 || args[len(args)-2] != "--" || args[len(args)-1] != "--all-databases" {
+		t.Errorf("Expected database after --, got %q", args)
+	}
+}
+
+func TestPgConnValue(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"orders", `'orders'`},
+		{"it's", `'it\'s'`},
+		{`a\b`, `'a\\b'`},
+		{"host=evil", `'host=evil'`},
+	}
+
+	for _, tt := range tests {
+		if got := pgConnValue(tt.input); got != tt.want {
+			t.Errorf("pgConnValue(%q) = %s, want %s", tt.input, got, tt.want)
+		}
+	}
+}
+
+// recordingQuery captures client invocations and replies with a fixed output
+func recordingQuery(out string, calls *[]string) queryFunc {
+	return func(args []string) (string, error) {
+		*calls = append(*calls, strings.Join(args, " "))
+		return out, nil
+	}
+}
+
+func TestDriverServerVersion(t *testing.T) {
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "app", Database: "orders"}
+
+	tests := []struct {
+		driver BackupDriver
+		query  string
+	}{
+		{postgresDriver{}, "SHOW server_version"},
+		{mysqlDriver{}, "SELECT VERSION()"},
+		{mysqlDriver{mariadb: true}, "SELECT VERSION()"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.driver.Name(), func(t *testing.T) {
+			var calls []string
+			got, err := tt.driver.ServerVersion(recordingQuery("15.4", &calls), p)
+			if err != nil || got != "15.4" {
+				t.Errorf("Expected version 15.4, got %q (%v)", got, err)
+			}
+			if len(calls) != 1 || !strings.HasSuffix(calls[0], tt.query) {
+				t.Errorf("Expected %q query, got %v", tt.query, calls)
+			}
+		})
+	}
+}
+
+func TestDriverDumpCommandFilters(t *testing.T) {
+	p := ConnParams{Host: "h", Port: 1, Username: "u", Database: "shop"}
+
+	tests := []struct {
+		name    string
+		driver  BackupDriver
+		filters DumpFilters
+		want    string
+	}{
+		{"postgres schema only", postgresDriver{}, DumpFilters{SchemaOnly: true},
+			"--schema-only"},
+		{"postgres data only", postgresDriver{}, DumpFilters{DataOnly: true},
+			"--data-only"},
+		{"postgres tables", postgresDriver{}, DumpFilters{Schemas: []string{"app"}, IncludeTables: []string{"order*"}, ExcludeTables: []string{"audit_*"}},
+			"--schema=app --table=order* --exclude-table=audit_* --strict-names"},
+		{"postgres exclude only", postgresDriver{}, DumpFilters{ExcludeTables: []string{"audit_*"}},
+			"--exclude-table=audit_*"},
+		{"mysql schema only", mysqlDriver{}, DumpFilters{SchemaOnly: true},
+			"--no-data -- shop"},
+		{"mysql data only", mysqlDriver{}, DumpFilters{DataOnly: true},
+			"--no-create-info --skip-triggers -- shop"},
+		{"mysql tables", mysqlDriver{}, DumpFilters{IncludeTables: []string{"orders", "items"}},
+			"-- shop orders items"},
+		{"mysql exclude", mysqlDriver{}, DumpFilters{ExcludeTables: []string{"audit_log"}},
+			"--ignore-table=shop.audit_log -- shop"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(tt.driver.DumpCommand(p, tt.filters, DumpFormat{Name: "plain"}), " ")
+			if !strings.HasSuffix(got, " "+tt.want) {
+				t.Errorf("Expected %q to end with %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgresDumpCommandFormats(t *testing.T) {
+	p := ConnParams{Host: "h", Port: 5432, Username: "u", Database: "orders"}
+
+	tests := []struct {
+		format DumpFormat
+		want   string
+	}{
+		{DumpFormat{Name: "plain", Compress: true}, "--dbname=dbname='orders'"},
+		{DumpFormat{Name: "custom", Compress: true}, "--format=custom"},
+		{DumpFormat{Name: "custom"}, "--format=custom --compress=0"},
+		{DumpFormat{Name: "tar", Compress: true}, "--format=tar"},
+		{DumpFormat{Name: "directory", Jobs: 8, Compress: true}, "--format=directory --jobs=8"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format.Name, func(t *testing.T) {
+			args := (postgresDriver{}).DumpCommand(p, DumpFilters{}, tt.format)
+			got := strings.Join(args, " ")
+			if !strings.HasSuffix(got, " "+tt.want) {
+				t.Errorf("Expected %q to end with %q", got, tt.want)
+			}
+
+			if tt.format.Name == "directory" && (args[0] != "sh" || args[1] != "-c" || args[3] != "pg_dump" ||
+				!strings.Contains(args[2], `--file="$dir/dump"`)) {
+				t.Errorf("Expected pg_dump wrapped in sh -c with its arguments as $@, got %q", args)
+			}
+		})
+	}
+}
+
+func TestPostgresRestoreCommandArchive(t *testing.T) {
+	p := ConnParams{Host: "h", Port: 5432, Username: "u", Database: "orders"}
+
+	got := strings.Join((postgresDriver{}).RestoreCommand(p, "custom"), " ")
+	want := "pg_restore --exit-on-error --format=custom --host=h --port=5432 --username=u --dbname=dbname='orders'"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	if args := (postgresDriver{}).RestoreCommand(p, "plain"); args[0] != "psql" {
+		t.Errorf("Expected psql for plain dumps, got %q", args)
+	}
+}
+
+func TestMySQLResolveFilters(t *testing.T) {
+	p := ConnParams{Host: "h", Port: 3306, Username: "u", Database: "shop"}
+	tables := "audit_2023\naudit_2024\nevents\nitems\norders"
+
+	tests := []struct {
+		name    string
+		filters DumpFilters
+		want    DumpFilters
+	}{
+		{"no filters", DumpFilters{SchemaOnly: true}, DumpFilters{SchemaOnly: true}},
+		{"exclude", DumpFilters{ExcludeTables: []string{"audit_*", "events"}},
+			DumpFilters{ExcludeTables: []string{"audit_2023", "audit_2024", "events"}}},
+		{"include", DumpFilters{IncludeTables: []string{"*s"}, ExcludeTables: []string{"events"}},
+			DumpFilters{IncludeTables: []string{"items", "orders"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			got, err := (mysqlDriver{}).ResolveFilters(recordingQuery(tables, &calls), p, tt.filters)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if strings.Join(got.IncludeTables, ",") != strings.Join(tt.want.IncludeTables, ",") ||
+				strings.Join(got.ExcludeTables, ",") != strings.Join(tt.want.ExcludeTables, ",") ||
+				got.SchemaOnly != tt.want.SchemaOnly {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMySQLResolveFiltersErrors(t *testing.T) {
+	p := ConnParams{Host: "h", Port: 3306, Username: "u", Database: "shop"}
+	var calls []string
+
+	_, err := (mysqlDriver{}).ResolveFilters(recordingQuery("orders", &calls), p, DumpFilters{Schemas: []string{"public"}})
+	if err == nil || !strings.Contains(err.Error(), "--schema is not supported") {
+		t.Errorf("Expected --schema error, got %v", err)
+	}
+
+	_, err = (mysqlDriver{}).ResolveFilters(recordingQuery("orders", &calls), p, DumpFilters{IncludeTables: []string{"missing*"}})
+	if err == nil || !strings.Contains(err.Error(), "no tables in shop match") {
+		t.Errorf("Expected no match error, got %v", err)
+	}
+}
+
+func TestDriverDatabaseSize(t *testing.T) {
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "app", Database: "orders"}
+
+	tests := []struct {
+		driver BackupDriver
+		query  string
+	}{
+		{postgresDriver{}, "SELECT pg_database_size(current_database())"},
+		{mysqlDriver{}, "WHERE table_schema = 'orders'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.driver.Name(), func(t *testing.T) {
+			var calls []string
+			got, err := tt.driver.DatabaseSize(recordingQuery("1048576", &calls), p)
+			if err != nil || got != 1<<20 {
+				t.Errorf("Expected 1048576 bytes, got %d (%v)", got, err)
+			}
+			if len(calls) != 1 || !strings.Contains(calls[0], tt.query) {
+				t.Errorf("Expected %q query, got %v", tt.query, calls)
+			}
+		})
+	}
+
+	if _, err := (postgresDriver{}).DatabaseSize(recordingQuery("n/a", new([]string)), p); err == nil {
+		t.Error("Expected an error for a non-numeric size")
+	}
+}
+
+func TestPostgresCreateDatabase(t *testing.T) {
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "postgres", Database: `we"ird`}
+
+	var calls []string
+	if err := (postgresDriver{}).CreateDatabase(recordingQuery("", &calls), p, false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(calls) != 2 || !strings.HasSuffix(calls[1], `CREATE DATABASE "we""ird"`) {
+		t.Errorf("Expected existence check then create, got %v", calls)
+	}
+
+	calls = nil
+	if err := (postgresDriver{}).CreateDatabase(recordingQuery("1", &calls), p, false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(calls) != 1 {
+		t.Errorf("Expected no create for existing database, got %v", calls)
+	}
+
+	calls = nil
+	if err := (postgresDriver{}).CreateDatabase(recordingQuery("", &calls), p, true); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(calls) != 2 || !strings.Contains(calls[0], "DROP DATABASE IF EXISTS") {
+		t.Errorf("Expected drop then create, got %v", calls)
+	}
+}
+
+func TestMySQLCreateDatabase(t *testing.T) {
+	p := ConnParams{Host: "localhost", Port: 3306, Username: "root", Database: "we`ird"}
+
+	var calls []string
+	if err := (mysqlDriver{}).CreateDatabase(recordingQuery("", &calls), p, true); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := "DROP DATABASE IF EXISTS `we``ird`; CREATE DATABASE `we``ird`"
+	if len(calls) != 1 || !strings.HasSuffix(calls[0], want) {
+		t.Errorf("Expected %q, got %v", want, calls)
+	}
+}
+
+func TestMariaDBRestoreCommand(t *testing.T) {
+	p := ConnParams{Host: "localhost", Port: 3306, Username: "root", Database: "orders", SSLMode: "disable"}
+
+	got := strings.Join(mysqlDriver{mariadb: true}.RestoreCommand(p, "plain"), " ")
+	want := "mariadb --host=localhost --port=3306 --user=root --skip-ssl --database=orders"
+	if got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestDriverCountTables(t *testing.T) {
+	p := ConnParams{Host: "localhost", Username: "user", Database: "db"}
+
+	for _, d := range []BackupDriver{postgresDriver{}, mysqlDriver{}} {
+		var calls []string
+		n, err := d.CountTables(recordingQuery("7", &calls), p)
+		if err != nil {
+			t.Fatalf("%s: expected no error, got %v", d.Name(), err)
+		}
+		if n != 7 {
+			t.Errorf("%s: expected 7 tables, got %d", d.Name(), n)
+		}
+	}
+}
+
+func TestMongoURI(t *testing.T) {
+	tests := []struct {
+		name string
+		p    ConnParams
+		want string
+	}{
+		{"single host", ConnParams{Host: "db", Port: 27017, Username: "app"},
+			"mongodb://app@db:27017/?authSource=admin"},
+		{"replica set", ConnParams{Host: "m1:27018, m2", Port: 27017, Username: "app", AuthDatabase: "orders", ReplicaSet: "rs0"},
+			"mongodb://app@m1:27018,m2:27017/?authSource=orders&replicaSet=rs0"},
+		{"tunneled", ConnParams{Host: "host.docker.internal", Port: 40001, Username: "app", ReplicaSet: "rs0", Tunneled: true},
+			"mongodb://app@host.docker.internal:40001/?authSource=admin&directConnection=true"},
+		{"tls and escaping", ConnParams{Host: "::1", Port: 27017, Username: "a@b:c", SSLMode: "require"},
+			"mongodb://a%40b%3Ac@[::1]:27017/?authSource=admin&tls=true&tlsInsecure=true"},
+		{"no auth", ConnParams{Host: "db", Port: 27017}, "mongodb://db:27017/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mongoURI(tt.p); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMongoCommandsKeepPasswordOutOfArgv(t *testing.T) {
+	d := mongoDriver{}
+	p := ConnParams{Host: "db", Port: 27017, Username: "app", Password: "s3cr3t", Database: "orders"}
+
+	dump := d.DumpCommand(p, DumpFilters{ExcludeTables: []string{"events"}}, DumpFormat{Name: "archive"})
+	want := []string{"mongodump", "mongodump", "--uri=mongodb://app@db:27017/?authSource=admin", "--db=orders", "--archive", "--excludeCollection=events"}
+	if dump[0] != "sh" || !strings.Contains(dump[2], `"$CUTTER_MONGO_PASSWORD" > "$config"`) || strings.Join(dump[3:], "|") != strings.Join(want, "|") {
+		t.Errorf("Unexpected dump command %q", dump)
+	}
+
+	restore := d.RestoreCommand(ConnParams{Host: "db", Port: 27017, Database: "we$ird"}, "archive")
+	want = []string{"mongorestore", "--uri=mongodb://db:27017/", "--archive", "--nsFrom=$db$.$collection$", `--nsTo=we\$ird.$collection$`}
+	if strings.Join(restore, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected %q without a password, got %q", want, restore)
+	}
+
+	for _, args := range [][]string{dump, restore, d.eval(p, "print(1)")} {
+		if strings.Contains(strings.Join(args, " "), "s3cr3t") {
+			t.Errorf("Expected no password in %q", args)
+		}
+	}
+	if got := d.encodePassword(`p"w\`); got != `"p\"w\\"` {
+		t.Errorf("Expected a JSON string, got %s", got)
+	}
+}
+
+func TestMongoQueries(t *testing.T) {
+	d := mongoDriver{}
+	p := ConnParams{Host: "db", Port: 27017, Username: "app", Password: "pw", Database: "orders"}
+
+	var calls []string
+	version, err := d.ServerVersion(recordingQuery("7.0.4", &calls), p)
+	if err != nil || version != "7.0.4" {
+		t.Errorf("Expected 7.0.4, got %s (%v)", version, err)
+	}
+	script := calls[0]
+	for _, want := range []string{"mongosh --quiet --nodb --eval", "JSON.parse(process.env.CUTTER_MONGO_PASSWORD)", `getSiblingDB("orders")`, "print(db.version())"} {
+		if !strings.Contains(script, want) {
+			t.Errorf("Expected %q in %s", want, script)
+		}
+	}
+
+	n, err := d.CountTables(recordingQuery("orders\nusers", &calls), p)
+	if err != nil || n != 2 {
+		t.Errorf("Expected 2 collections, got %d (%v)", n, err)
+	}
+
+	calls = nil
+	if err := d.CreateDatabase(recordingQuery("", &calls), p, false); err != nil || len(calls) != 0 {
+		t.Errorf("Expected nothing to do without drop, got %v (%v)", calls, err)
+	}
+	if err := d.CreateDatabase(recordingQuery("", &calls), p, true); err != nil || !strings.HasSuffix(calls[0], "db.dropDatabase()") {
+		t.Errorf("Expected dropDatabase, got %v (%v)", calls, err)
+	}
+}
+
+func TestMongoResolveFilters(t *testing.T) {
+	p := ConnParams{Host: "db", Port: 27017, Database: "orders"}
+	collections := "audit_2023\naudit_2024\norders\nusers"
+
+	tests := []struct {
+		name    string
+		filters DumpFilters
+		exclude []string
+	}{
+		{"exclude", DumpFilters{ExcludeTables: []string{"audit_*"}}, []string{"audit_2023", "audit_2024"}},
+		{"include", DumpFilters{IncludeTables: []string{"orders", "users"}}, []string{"audit_2023", "audit_2024"}},
+		{"include and exclude", DumpFilters{IncludeTables: []string{"*"}, ExcludeTables: []string{"users"}}, []string{"users"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (mongoDriver{}).ResolveFilters(recordingQuery(collections, new([]string)), p, tt.filters)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(got.IncludeTables) != 0 || strings.Join(got.ExcludeTables, ",") != strings.Join(tt.exclude, ",") {
+				t.Errorf("Expected excludes %v, got %+v", tt.exclude, got)
+			}
+		})
+	}
+
+	for _, f := range []DumpFilters{{SchemaOnly: true}, {Schemas: []string{"public"}}, {IncludeTables: []string{"missing"}}} {
+		if _, err := (mongoDriver{}).ResolveFilters(recordingQuery(collections, new([]string)), p, f); err == nil {
+			t.Errorf("Expected an error for %+v", f)
+		}
+	}
+}
+
+func TestMongoOptionsCheck(t *testing.T) {
+	opts := mongoOptions{replicaSet: "rs0"}
+	if err := opts.check(mongoDriver{}); err != nil {
+		t.Errorf("Expected no error for MongoDB, got %v", err)
+	}
+	if err := opts.check(postgresDriver{}); err == nil || !strings.Contains(err.Error(), "--replica-set is only supported for MongoDB") {
+		t.Errorf("Expected replica set error, got %v", err)
+	}
+}
+
+func TestRequiredFlags(t *testing.T) {
+	if got := requiredFlags(postgresDriver{}); strings.Join(got, ",") != "database,username" {
+		t.Errorf("Expected database and username for postgres, got %v", got)
+	}
+	if got := requiredFlags(redisDriver{}); len(got) != 0 {
+		t.Errorf("Expected nothing required for redis, got %v", got)
+	}
+	if got := requiredFlags(sqliteDriver{}); strings.Join(got, ",") != "path" {
+		t.Errorf("Expected path for sqlite, got %v", got)
+	}
+}
+
+func TestRedisDumpCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		p    ConnParams
+		want string
+	}{
+		{"default user", ConnParams{Host: "cache", Port: 6379}, "redis-cli -h cache -p 6379 --rdb -"},
+		{"acl user", ConnParams{Host: "cache", Port: 6380, Username: "backup"}, "redis-cli -h cache -p 6380 --user backup --rdb -"},
+		{"tls", ConnParams{Host: "cache", Port: 6379, SSLMode: "require"}, "redis-cli -h cache -p 6379 --tls --insecure --rdb -"},
+		{"verified tls", ConnParams{Host: "cache", Port: 6379, SSLMode: "verify-full"}, "redis-cli -h cache -p 6379 --tls --rdb -"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join((redisDriver{}).DumpCommand(tt.p, DumpFilters{}, DumpFormat{Name: "rdb"}), " ")
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRedisQueries(t *testing.T) {
+	d := redisDriver{}
+	p := ConnParams{Host: "cache", Port: 6379, Database: "2"}
+	info := "# Server\r\nredis_version:7.2.4\r\nredis_mode:standalone\r\nused_memory_dataset:1048576\r\n"
+
+	var calls []string
+	version, err := d.ServerVersion(recordingQuery(info, &calls), p)
+	if err != nil || version != "7.2.4" {
+		t.Errorf("Expected 7.2.4, got %q (%v)", version, err)
+	}
+	size, err := d.DatabaseSize(recordingQuery(info, &calls), p)
+	if err != nil || size != 1<<20 {
+		t.Errorf("Expected 1048576, got %d (%v)", size, err)
+	}
+	keys, err := d.CountTables(recordingQuery("42", &calls), p)
+	if err != nil || keys != 42 {
+		t.Errorf("Expected 42 keys, got %d (%v)", keys, err)
+	}
+
+	want := []string{"INFO server", "INFO memory", "-n 2 DBSIZE"}
+	for i, suffix := range want {
+		if !strings.HasSuffix(calls[i], suffix) {
+			t.Errorf("Expected call %d to end with %q, got %q", i, suffix, calls[i])
+		}
+	}
+
+	if _, err := d.ServerVersion(recordingQuery("# Server", &calls), p); err == nil {
+		t.Error("Expected an error when redis_version is missing")
+	}
+	if _, err := d.ResolveFilters(recordingQuery("", &calls), p, DumpFilters{ExcludeTables: []string{"x"}}); err == nil {
+		t.Error("Expected filters to be rejected")
+	}
+}
+
+// sqliteFixture creates a WAL-mode database with one row in dir
+func sqliteFixture(t *testing.T, dir string) string {
+	t.Helper()
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+
+	path := filepath.Join(dir, "app data.db")
+	setup := "PRAGMA journal_mode=WAL; CREATE TABLE users (name TEXT); INSERT INTO users VALUES ('ada');"
+	if out, err := exec.Command("sqlite3", path, setup).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to create database: %v: %s", err, out)
+	}
+	return path
+}
+
+// sqliteRows returns the names stored in a copy of the fixture
+func sqliteRows(t *testing.T, data []byte) string {
+	t.Helper()
+
+	copyPath := filepath.Join(t.TempDir(), "copy.db")
+	if err := os.WriteFile(copyPath, data, 0600); err != nil {
+		t.Fatalf("Failed to write copy: %v", err)
+	}
+	out, err := exec.Command("sqlite3", copyPath, "SELECT name FROM users").CombinedOutput()
+	if err != nil {
+		t.Fatalf("Expected a readable database copy, got %v: %s", err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestSQLiteDumpCommand(t *testing.T) {
+	path := sqliteFixture(t, t.TempDir())
+
+	args := (sqliteDriver{}).DumpCommand(ConnParams{Path: path}, DumpFilters{}, DumpFormat{Name: "sqlite"})
+	out, err := exec.Command(args[0], args[1:]...).Output()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.HasPrefix(out, []byte("SQLite format 3\x00")) {
+		t.Fatalf("Expected a SQLite database on stdout, got %q", out[:min(16, len(out))])
+	}
+	// The row is still in the WAL, so a plain copy of the file would miss it
+	if rows := sqliteRows(t, out); rows != "ada" {
+		t.Errorf("Expected the committed row, got %q", rows)
+	}
+}
+
+func TestSQLiteDumpCommandMissingFile(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 is not installed")
+	}
+
+	path := filepath.Join(t.TempDir(), "missing.db")
+	args := (sqliteDriver{}).DumpCommand(ConnParams{Path: path}, DumpFilters{}, DumpFormat{Name: "sqlite"})
+	if err := exec.Command(args[0], args[1:]...).Run(); err == nil {
+		t.Error("Expected an error for a missing database file")
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Error("Expected the missing database not to be created")
+	}
+}
+
+func TestSQLiteQueries(t *testing.T) {
+	d := sqliteDriver{}
+	p := ConnParams{Path: "-data.db"}
+
+	var calls []string
+	version, err := d.ServerVersion(recordingQuery("3.45.1 2024-01-30 16:01:20 e876e51a0ed5c5b3 (64-bit)", &calls), p)
+	if err != nil || version != "3.45.1" {
+		t.Errorf("Expected 3.45.1, got %q (%v)", version, err)
+	}
+	size, err := d.DatabaseSize(recordingQuery("   8192", &calls), p)
+	if err != nil || size != 8192 {
+		t.Errorf("Expected 8192, got %d (%v)", size, err)
+	}
+	if !strings.HasSuffix(calls[1], "./-data.db") {
+		t.Errorf("Expected the path to be kept from option parsing, got %q", calls[1])
+	}
+
+	if _, err := d.ResolveFilters(recordingQuery("", &calls), p, DumpFilters{SchemaOnly: true}); err == nil {
+		t.Error("Expected filters to be rejected")
+	}
+	if err := d.CreateDatabase(recordingQuery("", &calls), p, false); err == nil {
+		t.Error("Expected CreateDatabase to be rejected")
+	}
+}
+
+func TestSQLiteContainerOptions(t *testing.T) {
+	got := strings.Join((sqliteDriver{}).containerOptions(ConnParams{Path: `/srv/a,b "c"/data.db`}).cliArgs(), " ")
+	want := `--network none --entrypoint= --mount type=bind,"source=/srv/a,b ""c""","target=/srv/a,b ""c"""`
+	if got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestCheckPath(t *testing.T) {
+	if err := checkPath(sqliteDriver{}, "/srv/app/data.db"); err != nil {
+		t.Errorf("Expected --path to be accepted for sqlite, got %v", err)
+	}
+	if err := checkPath(postgresDriver{}, ""); err != nil {
+		t.Errorf("Expected no error without --path, got %v", err)
+	}
+	if err := checkPath(postgresDriver{}, "/srv/app/data.db"); err == nil {
+		t.Error("Expected --path to be rejected for postgres")
+	}
+}
+
+func TestLookupPgpass(t *testing.T) {
+	pgpass := `# comment
+db.internal:5432:orders:app:first
+*:*:*:app:wildcard
+weird\:host:5432:*:app:escaped\:colon
+`
+	tests := []struct {
+		name   string
+		p      ConnParams
+		want   string
+		wantOK bool
+	}{
+		{"Exact match", ConnParams{Host: "db.internal", Port: 5432, Database: "orders", Username: "app"}, "first", true},
+		{"Wildcard", ConnParams{Host: "other", Port: 6543, Database: "x", Username: "app"}, "wildcard", true},
+		{"No match", ConnParams{Host: "other", Port: 5432, Database: "x", Username: "nobody"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := lookupPgpass(strings.NewReader(pgpass), tt.p)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", tt.want, tt.wantOK, got, ok)
+			}
+		})
+	}
+
+	fields := splitPgpassLine(`weird\:host:5432:*:app:escaped\:colon`)
+	if len(fields) != 5 || fields[0] != "weird:host" || fields[4] != "escaped:colon" {
+		t.Errorf("Unexpected escaped fields %q", fields)
+	}
+}
+
+func TestLookupMyCnfPassword(t *testing.T) {
+	tests := []struct {
+		name   string
+		cnf    string
+		want   string
+		wantOK bool
+	}{
+		{"Client group", "[client]\nuser=root\npassword=secret\n", "secret", true},
+		{"Quoted", "[client]\npassword = \"with space\"\n", "with space", true},
+		{"Mysqldump overrides", "[client]\npassword=a\n[mysqldump]\npassword=b\n", "b", true},
+		{"MariaDB group", "[client]\npassword=a\n[client-mariadb]\npassword=b\n", "b", true},
+		{"Other group ignored", "[mysqld]\npassword=server\n", "", false},
+		{"No password", "[client]\nuser=root\n", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := lookupMyCnfPassword(strings.NewReader(tt.cnf))
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", tt.want, tt.wantOK, got, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/spf13/cobra"
+)
+
+// ageHeader starts every binary age file; the second line names the
+// first recipient stanza type
+const ageHeader = "age-encryption.org/v1\n"
+
+// encryptOptions selects how a backup is encrypted before it reaches disk
+type encryptOptions struct {
+	recipients []string
+	passphrase bool
+}
+
+// addEncryptFlags registers --encrypt-recipient and --encrypt-passphrase
+func addEncryptFlags(cmd *cobra.Command, opts *encryptOptions) {
+	cmd.Flags().StringArrayVar(&opts.recipients, "encrypt-recipient", nil, "Encrypt the backup to an age public key (age1...) or a recipients file; repeatable")
+	cmd.Flags().BoolVar(&opts.passphrase, "encrypt-passphrase", false, "Encrypt the backup with a passphrase ($CUTTER_BACKUP_PASSPHRASE or prompt)")
+	cmd.MarkFlagsMutuallyExclusive("encrypt-recipient", "encrypt-passphrase")
+}
+
+// resolveRecipients turns the encryption flags into age recipients. It
+// returns nil when encryption is off.
+func resolveRecipients(opts encryptOptions) ([]age.Recipient, error) {
+	if opts.passphrase {
+		passphrase, err := readPassphrase(true)
+		if err != nil {
+			return nil, err
+		}
+		r, err := age.NewScryptRecipient(passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("invalid passphrase: %v", err)
+		}
+		return []age.Recipient{r}, nil
+	}
+
+	var recipients []age.Recipient
+	for _, value := range opts.recipients {
+		if strings.HasPrefix(value, "age1") {
+			r, err := age.ParseX25519Recipient(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid recipient %s: %v", value, err)
+			}
+			recipients = append(recipients, r)
+			continue
+		}
+
+		file, err := os.Open(expandUserPath(value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open recipients file: %v", err)
+		}
+		parsed, err := age.ParseRecipients(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse recipients file %s: %v", value, err)
+		}
+		recipients = append(recipients, parsed...)
+	}
+	return recipients, nil
+}
+
+// expandUserPath expands a leading ~ so paths taken from profiles and
+// environment variables work like the shell-expanded flag values
+func expandUserPath(p string) string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return p
+	}
+	return expandHomePath(p, home)
+}
+
+// readPassphrase takes the backup passphrase from $CUTTER_BACKUP_PASSPHRASE
+// or the terminal, asking twice when confirm is set
+func readPassphrase(confirm bool) (string, error) {
+	if value := os.Getenv("CUTTER_BACKUP_PASSPHRASE"); value != "" {
+		return value, nil
+	}
+	if !isTerminal() {
+		return "", fmt.Errorf("no terminal to prompt for the backup passphrase; set CUTTER_BACKUP_PASSPHRASE")
+	}
+
+	fmt.Fprint(os.Stderr, "Backup passphrase: ")
+	value, err := readPassword()
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %v", err)
+	}
+	if value == "" {
+		return "", fmt.Errorf("passphrase must not be empty")
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm passphrase: ")
+		again, err := readPassword()
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase: %v", err)
+		}
+		if again != value {
+			return "", fmt.Errorf("passphrases do not match")
+		}
+	}
+	return value, nil
+}
+
+// openDecryptingReader decrypts r when it is an age file. Passphrase
+// encrypted backups ask for the passphrase; key encrypted ones need the
+// identity file. Plain streams are returned unchanged.
+func openDecryptingReader(r io.Reader, identityFile string) (io.Reader, bool, error) {
+	buffered := bufio.NewReader(r)
+
+	head, err := buffered.Peek(len(ageHeader) + len("-> scrypt "))
+	if err != nil && err != io.EOF {
+		return nil, false, fmt.Errorf("failed to read backup file: %v", err)
+	}
+	if !bytes.HasPrefix(head, []byte(ageHeader)) {
+		return buffered, false, nil
+	}
+
+	var identities []age.Identity
+	if bytes.HasPrefix(head[len(ageHeader):], []byte("-> scrypt ")) {
+		passphrase, err := readPassphrase(false)
+		if err != nil {
+			return nil, true, err
+		}
+		identity, err := age.NewScryptIdentity(passphrase)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid passphrase: %v", err)
+		}
+		identities = append(identities, identity)
+	} else {
+		if identityFile == "" {
+			return nil, true, fmt.Errorf("backup is encrypted; pass --identity with an age identity file")
+		}
+		file, err := os.Open(expandUserPath(identityFile))
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to open identity file: %v", err)
+		}
+		identities, err = age.ParseIdentities(file)
+		file.Close()
+		if err != nil {
+			return nil, true, fmt.Errorf("failed to parse identity file: %v", err)
+		}
+	}
+
+	plain, err := age.Decrypt(buffered, identities...)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to decrypt backup: %v", err)
+	}
+	return plain, true, nil
+}
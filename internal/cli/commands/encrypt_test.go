@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+// encryptedDump writes a gzip compressed dump of content encrypted to recipients
+func encryptedDump(t *testing.T, content string, recipients []age.Recipient) string {
+	t.Helper()
+	fakeDocker(t)
+
+	output := filepath.Join(t.TempDir(), "backup.sql.gz.age")
+	if _, err := streamDump("pg_dump", commandDump(exec.Command(dockerBinary, content)), localStore{dir: filepath.Dir(output)}, filepath.Base(output), compressionOptions{codec: "gzip"}, recipients, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return output
+}
+
+// decryptDump runs path through the same readers as db restore
+func decryptDump(t *testing.T, path, identityFile string) (string, error) {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open dump: %v", err)
+	}
+	defer file.Close()
+
+	decrypted, encrypted, err := openDecryptingReader(file, identityFile)
+	if err != nil {
+		return "", err
+	}
+	if !encrypted {
+		t.Error("Expected the dump to be detected as encrypted")
+	}
+
+	reader, compression, err := openDumpReader(decrypted)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	if compression != "gzip" {
+		t.Error("Expected gzip inside the encrypted dump")
+	}
+
+	data, err := io.ReadAll(reader)
+	return string(data), err
+}
+
+func TestEncryptedBackupRoundTripWithIdentity(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("Failed to generate identity: %v", err)
+	}
+	identityFile := filepath.Join(t.TempDir(), "key.txt")
+	os.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600)
+
+	recipients, err := resolveRecipients(encryptOptions{recipients: []string{identity.Recipient().String()}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	output := encryptedDump(t, "CREATE TABLE secrets;", recipients)
+
+	raw, _ := os.ReadFile(output)
+	if bytes.Contains(raw, []byte("secrets")) || !bytes.HasPrefix(raw, []byte(ageHeader)) {
+		t.Fatal("Expected only age ciphertext on disk")
+	}
+
+	got, err := decryptDump(t, output, identityFile)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got != "CREATE TABLE secrets;\n" {
+		t.Errorf("Expected original dump, got %q", got)
+	}
+
+	if _, err := decryptDump(t, output, ""); err == nil || !strings.Contains(err.Error(), "--identity") {
+		t.Errorf("Expected missing identity error, got %v", err)
+	}
+}
+
+func TestEncryptedBackupRoundTripWithPassphrase(t *testing.T) {
+	stubTerminal(t, false, "", nil)
+	t.Setenv("CUTTER_BACKUP_PASSPHRASE", "correct horse")
+
+	recipients, err := resolveRecipients(encryptOptions{passphrase: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	output := encryptedDump(t, "SELECT 1;", recipients)
+
+	got, err := decryptDump(t, output, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got != "SELECT 1;\n" {
+		t.Errorf("Expected original dump, got %q", got)
+	}
+
+	t.Setenv("CUTTER_BACKUP_PASSPHRASE", "wrong")
+	if _, err := decryptDump(t, output, ""); err == nil {
+		t.Error("Expected wrong passphrase to fail")
+	}
+}
+
+func TestOpenDecryptingReaderPassesPlainStreams(t *testing.T) {
+	reader, encrypted, err := openDecryptingReader(strings.NewReader("SELECT 1;"), "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if encrypted {
+		t.Error("Expected plain stream not to be treated as encrypted")
+	}
+
+	data, _ := io.ReadAll(reader)
+	if string(data) != "SELECT 1;" {
+		t.Errorf("Expected stream unchanged, got %q", data)
+	}
+}
+
+func TestResolveRecipients(t *testing.T) {
+	identity, _ := age.GenerateX25519Identity()
+	recipientsFile := filepath.Join(t.TempDir(), "recipients.txt")
+	os.WriteFile(recipientsFile, []byte("# team\n"+identity.Recipient().String()+"\n"), 0600)
+
+	tests := []struct {
+		name    string
+		opts    encryptOptions
+		want    int
+		wantErr bool
+	}{
+		{"Disabled", encryptOptions{}, 0, false},
+		{"Public key", encryptOptions{recipients: []string{identity.Recipient().String()}}, 1, false},
+		{"Recipients file", encryptOptions{recipients: []string{recipientsFile}}, 1, false},
+		{"Invalid key", encryptOptions{recipients: []string{"age1notakey"}}, 0, true},
+		{"Missing file", encryptOptions{recipients: []string{"/nonexistent/recipients"}}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveRecipients(tt.opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if len(got) != tt.want {
+				t.Errorf("Expected %d recipients, got %d", tt.want, len(got))
+			}
+		})
+	}
+}
+
+func TestReadPassphrase(t *testing.T) {
+	t.Setenv("CUTTER_BACKUP_PASSPHRASE", "")
+
+	stubTerminal(t, false, "", nil)
+	if _, err := readPassphrase(true); err == nil || !strings.Contains(err.Error(), "CUTTER_BACKUP_PASSPHRASE") {
+		t.Errorf("Expected no terminal error, got %v", err)
+	}
+
+	prompts := stubTerminal(t, true, "s3cret", nil)
+	got, err := readPassphrase(true)
+	if err != nil || got != "s3cret" {
+		t.Errorf("Expected s3cret, got %q (%v)", got, err)
+	}
+	if *prompts != 2 {
+		t.Errorf("Expected passphrase to be confirmed, got %d prompts", *prompts)
+	}
+
+	stubTerminal(t, true, "", nil)
+	if _, err := readPassphrase(false); err == nil {
+		t.Error("Expected empty passphrase to be rejected")
+	}
+}
+
+func TestResolveRecipientsExpandsHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	identity, _ := age.GenerateX25519Identity()
+	os.WriteFile(filepath.Join(home, "recipients.txt"), []byte(identity.Recipient().String()+"\n"), 0600)
+
+	got, err := resolveRecipients(encryptOptions{recipients: []string{"~/recipients.txt"}})
+	if err != nil || len(got) != 1 {
+		t.Errorf("Expected one recipient from ~/recipients.txt, got %d (%v)", len(got), err)
+	}
+}
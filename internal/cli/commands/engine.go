@@ -0,0 +1,687 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// engineAPIVersion is the Docker Engine API version of every request. 1.41
+// is Docker 20.10, the first to resolve host-gateway, and is also spoken by
+// Podman's Docker-compatible service.
+const engineAPIVersion = "v1.41"
+
+// engineAPI talks to a Docker engine over its API socket
+type engineAPI struct {
+	// network and address are where the engine listens, such as unix and
+	// /var/run/docker.sock
+	network string
+	address string
+	http    *http.Client
+}
+
+// engineError is an error status returned by the engine
+type engineError struct {
+	status  int
+	message string
+}
+
+func (e *engineError) Error() string {
+	return e.message
+}
+
+// defaultEngineHosts are the sockets tried when neither DOCKER_HOST nor a
+// docker context names the engine: the system daemon, a rootless daemon and
+// Docker Desktop
+func defaultEngineHosts() []string {
+	hosts := []string{"unix:///var/run/docker.sock"}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		hosts = append(hosts, "unix://"+filepath.Join(dir, "docker.sock"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		hosts = append(hosts, "unix://"+filepath.Join(home, ".docker", "run", "docker.sock"))
+	}
+	return hosts
+}
+
+// openEngineAPI connects to the engine the docker CLI would use. ok is
+// false when that engine does not answer or is reached in a way only the
+// CLI handles, such as ssh:// or TLS.
+func openEngineAPI() (*engineAPI, bool) {
+	hosts := defaultEngineHosts()
+	if host := os.Getenv("DOCKER_HOST"); host != "" {
+		hosts = []string{host}
+	} else if host, ok := dockerContextHost(); ok {
+		hosts = []string{host}
+	}
+
+	for _, host := range hosts {
+		api, err := newEngineAPI(host)
+		if err != nil {
+			return nil, false
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		err = api.call(ctx, http.MethodGet, "/_ping", nil, nil, nil)
+		cancel()
+		if err == nil {
+			return api, true
+		}
+	}
+	return nil, false
+}
+
+// newEngineAPI returns a client for a DOCKER_HOST value
+func newEngineAPI(host string) (*engineAPI, error) {
+	api := &engineAPI{}
+	switch {
+	case strings.HasPrefix(host, "unix://"):
+		api.network, api.address = "unix", strings.TrimPrefix(host, "unix://")
+	case strings.HasPrefix(host, "tcp://") && os.Getenv("DOCKER_TLS_VERIFY") == "":
+		api.network, api.address = "tcp", strings.TrimPrefix(host, "tcp://")
+	default:
+		return nil, fmt.Errorf("unsupported Docker host: %s", host)
+	}
+
+	api.http = &http.Client{Transport: &http.Transport{DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return api.dial(ctx)
+	}}}
+	return api, nil
+}
+
+func (a *engineAPI) dial(ctx context.Context) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, a.network, a.address)
+}
+
+func (a *engineAPI) url(path string, query url.Values) string {
+	u := "http://docker/" + engineAPIVersion + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// request sends body as JSON and returns the response of a successful
+// status; an error status becomes an *engineError
+func (a *engineAPI) request(ctx context.Context, method, path string, query url.Values, body any, header http.Header) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.url(path, query), reader)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	return resp, nil
+}
+
+// responseError reads the engine's message from an error response
+func responseError(resp *http.Response) error {
+	var body struct {
+		Message string `json:"message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &body) != nil || body.Message == "" {
+		body.Message = strings.TrimSpace(resp.Status + " " + string(data))
+	}
+	return &engineError{status: resp.StatusCode, message: body.Message}
+}
+
+// call makes a request and decodes its JSON response into out unless out
+// is nil
+func (a *engineAPI) call(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	resp, err := a.request(ctx, method, path, query, body, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// imageExists reports whether image is in the engine's image store
+func (a *engineAPI) imageExists(ctx context.Context, image string) (bool, error) {
+	err := a.call(ctx, http.MethodGet, "/images/"+image+"/json", nil, nil, nil)
+	var status *engineError
+	if errors.As(err, &status) && status.status == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// pullMessage is one line of the engine's pull progress stream
+type pullMessage struct {
+	Status         string `json:"status"`
+	ID             string `json:"id"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+	Error string `json:"error"`
+}
+
+// pull downloads image, calling progress with the bytes downloaded so far
+// and the size of the layers seen so far
+func (a *engineAPI) pull(ctx context.Context, image string, progress func(current, total int64)) error {
+	repository, tag := splitImageTag(image)
+	query := url.Values{"fromImage": {repository}, "tag": {tag}}
+	header := http.Header{}
+	if auth := registryAuth(imageRegistry(repository)); auth != "" {
+		header.Set("X-Registry-Auth", auth)
+	}
+
+	resp, err := a.request(ctx, http.MethodPost, "/images/create", query, nil, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Layers report their own progress; a finished layer counts in full
+	type layer struct{ current, total int64 }
+	layers := map[string]*layer{}
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg pullMessage
+		if err := decoder.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+		if msg.ID == "" {
+			continue
+		}
+
+		l, ok := layers[msg.ID]
+		if !ok {
+			l = &layer{}
+			layers[msg.ID] = l
+		}
+		switch msg.Status {
+		case "Downloading":
+			l.current, l.total = msg.ProgressDetail.Current, msg.ProgressDetail.Total
+		case "Download complete", "Pull complete", "Already exists":
+			l.current = l.total
+		default:
+			continue
+		}
+
+		var current, total int64
+		for _, l := range layers {
+			current += l.current
+			total += l.total
+		}
+		progress(current, total)
+	}
+}
+
+// splitImageTag splits image into its repository and its tag or digest,
+// which is latest when not given
+func splitImageTag(image string) (string, string) {
+	if repository, digest, ok := strings.Cut(image, "@"); ok {
+		return repository, digest
+	}
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		return image[:i], image[i+1:]
+	}
+	return image, "latest"
+}
+
+// engineMount is a mount in a container's host config
+type engineMount struct {
+	Type   string
+	Source string
+	Target string
+}
+
+// engineHostConfig is the host part of a container's configuration
+type engineHostConfig struct {
+	NetworkMode string        `json:",omitempty"`
+	ExtraHosts  []string      `json:",omitempty"`
+	Mounts      []engineMount `json:",omitempty"`
+	Memory      int64         `json:",omitempty"`
+	NanoCpus    int64         `json:",omitempty"`
+	// AutoRemove has the engine remove the container once it stops, even
+	// when cutter is killed before it can
+	AutoRemove bool
+}
+
+// engineContainerConfig is the body of a container create request
+type engineContainerConfig struct {
+	Image        string
+	Cmd          []string
+	Entrypoint   []string `json:",omitempty"`
+	Env          []string `json:",omitempty"`
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+	OpenStdin    bool
+	StdinOnce    bool
+	HostConfig   engineHostConfig
+}
+
+// createContainer creates a container named name and returns its ID
+func (a *engineAPI) createContainer(ctx context.Context, name string, config engineContainerConfig) (string, error) {
+	var created struct{ Id string }
+	if err := a.call(ctx, http.MethodPost, "/containers/create", url.Values{"name": {name}}, config, &created); err != nil {
+		return "", err
+	}
+	return created.Id, nil
+}
+
+// attach connects to the container's stdout and stderr, and stdin when
+// asked, before it starts
+func (a *engineAPI) attach(ctx context.Context, id string, stdin bool) (net.Conn, *bufio.Reader, error) {
+	query := url.Values{"stream": {"1"}, "stdout": {"1"}, "stderr": {"1"}}
+	if stdin {
+		query.Set("stdin", "1")
+	}
+	return a.hijack(ctx, "/containers/"+id+"/attach", query, nil)
+}
+
+// hijack sends a request that the engine upgrades to a raw stream, as
+// attach and exec start do. The returned reader carries the multiplexed
+// output and writes to the connection reach stdin.
+func (a *engineAPI) hijack(ctx context.Context, path string, query url.Values, body any) (net.Conn, *bufio.Reader, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(http.MethodPost, a.url(path, query), reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	conn, err := a.dial(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	buffered := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(buffered, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		defer conn.Close()
+		return nil, nil, responseError(resp)
+	}
+	return conn, buffered, nil
+}
+
+// startContainer starts a created container
+func (a *engineAPI) startContainer(ctx context.Context, id string) error {
+	return a.call(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil, nil)
+}
+
+// waitContainer registers a wait for the container to be removed, which
+// AutoRemove does once it stops. The engine sends the response headers as
+// soon as the wait is in place, so starting the container afterwards
+// cannot miss its exit. waitExitCode reads the result.
+func (a *engineAPI) waitContainer(ctx context.Context, id string) (*http.Response, error) {
+	return a.request(ctx, http.MethodPost, "/containers/"+id+"/wait", url.Values{"condition": {"removed"}}, nil, nil)
+}
+
+// waitExitCode reads the exit code from a waitContainer response
+func waitExitCode(resp *http.Response) (int, error) {
+	defer resp.Body.Close()
+	var result struct {
+		StatusCode int
+		Error      *struct{ Message string }
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	if result.Error != nil && result.Error.Message != "" {
+		return 0, errors.New(result.Error.Message)
+	}
+	return result.StatusCode, nil
+}
+
+// removeContainer force-removes a container, stopping it if it still runs.
+// It gets its own deadline so that it also runs after a cancellation.
+func (a *engineAPI) removeContainer(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return a.call(ctx, http.MethodDelete, "/containers/"+id, url.Values{"force": {"1"}}, nil, nil)
+}
+
+// engineExecConfig is the body of an exec create request
+type engineExecConfig struct {
+	Cmd          []string
+	Env          []string `json:",omitempty"`
+	AttachStdin  bool
+	AttachStdout bool
+	AttachStderr bool
+}
+
+// inspectContainer reports whether the container named name is running
+func (a *engineAPI) inspectContainer(ctx context.Context, name string) (bool, error) {
+	var container struct {
+		State struct{ Running bool }
+	}
+	if err := a.call(ctx, http.MethodGet, "/containers/"+name+"/json", nil, nil, &container); err != nil {
+		return false, err
+	}
+	return container.State.Running, nil
+}
+
+// createExec prepares config to run in the container named name and
+// returns the exec ID
+func (a *engineAPI) createExec(ctx context.Context, name string, config engineExecConfig) (string, error) {
+	var created struct{ Id string }
+	if err := a.call(ctx, http.MethodPost, "/containers/"+name+"/exec", nil, config, &created); err != nil {
+		return "", err
+	}
+	return created.Id, nil
+}
+
+// startExec starts an exec and connects to its streams
+func (a *engineAPI) startExec(ctx context.Context, id string) (net.Conn, *bufio.Reader, error) {
+	return a.hijack(ctx, "/exec/"+id+"/start", nil, map[string]bool{"Detach": false, "Tty": false})
+}
+
+// execExitCode returns the exit code of a finished exec. The engine can
+// still report it running for a moment after its streams closed.
+func (a *engineAPI) execExitCode(ctx context.Context, id string) (int, error) {
+	for {
+		var exec struct {
+			Running  bool
+			ExitCode int
+		}
+		if err := a.call(ctx, http.MethodGet, "/exec/"+id+"/json", nil, nil, &exec); err != nil {
+			return 0, err
+		}
+		if !exec.Running {
+			return exec.ExitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// copyStreams feeds input, when not nil, to a hijacked connection and
+// copies its output to stdout and stderr until the process ends. Canceling
+// ctx closes the connection.
+func copyStreams(ctx context.Context, conn net.Conn, stream io.Reader, input io.Reader, stdout, stderr io.Writer) error {
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if input != nil {
+		go func() {
+			io.Copy(conn, input)
+			if closer, ok := conn.(interface{ CloseWrite() error }); ok {
+				closer.CloseWrite()
+			}
+		}()
+	}
+
+	if err := demuxStream(stream, stdout, stderr); err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return err
+	}
+	return nil
+}
+
+// demuxStream copies the engine's multiplexed attach stream to stdout and
+// stderr. Each frame starts with the stream it belongs to and its size.
+func demuxStream(r io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		w := stdout
+		if header[0] == 2 {
+			w = stderr
+		}
+		if _, err := io.CopyN(w, r, int64(binary.BigEndian.Uint32(header[4:]))); err != nil {
+			return err
+		}
+	}
+}
+
+// engineImages records the outcome of making each image available during
+// this run, so the pull policy is applied once per image
+type engineImages struct {
+	mu     sync.Mutex
+	result map[string]error
+}
+
+// engineClient runs database client tools inside throwaway containers it
+// creates, attaches to and removes through the Docker Engine API
+type engineClient struct {
+	api   *engineAPI
+	image string
+	spec  containerSpec
+	env   []string
+	// pullPolicy is always, missing or never
+	pullPolicy string
+	images     *engineImages
+	// ctx, when set, stops running containers once it is canceled
+	ctx context.Context
+}
+
+// newEngineClient returns a client for containers of image on api
+func newEngineClient(ctx context.Context, api *engineAPI, image string, spec containerSpec, pullPolicy string) engineClient {
+	return engineClient{api: api, image: image, spec: spec, pullPolicy: pullPolicy,
+		images: &engineImages{result: map[string]error{}}, ctx: ctx}
+}
+
+// query runs a client command and returns its trimmed stdout
+func (c engineClient) query(args []string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	if err := c.start(args, nil, &stdout, &stderr); err != nil {
+		return "", fmt.Errorf("%s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// run executes a client command with stdin attached to input
+func (c engineClient) run(args []string, input io.Reader) error {
+	return c.start(args, input, os.Stdout, os.Stderr)
+}
+
+// dump runs a client command with its stdout connected to stdout
+func (c engineClient) dump(args []string, stdout io.Writer) error {
+	return c.start(args, nil, stdout, os.Stderr)
+}
+
+// withImage returns a copy of c running image
+func (c engineClient) withImage(image string) dumpClient {
+	c.image = image
+	return c
+}
+
+// clientImage returns the image c runs
+func (c engineClient) clientImage() string {
+	return c.image
+}
+
+// config returns the create request for a container running args
+func (c engineClient) config(args []string, interactive bool) engineContainerConfig {
+	config := engineContainerConfig{
+		Image:        c.image,
+		Cmd:          args,
+		Env:          c.env,
+		AttachStdin:  interactive,
+		AttachStdout: true,
+		AttachStderr: true,
+		OpenStdin:    interactive,
+		StdinOnce:    interactive,
+		HostConfig: engineHostConfig{
+			NetworkMode: c.spec.network,
+			ExtraHosts:  c.spec.addHosts,
+			Memory:      c.spec.memory,
+			NanoCpus:    int64(c.spec.cpus * 1e9),
+			AutoRemove:  true,
+		},
+	}
+	if c.spec.noEntrypoint {
+		config.Entrypoint = []string{""}
+	}
+	for _, dir := range c.spec.mounts {
+		config.HostConfig.Mounts = append(config.HostConfig.Mounts, engineMount{Type: "bind", Source: dir, Target: dir})
+	}
+	return config
+}
+
+// start runs args in a new container, streaming input to its stdin when
+// not nil. The engine removes the container once it stops; cutter removes
+// it itself when the run fails or is canceled first.
+func (c engineClient) start(args []string, input io.Reader, stdout, stderr io.Writer) error {
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := c.ensureImage(ctx); err != nil {
+		return err
+	}
+
+	id, err := c.api.createContainer(ctx, containerName(), c.config(args, input != nil))
+	if err != nil {
+		return fmt.Errorf("failed to create container from %s: %v", c.image, err)
+	}
+	defer c.api.removeContainer(id)
+
+	conn, stream, err := c.api.attach(ctx, id, input != nil)
+	if err != nil {
+		return fmt.Errorf("failed to attach to container: %v", err)
+	}
+	defer conn.Close()
+
+	wait, err := c.api.waitContainer(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to wait for container: %v", err)
+	}
+	defer wait.Body.Close()
+
+	if err := c.api.startContainer(ctx, id); err != nil {
+		return fmt.Errorf("failed to start container: %v", err)
+	}
+	// Removing the container stops the tool once ctx is canceled
+	if err := copyStreams(ctx, conn, stream, input, stdout, stderr); err != nil {
+		return err
+	}
+	status, err := waitExitCode(wait)
+	if err != nil {
+		return fmt.Errorf("failed to wait for container: %v", err)
+	}
+	if status != 0 {
+		return fmt.Errorf("exit status %d", status)
+	}
+	return nil
+}
+
+// ensureImage makes c.image available as c.pullPolicy says
+func (c engineClient) ensureImage(ctx context.Context) error {
+	c.images.mu.Lock()
+	defer c.images.mu.Unlock()
+	if err, done := c.images.result[c.image]; done {
+		return err
+	}
+
+	err := c.prepareImage(ctx)
+	c.images.result[c.image] = err
+	return err
+}
+
+// prepareImage checks for c.image and pulls it when the policy asks to
+func (c engineClient) prepareImage(ctx context.Context) error {
+	if c.pullPolicy != "always" {
+		exists, err := c.api.imageExists(ctx, c.image)
+		if err != nil {
+			return fmt.Errorf("failed to inspect image %s: %v", c.image, err)
+		}
+		if exists {
+			return nil
+		}
+		if c.pullPolicy == "never" {
+			return fmt.Errorf("image %s is not present and --pull-policy is never", c.image)
+		}
+	}
+	return c.pullImage(ctx)
+}
+
+// pullImage pulls c.image, showing the download on a live line when stdout
+// is a terminal
+func (c engineClient) pullImage(ctx context.Context) error {
+	fmt.Printf("Pulling %s...\n", c.image)
+	live := stdoutIsTerminal()
+	var shown time.Time
+	err := c.api.pull(ctx, c.image, func(current, total int64) {
+		if !live || time.Since(shown) < 200*time.Millisecond {
+			return
+		}
+		shown = time.Now()
+		fmt.Printf("\r\033[K  %s of %s", formatBytes(current), formatBytes(total))
+	})
+	if !shown.IsZero() {
+		fmt.Print("\r\033[K")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to pull %s: %v", c.image, err)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// dockerHubRegistry is the key docker login stores Docker Hub credentials
+// under
+const dockerHubRegistry = "https://index.docker.io/v1/"
+
+// dockerConfig is the part of the docker CLI's config.json cutter reads
+type dockerConfig struct {
+	CurrentContext string `json:"currentContext"`
+	Auths          map[string]struct {
+		Auth          string `json:"auth"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredsStore  string            `json:"credsStore"`
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// dockerConfigDir returns the docker CLI's configuration directory
+func dockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// loadDockerConfig reads config.json, which is empty when missing
+func loadDockerConfig() dockerConfig {
+	var config dockerConfig
+	if data, err := os.ReadFile(filepath.Join(dockerConfigDir(), "config.json")); err == nil {
+		json.Unmarshal(data, &config)
+	}
+	return config
+}
+
+// dockerContextHost returns the engine address of the current docker
+// context, chosen by DOCKER_CONTEXT or docker context use. ok is false for
+// the default context.
+func dockerContextHost() (string, bool) {
+	name := os.Getenv("DOCKER_CONTEXT")
+	if name == "" {
+		name = loadDockerConfig().CurrentContext
+	}
+	if name == "" || name == "default" {
+		return "", false
+	}
+
+	// Context metadata lives in a directory named by the hash of its name
+	sum := sha256.Sum256([]byte(name))
+	data, err := os.ReadFile(filepath.Join(dockerConfigDir(), "contexts", "meta", hex.EncodeToString(sum[:]), "meta.json"))
+	if err != nil {
+		return "", false
+	}
+	var meta struct {
+		Endpoints map[string]struct{ Host string }
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return "", false
+	}
+	host := meta.Endpoints["docker"].Host
+	return host, host != ""
+}
+
+// imageRegistry returns the registry an image repository is pulled from
+func imageRegistry(repository string) string {
+	first, _, ok := strings.Cut(repository, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return dockerHubRegistry
+}
+
+// registryCredentials is the JSON carried by the X-Registry-Auth header
+type registryCredentials struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+	ServerAddress string `json:"serveraddress"`
+}
+
+// registryAuth returns the X-Registry-Auth header for pulling from
+// registry with the credentials docker login stored, either in a
+// credential helper or in config.json, or "" when there are none
+func registryAuth(registry string) string {
+	config := loadDockerConfig()
+	creds := registryCredentials{ServerAddress: registry}
+
+	helper := config.CredsStore
+	if name, ok := config.CredHelpers[registry]; ok {
+		helper = name
+	}
+	if helper != "" {
+		creds.Username, creds.Password = credentialHelper(helper, registry)
+	}
+	if creds.Username == "" {
+		entry, ok := config.Auths[registry]
+		if !ok {
+			entry = config.Auths["https://"+registry]
+		}
+		if decoded, err := base64.StdEncoding.DecodeString(entry.Auth); err == nil {
+			creds.Username, creds.Password, _ = strings.Cut(string(decoded), ":")
+		}
+		creds.IdentityToken = entry.IdentityToken
+	}
+
+	// Helpers hand out identity tokens under this username
+	if creds.Username == "<token>" {
+		creds.Username, creds.IdentityToken, creds.Password = "", creds.Password, ""
+	}
+	if creds.Username == "" && creds.IdentityToken == "" {
+		return ""
+	}
+	data, _ := json.Marshal(creds)
+	return base64.URLEncoding.EncodeToString(data)
+}
+
+// credentialHelper asks docker-credential-<helper> for the credentials of
+// registry; both are empty when it has none
+func credentialHelper(helper, registry string) (string, string) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(registry)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", ""
+	}
+	var creds struct {
+		Username string
+		Secret   string
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(out), &creds); err != nil {
+		return "", ""
+	}
+	return creds.Username, creds.Secret
+}
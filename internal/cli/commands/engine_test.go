@@ -0,0 +1,598 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// noEngineAPI points DOCKER_HOST at a socket nobody listens on, so that
+// detection falls back to the container CLIs
+func noEngineAPI(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix://"+filepath.Join(t.TempDir(), "missing.sock"))
+}
+
+// fakeEngine is a Docker Engine API on a unix socket whose containers run
+// their command on this machine
+type fakeEngine struct {
+	api *engineAPI
+
+	mu         sync.Mutex
+	images     map[string]bool
+	pulls      []string
+	pullAuth   []string
+	pullError  string
+	created    []engineContainerConfig
+	containers map[string]*fakeContainer
+	removed    []string
+	// running holds the existing containers by name and whether they run
+	running map[string]bool
+	execs   map[string]*fakeContainer
+}
+
+// fakeContainer is a created container and, once started, its process
+type fakeContainer struct {
+	config engineContainerConfig
+	conn   net.Conn
+	stream *bufio.ReadWriter
+	// writeMu keeps stdout and stderr frames whole
+	writeMu sync.Mutex
+	cmd     *exec.Cmd
+	done    chan struct{}
+	status  int
+}
+
+// newFakeEngine serves a fake engine holding images and sets DOCKER_HOST
+// to it. DOCKER_CONFIG is emptied so no real credentials are read.
+func newFakeEngine(t *testing.T, images ...string) *fakeEngine {
+	t.Helper()
+
+	// Unix socket paths are short, so stay out of the long test directory
+	dir, err := os.MkdirTemp("", "engine")
+	if err != nil {
+		t.Fatalf("Failed to create socket directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	e := &fakeEngine{images: map[string]bool{}, containers: map[string]*fakeContainer{},
+		running: map[string]bool{}, execs: map[string]*fakeContainer{}}
+	for _, image := range images {
+		e.images[image] = true
+	}
+	server := &http.Server{Handler: http.HandlerFunc(e.serve)}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	t.Setenv("DOCKER_HOST", "unix://"+socket)
+	t.Setenv("DOCKER_CONFIG", t.TempDir())
+	if e.api, err = newEngineAPI("unix://" + socket); err != nil {
+		t.Fatalf("Failed to create API client: %v", err)
+	}
+	return e
+}
+
+func (e *fakeEngine) serve(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/"+engineAPIVersion)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch {
+	case path == "/_ping":
+		io.WriteString(w, "OK")
+	case path == "/version":
+		io.WriteString(w, `{"Components":[{"Name":"Engine"}]}`)
+	case path == "/info":
+		io.WriteString(w, `{"OperatingSystem":"Ubuntu 24.04 LTS","SecurityOptions":["name=seccomp,profile=builtin","name=rootless"]}`)
+	case strings.HasPrefix(path, "/images/") && strings.HasSuffix(path, "/json"):
+		image := strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json")
+		if !e.images[image] {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"message":"No such image: %s"}`, image)
+			return
+		}
+		io.WriteString(w, `{}`)
+	case path == "/images/create":
+		image := r.URL.Query().Get("fromImage") + ":" + r.URL.Query().Get("tag")
+		e.pulls = append(e.pulls, image)
+		e.pullAuth = append(e.pullAuth, r.Header.Get("X-Registry-Auth"))
+		io.WriteString(w, `{"status":"Pulling from library/postgres","id":"16-alpine"}`+"\n")
+		if e.pullError != "" {
+			fmt.Fprintf(w, `{"errorDetail":{"message":%q},"error":%q}`+"\n", e.pullError, e.pullError)
+			return
+		}
+		io.WriteString(w, `{"status":"Downloading","progressDetail":{"current":512,"total":2048},"id":"a1"}`+"\n")
+		io.WriteString(w, `{"status":"Pull complete","progressDetail":{},"id":"a1"}`+"\n")
+		e.images[image] = true
+	case path == "/containers/create":
+		var config engineContainerConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !e.images[config.Image] {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"message":"No such image: %s"}`, config.Image)
+			return
+		}
+		id := fmt.Sprintf("c%d", len(e.created))
+		e.created = append(e.created, config)
+		e.containers[id] = &fakeContainer{config: config, done: make(chan struct{})}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"Id":%q}`, id)
+	case r.Method == http.MethodDelete:
+		id := strings.TrimPrefix(path, "/containers/")
+		c := e.containers[id]
+		if c == nil {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(w, `{"message":"No such container: %s"}`, id)
+			return
+		}
+		if c.cmd != nil && c.cmd.Process != nil {
+			c.cmd.Process.Kill()
+		}
+		delete(e.containers, id)
+		e.removed = append(e.removed, id)
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(path, "/exec/"):
+		e.serveExec(w, r, strings.TrimPrefix(path, "/exec/"))
+	default:
+		id, action, _ := strings.Cut(strings.TrimPrefix(path, "/containers/"), "/")
+		if _, ok := e.running[id]; ok {
+			e.serveExisting(w, r, id, action)
+			return
+		}
+		c := e.containers[id]
+		if c == nil {
+			http.NotFound(w, r)
+			return
+		}
+		switch action {
+		case "attach":
+			conn, stream, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				return
+			}
+			io.WriteString(conn, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+			c.conn, c.stream = conn, stream
+		case "start":
+			c.start()
+			w.WriteHeader(http.StatusNoContent)
+		case "wait":
+			// The engine confirms the wait before the container starts
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			e.mu.Unlock()
+			<-c.done
+			e.mu.Lock()
+			if c.config.HostConfig.AutoRemove && e.containers[id] != nil {
+				delete(e.containers, id)
+				e.removed = append(e.removed, id)
+			}
+			fmt.Fprintf(w, `{"StatusCode":%d}`, c.status)
+		}
+	}
+}
+
+// serveExisting inspects an existing container and creates execs in it
+func (e *fakeEngine) serveExisting(w http.ResponseWriter, r *http.Request, name, action string) {
+	switch action {
+	case "json":
+		fmt.Fprintf(w, `{"Name":"/%s","State":{"Running":%v}}`, name, e.running[name])
+	case "exec":
+		var config engineExecConfig
+		if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		id := fmt.Sprintf("e%d", len(e.execs))
+		e.execs[id] = &fakeContainer{
+			config: engineContainerConfig{Cmd: config.Cmd, Env: config.Env, OpenStdin: config.AttachStdin},
+			done:   make(chan struct{}),
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"Id":%q}`, id)
+	}
+}
+
+// serveExec starts an exec on a hijacked connection and reports its exit
+// code once it finished
+func (e *fakeEngine) serveExec(w http.ResponseWriter, r *http.Request, path string) {
+	id, action, _ := strings.Cut(path, "/")
+	c := e.execs[id]
+	if c == nil {
+		http.NotFound(w, r)
+		return
+	}
+	switch action {
+	case "start":
+		// The engine reads the start options before taking over the connection
+		io.Copy(io.Discard, r.Body)
+		conn, stream, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		c.conn, c.stream = conn, stream
+		c.start()
+	case "json":
+		e.mu.Unlock()
+		<-c.done
+		e.mu.Lock()
+		fmt.Fprintf(w, `{"Running":false,"ExitCode":%d}`, c.status)
+	}
+}
+
+// start runs the container's command with its output framed onto the
+// attach connection
+func (c *fakeContainer) start() {
+	c.cmd = exec.Command(c.config.Cmd[0], c.config.Cmd[1:]...)
+	c.cmd.Env = append(os.Environ(), c.config.Env...)
+	c.cmd.Stdout = frameWriter{c, 1}
+	c.cmd.Stderr = frameWriter{c, 2}
+	if c.config.OpenStdin {
+		c.cmd.Stdin = c.stream
+	}
+	if err := c.cmd.Start(); err != nil {
+		c.status = 127
+		c.conn.Close()
+		close(c.done)
+		return
+	}
+	go func() {
+		c.cmd.Wait()
+		c.status = c.cmd.ProcessState.ExitCode()
+		c.conn.Close()
+		close(c.done)
+	}()
+}
+
+// frameWriter writes one stream of a container in the engine's framing
+type frameWriter struct {
+	c      *fakeContainer
+	stream byte
+}
+
+func (w frameWriter) Write(p []byte) (int, error) {
+	w.c.writeMu.Lock()
+	defer w.c.writeMu.Unlock()
+	header := make([]byte, 8)
+	header[0] = w.stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(p)))
+	if _, err := w.c.conn.Write(append(header, p...)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func TestEngineClientRunsContainers(t *testing.T) {
+	e := newFakeEngine(t, "postgres:16-alpine")
+	spec := containerSpec{network: "host", addHosts: []string{"db:10.0.0.5"}, noEntrypoint: true,
+		mounts: []string{"/srv/data"}, memory: 512 << 20, cpus: 1.5}
+	client := newEngineClient(context.Background(), e.api, "postgres:16-alpine", spec, "missing")
+	client.env = []string{"PGPASSWORD=s3cr3t"}
+
+	got, err := client.query([]string{"sh", "-c", `printf %s "$PGPASSWORD"; echo noise >&2`})
+	if err != nil || got != "s3cr3t" {
+		t.Errorf("Expected the password from the container env, got %q (%v)", got, err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if err := client.start([]string{"sh", "-c", "tr a-z A-Z; echo done >&2"}, strings.NewReader("restore me"), &stdout, &stderr); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stdout.String() != "RESTORE ME" || stderr.String() != "done\n" {
+		t.Errorf("Expected stdin piped through and streams split, got %q and %q", stdout.String(), stderr.String())
+	}
+
+	_, err = client.query([]string{"sh", "-c", "echo access denied >&2; exit 3"})
+	if err == nil || err.Error() != "sh failed: exit status 3: access denied" {
+		t.Errorf("Expected the exit status and stderr, got %v", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	want := engineHostConfig{NetworkMode: "host", ExtraHosts: []string{"db:10.0.0.5"},
+		Mounts: []engineMount{{Type: "bind", Source: "/srv/data", Target: "/srv/data"}}, Memory: 512 << 20, NanoCpus: 1500000000, AutoRemove: true}
+	if !reflect.DeepEqual(e.created[0].HostConfig, want) {
+		t.Errorf("Expected host config %+v, got %+v", want, e.created[0].HostConfig)
+	}
+	if !reflect.DeepEqual(e.created[0].Entrypoint, []string{""}) {
+		t.Errorf("Expected a cleared entrypoint, got %q", e.created[0].Entrypoint)
+	}
+	if e.created[0].OpenStdin || !e.created[1].OpenStdin || !e.created[1].StdinOnce {
+		t.Errorf("Expected stdin only for the restore, got %v and %v", e.created[0].OpenStdin, e.created[1].OpenStdin)
+	}
+	if len(e.removed) != 3 || len(e.pulls) != 0 {
+		t.Errorf("Expected 3 containers removed and no pulls, got %v and %v", e.removed, e.pulls)
+	}
+}
+
+func TestEngineClientPullPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    string
+		images    []string
+		pullError string
+		pulls     int
+		err       string
+	}{
+		{"Missing pulls absent image", "missing", nil, "", 1, ""},
+		{"Missing uses present image", "missing", []string{"postgres:16-alpine"}, "", 0, ""},
+		{"Always pulls present image", "always", []string{"postgres:16-alpine"}, "", 1, ""},
+		{"Never refuses absent image", "never", nil, "", 0, "image postgres:16-alpine is not present and --pull-policy is never"},
+		{"Never uses present image", "never", []string{"postgres:16-alpine"}, "", 0, ""},
+		{"Pull failure", "missing", nil, "manifest for postgres:16-alpine not found", 1, "failed to pull postgres:16-alpine: manifest for postgres:16-alpine not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newFakeEngine(t, tt.images...)
+			e.pullError = tt.pullError
+			client := newEngineClient(context.Background(), e.api, "postgres:16-alpine", containerSpec{}, tt.policy)
+
+			// The policy applies once per run, however many commands run
+			for range 2 {
+				_, err := client.query([]string{"true"})
+				if tt.err == "" && err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+					t.Fatalf("Expected error %q, got %v", tt.err, err)
+				}
+			}
+			if len(e.pulls) != tt.pulls {
+				t.Errorf("Expected %d pulls, got %v", tt.pulls, e.pulls)
+			}
+		})
+	}
+}
+
+func TestEngineClientCancelRemovesContainer(t *testing.T) {
+	e := newFakeEngine(t, "postgres:16-alpine")
+	ctx, cancel := context.WithCancel(context.Background())
+	client := newEngineClient(ctx, e.api, "postgres:16-alpine", containerSpec{}, "missing")
+
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start := time.Now()
+	if err := client.dump([]string{"sleep", "30"}, io.Discard); err == nil {
+		t.Fatal("Expected an error from a canceled dump")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the canceled dump to stop promptly, took %v", elapsed)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.removed) != 1 {
+		t.Errorf("Expected the container to be removed, got %v", e.removed)
+	}
+}
+
+func TestDetectContainerRuntimeThroughAPI(t *testing.T) {
+	newFakeEngine(t)
+	original := dockerBinary
+	dockerBinary = "cutter-missing-docker"
+	t.Cleanup(func() { dockerBinary = original })
+
+	r, err := detectContainerRuntime("auto")
+	if err != nil {
+		t.Fatalf("Expected the engine API to be found, got %v", err)
+	}
+	if r.name != "docker" || !r.rootless || r.engine == nil {
+		t.Errorf("Expected rootless Docker through the API, got %+v", r)
+	}
+}
+
+func TestOpenClientUsesEngineAPI(t *testing.T) {
+	newFakeEngine(t)
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "app", Password: "s3cr3t", Database: "orders"}
+
+	client, conn, cleanup, err := openClient(context.Background(), postgresDriver{}, p,
+		clientOptions{runtime: "docker", image: "postgres:16-alpine", container: containerSettings{pullPolicy: "never", memory: "1g", cpus: 2}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer cleanup()
+
+	engine, ok := client.(engineClient)
+	if !ok {
+		t.Fatalf("Expected an engine client, got %T", client)
+	}
+	if engine.spec.network != "host" || engine.spec.memory != 1<<30 || engine.spec.cpus != 2 || engine.pullPolicy != "never" {
+		t.Errorf("Unexpected container setup %+v with policy %s", engine.spec, engine.pullPolicy)
+	}
+	if !reflect.DeepEqual(engine.env, []string{"PGPASSWORD=s3cr3t"}) {
+		t.Errorf("Expected the password in the container env, got %q", engine.env)
+	}
+	if conn.Host != rootlessHostLoopback {
+		t.Errorf("Expected rootless Docker to reach localhost at %s, got %s", rootlessHostLoopback, conn.Host)
+	}
+}
+
+func TestNewEngineAPI(t *testing.T) {
+	tests := []struct {
+		host    string
+		network string
+		address string
+		tls     bool
+		ok      bool
+	}{
+		{"unix:///var/run/docker.sock", "unix", "/var/run/docker.sock", false, true},
+		{"tcp://10.0.0.5:2375", "tcp", "10.0.0.5:2375", false, true},
+		{"tcp://10.0.0.5:2376", "", "", true, false},
+		{"ssh://deploy@build1", "", "", false, false},
+		{"npipe:////./pipe/docker_engine", "", "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if tt.tls {
+				t.Setenv("DOCKER_TLS_VERIFY", "1")
+			}
+			api, err := newEngineAPI(tt.host)
+			if (err == nil) != tt.ok {
+				t.Fatalf("Expected ok %v, got %v", tt.ok, err)
+			}
+			if tt.ok && (api.network != tt.network || api.address != tt.address) {
+				t.Errorf("Expected %s %s, got %s %s", tt.network, tt.address, api.network, api.address)
+			}
+		})
+	}
+}
+
+func TestSplitImageTag(t *testing.T) {
+	tests := []struct {
+		image, repository, tag string
+	}{
+		{"postgres:16-alpine", "postgres", "16-alpine"},
+		{"mongo", "mongo", "latest"},
+		{"registry.local:5000/team/mysql:8.4", "registry.local:5000/team/mysql", "8.4"},
+		{"registry.local:5000/team/mysql", "registry.local:5000/team/mysql", "latest"},
+		{"redis@sha256:abc123", "redis", "sha256:abc123"},
+	}
+
+	for _, tt := range tests {
+		repository, tag := splitImageTag(tt.image)
+		if repository != tt.repository || tag != tt.tag {
+			t.Errorf("splitImageTag(%q) = %q, %q, want %q, %q", tt.image, repository, tag, tt.repository, tt.tag)
+		}
+	}
+}
+
+func TestImageRegistry(t *testing.T) {
+	tests := map[string]string{
+		"postgres":                       dockerHubRegistry,
+		"bitnami/postgresql":             dockerHubRegistry,
+		"registry.local/postgres":        "registry.local",
+		"registry.local:5000/team/mysql": "registry.local:5000",
+		"localhost/mariadb":              "localhost",
+	}
+
+	for repository, want := range tests {
+		if got := imageRegistry(repository); got != want {
+			t.Errorf("imageRegistry(%q) = %q, want %q", repository, got, want)
+		}
+	}
+}
+
+// decodeRegistryAuth decodes an X-Registry-Auth header
+func decodeRegistryAuth(t *testing.T, header string) registryCredentials {
+	t.Helper()
+	var creds registryCredentials
+	data, err := base64.URLEncoding.DecodeString(header)
+	if err != nil || json.Unmarshal(data, &creds) != nil {
+		t.Fatalf("Invalid X-Registry-Auth %q", header)
+	}
+	return creds
+}
+
+func TestRegistryAuth(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	config := `{
+  "auths": {
+    "registry.local": {"auth": "` + base64.StdEncoding.EncodeToString([]byte("ci:hunter2")) + `"},
+    "https://index.docker.io/v1/": {}
+  },
+  "credHelpers": {"ecr.example.com": "fake"}
+}`
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	fakeTools(t, map[string]string{
+		"docker-credential-fake": `read server; echo "{\"ServerURL\":\"$server\",\"Username\":\"<token>\",\"Secret\":\"tok-$server\"}"`,
+	})
+
+	creds := decodeRegistryAuth(t, registryAuth("registry.local"))
+	if creds != (registryCredentials{Username: "ci", Password: "hunter2", ServerAddress: "registry.local"}) {
+		t.Errorf("Unexpected credentials from config.json: %+v", creds)
+	}
+
+	creds = decodeRegistryAuth(t, registryAuth("ecr.example.com"))
+	if creds != (registryCredentials{IdentityToken: "tok-ecr.example.com", ServerAddress: "ecr.example.com"}) {
+		t.Errorf("Unexpected credentials from the helper: %+v", creds)
+	}
+
+	if got := registryAuth(dockerHubRegistry); got != "" {
+		t.Errorf("Expected no credentials for Docker Hub, got %q", got)
+	}
+}
+
+func TestEnginePullSendsRegistryAuth(t *testing.T) {
+	e := newFakeEngine(t)
+	config := `{"auths":{"registry.local":{"auth":"` + base64.StdEncoding.EncodeToString([]byte("ci:hunter2")) + `"}}}`
+	if err := os.WriteFile(filepath.Join(os.Getenv("DOCKER_CONFIG"), "config.json"), []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	var current, total int64
+	err := e.api.pull(context.Background(), "registry.local/postgres:16", func(c, t int64) { current, total = c, t })
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if current != 2048 || total != 2048 {
+		t.Errorf("Expected the finished layer to count in full, got %d of %d", current, total)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pulls) != 1 || e.pulls[0] != "registry.local/postgres:16" {
+		t.Fatalf("Expected one pull of registry.local/postgres:16, got %v", e.pulls)
+	}
+	if creds := decodeRegistryAuth(t, e.pullAuth[0]); creds.Username != "ci" || creds.ServerAddress != "registry.local" {
+		t.Errorf("Unexpected pull credentials %+v", creds)
+	}
+}
+
+func TestDockerContextHost(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	if _, ok := dockerContextHost(); ok {
+		t.Error("Expected no context without a config")
+	}
+
+	sum := "f24fd3749c1368328e2b149bec149cb6795619f244c5b584e844961215dadd16"
+	meta := filepath.Join(dir, "contexts", "meta", sum)
+	if err := os.MkdirAll(meta, 0700); err != nil {
+		t.Fatalf("Failed to create context: %v", err)
+	}
+	content := `{"Name":"colima","Endpoints":{"docker":{"Host":"unix:///home/dev/.colima/default/docker.sock"}}}`
+	if err := os.WriteFile(filepath.Join(meta, "meta.json"), []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write context: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"currentContext":"colima"}`), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	host, ok := dockerContextHost()
+	if !ok || host != "unix:///home/dev/.colima/default/docker.sock" {
+		t.Errorf("Expected the colima socket, got %q (%v)", host, ok)
+	}
+
+	t.Setenv("DOCKER_CONTEXT", "default")
+	if _, ok := dockerContextHost(); ok {
+		t.Error("Expected DOCKER_CONTEXT=default to use the default engine")
+	}
+}
@@ -0,0 +1,63 @@
+package commands
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/spf13/cobra"
+)
+
+// DumpFilters narrows what a backup contains. Table and schema values are
+// glob patterns (* and ?); an empty filter dumps the whole database.
+type DumpFilters struct {
+	SchemaOnly    bool     `json:"schema_only,omitempty"`
+	DataOnly      bool     `json:"data_only,omitempty"`
+	IncludeTables []string `json:"include_tables,omitempty"`
+	ExcludeTables []string `json:"exclude_tables,omitempty"`
+	Schemas       []string `json:"schemas,omitempty"`
+}
+
+// addFilterFlags registers the backup content filters
+func addFilterFlags(cmd *cobra.Command, f *DumpFilters) {
+	cmd.Flags().BoolVar(&f.SchemaOnly, "schema-only", false, "Dump only the schema, no data")
+	cmd.Flags().BoolVar(&f.DataOnly, "data-only", false, "Dump only the data, no schema")
+	cmd.Flags().StringArrayVar(&f.IncludeTables, "include-table", nil, "Dump only tables matching this glob pattern; repeatable")
+	cmd.Flags().StringArrayVar(&f.ExcludeTables, "exclude-table", nil, "Skip tables matching this glob pattern; repeatable")
+	cmd.Flags().StringArrayVar(&f.Schemas, "schema", nil, "Dump only schemas matching this glob pattern; repeatable (PostgreSQL)")
+	cmd.MarkFlagsMutuallyExclusive("schema-only", "data-only")
+}
+
+// isEmpty reports whether f leaves the dump unfiltered
+func (f DumpFilters) isEmpty() bool {
+	return !f.SchemaOnly && !f.DataOnly && len(f.IncludeTables) == 0 && len(f.ExcludeTables) == 0 && len(f.Schemas) == 0
+}
+
+// validate rejects malformed patterns before anything is started
+func (f DumpFilters) validate() error {
+	for _, patterns := range [][]string{f.IncludeTables, f.ExcludeTables, f.Schemas} {
+		for _, pattern := range patterns {
+			if pattern == "" {
+				return fmt.Errorf("empty table or schema pattern")
+			}
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %v", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// matchTables returns the tables matching any of patterns, in the order
+// of tables
+func matchTables(tables, patterns []string) []string {
+	var matched []string
+	for _, table := range tables {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(pattern, table); ok {
+				matched = append(matched, table)
+				break
+			}
+		}
+	}
+	return matched
+}
@@ -0,0 +1,59 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDumpFiltersValidate(t *testing.T) {
+	tests := []struct {
+		filters DumpFilters
+		wantErr string
+	}{
+		{DumpFilters{}, ""},
+		{DumpFilters{IncludeTables: []string{"order*", "item?"}, Schemas: []string{"app"}}, ""},
+		{DumpFilters{ExcludeTables: []string{"audit_[0-9"}}, `invalid pattern "audit_[0-9"`},
+		{DumpFilters{IncludeTables: []string{""}}, "empty table or schema pattern"},
+	}
+
+	for _, tt := range tests {
+		err := tt.filters.validate()
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("Expected no error for %+v, got %v", tt.filters, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("Expected error %q for %+v, got %v", tt.wantErr, tt.filters, err)
+		}
+	}
+}
+
+func TestDumpFiltersIsEmpty(t *testing.T) {
+	if !(DumpFilters{}).isEmpty() {
+		t.Error("Expected zero filters to be empty")
+	}
+	if (DumpFilters{DataOnly: true}).isEmpty() {
+		t.Error("Expected --data-only to be a filter")
+	}
+}
+
+func TestMatchTables(t *testing.T) {
+	tables := []string{"audit_2023", "audit_2024", "events", "orders"}
+
+	got := matchTables(tables, []string{"audit_*", "orders", "nothing"})
+	want := []string{"audit_2023", "audit_2024", "orders"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestFilterFlagsExclusive(t *testing.T) {
+	cmd := newDBBackupCmd()
+	cmd.SetArgs([]string{"--database", "db", "--username", "u", "--schema-only", "--data-only"})
+	cmd.SetOut(new(strings.Builder))
+	cmd.SetErr(new(strings.Builder))
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "schema-only") {
+		t.Errorf("Expected mutually exclusive flag error, got %v", err)
+	}
+}
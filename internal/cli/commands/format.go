@@ -0,0 +1,318 @@
+package commands
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// formatExtensions maps each dump format to the extension of its files. A
+// packed directory dump is named .dir.tar, an unpacked one .dir.
+var formatExtensions = map[string]string{
+	"plain":     ".sql",
+	"custom":    ".dump",
+	"directory": ".dir",
+	"tar":       ".tar",
+	// archive is mongodump's --archive stream, rdb a Redis snapshot and
+	// sqlite a copy of a SQLite database file
+	"archive": ".archive",
+	"rdb":     ".rdb",
+	"sqlite":  ".sqlite",
+}
+
+// backupExtPattern finds the format extension in a backup name
+var backupExtPattern = regexp.MustCompile(`\.(sqlite|sql|dump|dir|tar|archive|rdb)(\.|$)`)
+
+// backupFormat returns the dump format a backup name was generated for
+func backupFormat(name string) string {
+	var ext string
+	if m := backupNamePattern.FindStringSubmatch(name); m != nil {
+		ext = m[3]
+	} else if m := backupExtPattern.FindStringSubmatch(name); m != nil {
+		ext = m[1]
+	}
+	for format, formatExt := range formatExtensions {
+		if formatExt == "."+ext {
+			return format
+		}
+	}
+	return "plain"
+}
+
+// compressedByTool reports whether the dump tool compresses format itself,
+// in which case cutter does not gzip it again
+func compressedByTool(format string) bool {
+	return format == "custom" || format == "directory"
+}
+
+// streamCompression returns the compression cutter applies to a dump in
+// format, which is none when the dump tool compresses it
+func streamCompression(format string, compression compressionOptions) compressionOptions {
+	if compressedByTool(format) {
+		return compressionOptions{codec: "none"}
+	}
+	return compression
+}
+
+// backupExtension returns the extension of a generated backup name
+func backupExtension(format string, compression compressionOptions, packed, encrypted bool) string {
+	ext := formatExtensions[format]
+	if format == "directory" && packed {
+		ext += ".tar"
+	}
+	if stream := streamCompression(format, compression); stream.enabled() {
+		ext += stream.ext()
+	}
+	if encrypted {
+		ext += ".age"
+	}
+	return ext
+}
+
+// checkDumpFormat validates --format, --compression, --jobs and --unpack
+// for d
+func checkDumpFormat(d BackupDriver, opts backupOptions) error {
+	if !slices.Contains(d.Formats(), opts.format) {
+		return fmt.Errorf("unsupported format %q for %s (use one of %s)", opts.format, d.Name(), strings.Join(d.Formats(), ", "))
+	}
+
+	if compressedByTool(opts.format) && opts.compression.enabled() && opts.compression.codec != "gzip" {
+		return fmt.Errorf("--compression %s is not available for %s dumps, which the dump tool compresses itself (use gzip or none)", opts.compression.codec, opts.format)
+	}
+	if compressedByTool(opts.format) && opts.compression.level != 0 {
+		return fmt.Errorf("--compression-level is not available for %s dumps, which the dump tool compresses at its default level", opts.format)
+	}
+
+	if opts.jobs < 1 {
+		return fmt.Errorf("--jobs must be at least 1")
+	}
+	if opts.jobs > 1 && opts.format != "directory" {
+		return fmt.Errorf("--jobs requires --format directory")
+	}
+
+	if opts.unpack {
+		switch {
+		case opts.format != "directory":
+			return fmt.Errorf("--unpack requires --format directory")
+		case isObjectStorage(opts.output):
+			return fmt.Errorf("--unpack cannot write to object storage")
+		case len(opts.encrypt.recipients) > 0 || opts.encrypt.passphrase:
+			return fmt.Errorf("--unpack cannot be combined with encryption")
+		}
+	}
+	return nil
+}
+
+// mongoArchiveMagic starts every mongodump --archive stream
+var mongoArchiveMagic = []byte{0x6d, 0xe2, 0x99, 0x81}
+
+// detectArchive peeks at a decompressed dump and reports whether it is a
+// pg_dump custom archive, a tar archive, a mongodump archive, a Redis RDB
+// snapshot, a SQLite database or plain SQL
+func detectArchive(r io.Reader) (io.Reader, string, error) {
+	buffered := bufio.NewReaderSize(r, 512)
+
+	head, err := buffered.Peek(512)
+	if err != nil && err != io.EOF {
+		return nil, "", fmt.Errorf("failed to read backup file: %v", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(head, []byte("PGDMP")):
+		return buffered, "custom", nil
+	case bytes.HasPrefix(head, mongoArchiveMagic):
+		return buffered, "archive", nil
+	case bytes.HasPrefix(head, []byte("REDIS")):
+		return buffered, "rdb", nil
+	case bytes.HasPrefix(head, []byte("SQLite format 3\x00")):
+		return buffered, "sqlite", nil
+	case len(head) >= 262 && bytes.Equal(head[257:262], []byte("ustar")):
+		return buffered, "tar", nil
+	}
+	return buffered, "plain", nil
+}
+
+// streamDumpDir runs a dump that writes a tar stream to stdout and unpacks
+// it into dir, which must not exist yet. Nothing is left behind on failure.
+func streamDumpDir(tool string, dump dumpFunc, dir string, progress *progressReporter) (dumpStats, error) {
+	if _, err := os.Lstat(dir); err == nil {
+		return dumpStats{}, fmt.Errorf("failed to create output directory: %s already exists", dir)
+	}
+	// Extract next to dir and rename once the dump is complete
+	final := dir
+	dir, err := os.MkdirTemp(filepath.Dir(final), partialName(filepath.Base(final)))
+	if err != nil {
+		return dumpStats{}, fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	stdout, pipe := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := dump(pipe)
+		pipe.Close()
+		done <- err
+	}()
+
+	raw := &countingReader{r: stdout}
+	progress.track(raw.n.Load, raw.n.Load)
+	extractErr := extractTar(raw, dir)
+	// Unblock the dump so it can exit, whether extraction stopped early or
+	// left the tar stream's trailing padding unread
+	io.Copy(io.Discard, stdout)
+	err = <-done
+	if err != nil {
+		err = fmt.Errorf("%s failed: %v", tool, err)
+	} else if extractErr != nil {
+		err = extractErr
+	}
+	progress.finish(err)
+	if err != nil {
+		os.RemoveAll(dir)
+		return dumpStats{}, err
+	}
+
+	sum, size, err := hashPath(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return dumpStats{}, err
+	}
+	if err := os.Rename(dir, final); err != nil {
+		os.RemoveAll(dir)
+		return dumpStats{}, fmt.Errorf("failed to create output directory: %v", err)
+	}
+	return dumpStats{RawBytes: raw.n.Load(), FileBytes: size, SHA256: sum}, nil
+}
+
+// countingReader counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// extractTar unpacks the regular files and directories of a tar stream
+// into dir, refusing entries that would land outside it
+func extractTar(r io.Reader, dir string) error {
+	archive := tar.NewReader(r)
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read dump archive: %v", err)
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if name == "." {
+			continue
+		}
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("dump archive entry %q escapes the output directory", header.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return fmt.Errorf("failed to create %s: %v", target, err)
+			}
+		case tar.TypeReg:
+			file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %v", target, err)
+			}
+			_, err = io.Copy(file, archive)
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				return fmt.Errorf("failed to write %s: %v", target, err)
+			}
+		default:
+			return fmt.Errorf("unexpected dump archive entry %q", header.Name)
+		}
+	}
+}
+
+// hashPath returns the SHA-256 and size of a backup file, or of a backup
+// directory as a whole: every file's relative path, length and content in
+// lexical order
+func hashPath(path string) (string, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open backup file: %v", err)
+	}
+	if !info.IsDir() {
+		return hashFile(path)
+	}
+
+	hash := sha256.New()
+	var total int64
+	err = filepath.WalkDir(path, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(path, file)
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		io.WriteString(hash, filepath.ToSlash(rel)+"\x00")
+		binary.Write(hash, binary.BigEndian, info.Size())
+		n, err := io.Copy(hash, f)
+		total += n
+		return err
+	})
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read backup directory: %v", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), total, nil
+}
+
+// dirSize returns the total size of the files under dir
+func dirSize(dir string) int64 {
+	var total int64
+	filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			if info, err := entry.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+		return nil
+	})
+	return total
+}
+
+// formatLabel is the short description of a backup shown by db list
+func formatLabel(name string) string {
+	format := backupFormat(name)
+	if format == "directory" && strings.Contains(name, ".dir.tar") {
+		return "directory, packed"
+	}
+	return format
+}
@@ -0,0 +1,238 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestBackupFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"orders_20240101_120000.sql.gz", "plain"},
+		{"orders_20240101_120000.dump", "custom"},
+		{"orders_20240101_120000.dump.age", "custom"},
+		{"orders_20240101_120000.tar.gz", "tar"},
+		{"orders_20240101_120000.dir", "directory"},
+		{"orders_20240101_120000.dir.tar.age", "directory"},
+		{"events_20240101_120000.archive.zst", "archive"},
+		{"cache.internal_20240101_120000.rdb.gz", "rdb"},
+		{"data_20240101_120000.sqlite.zst", "sqlite"},
+		{"my.tar.db_20240101_120000.sql", "plain"},
+		{"custom.dump", "custom"},
+		{"notes.txt", "plain"},
+	}
+
+	for _, tt := range tests {
+		if got := backupFormat(tt.name); got != tt.want {
+			t.Errorf("Expected %s for %s, got %s", tt.want, tt.name, got)
+		}
+	}
+}
+
+func TestBackupExtension(t *testing.T) {
+	tests := []struct {
+		format      string
+		compression string
+		packed      bool
+		encrypted   bool
+		want        string
+	}{
+		{"plain", "gzip", true, false, ".sql.gz"},
+		{"plain", "zstd", true, false, ".sql.zst"},
+		{"plain", "none", true, true, ".sql.age"},
+		{"custom", "gzip", true, false, ".dump"},
+		{"tar", "lz4", true, false, ".tar.lz4"},
+		{"directory", "gzip", true, true, ".dir.tar.age"},
+		{"directory", "gzip", false, false, ".dir"},
+		{"archive", "gzip", true, false, ".archive.gz"},
+	}
+
+	for _, tt := range tests {
+		got := backupExtension(tt.format, compressionOptions{codec: tt.compression}, tt.packed, tt.encrypted)
+		if got != tt.want {
+			t.Errorf("Expected %s for %+v, got %s", tt.want, tt, got)
+		}
+	}
+}
+
+func TestFormatLabel(t *testing.T) {
+	if got := formatLabel("orders_20240101_120000.dir.tar"); got != "directory, packed" {
+		t.Errorf("Expected 'directory, packed', got %q", got)
+	}
+	if got := formatLabel("orders_20240101_120000.dir"); got != "directory" {
+		t.Errorf("Expected 'directory', got %q", got)
+	}
+}
+
+func TestCheckDumpFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		driver  BackupDriver
+		opts    backupOptions
+		wantErr string
+	}{
+		{"plain", mysqlDriver{}, backupOptions{format: "plain", jobs: 1}, ""},
+		{"directory jobs", postgresDriver{}, backupOptions{format: "directory", jobs: 8}, ""},
+		{"unpack", postgresDriver{}, backupOptions{format: "directory", jobs: 1, unpack: true, output: "/backups/"}, ""},
+		{"mysql custom", mysqlDriver{}, backupOptions{format: "custom", jobs: 1}, `unsupported format "custom" for mysql`},
+		{"mongodb archive", mongoDriver{}, backupOptions{format: "archive", jobs: 1}, ""},
+		{"mongodb plain", mongoDriver{}, backupOptions{format: "plain", jobs: 1}, `unsupported format "plain" for mongodb`},
+		{"unknown", postgresDriver{}, backupOptions{format: "zip", jobs: 1}, `unsupported format "zip"`},
+		{"jobs without directory", postgresDriver{}, backupOptions{format: "custom", jobs: 4}, "--jobs requires --format directory"},
+		{"zero jobs", postgresDriver{}, backupOptions{format: "directory", jobs: 0}, "--jobs must be at least 1"},
+		{"unpack custom", postgresDriver{}, backupOptions{format: "custom", jobs: 1, unpack: true}, "--unpack requires --format directory"},
+		{"unpack s3", postgresDriver{}, backupOptions{format: "directory", jobs: 1, unpack: true, output: "s3://b/p/"}, "object storage"},
+		{"unpack encrypted", postgresDriver{}, backupOptions{format: "directory", jobs: 1, unpack: true, encrypt: encryptOptions{passphrase: true}}, "encryption"},
+		{"custom zstd", postgresDriver{}, backupOptions{format: "custom", jobs: 1, compression: compressionOptions{codec: "zstd"}}, "--compression zstd is not available for custom dumps"},
+		{"custom gzip", postgresDriver{}, backupOptions{format: "custom", jobs: 1, compression: compressionOptions{codec: "gzip"}}, ""},
+		{"custom level", postgresDriver{}, backupOptions{format: "custom", jobs: 1, compression: compressionOptions{codec: "gzip", level: 9}}, "--compression-level is not available for custom dumps"},
+		{"directory level", postgresDriver{}, backupOptions{format: "directory", jobs: 1, compression: compressionOptions{codec: "gzip", level: 1}}, "--compression-level is not available for directory dumps"},
+		{"plain level", postgresDriver{}, backupOptions{format: "plain", jobs: 1, compression: compressionOptions{codec: "gzip", level: 9}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkDumpFormat(tt.driver, tt.opts)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// tarStream builds a tar archive holding files
+func tarStream(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("Failed to write tar header: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDetectArchive(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"custom", []byte("PGDMP\x01\x0e\x00"), "custom"},
+		{"mongodb archive", []byte("\x6d\xe2\x99\x81\x01\x00"), "archive"},
+		{"redis snapshot", []byte("REDIS0011\xfa"), "rdb"},
+		{"sqlite database", []byte("SQLite format 3\x00\x10\x00"), "sqlite"},
+		{"tar", tarStream(t, map[string]string{"toc.dat": "toc"}), "tar"},
+		{"plain", []byte("CREATE TABLE users (id int);\n"), "plain"},
+		{"empty", nil, "plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, got, err := detectArchive(bytes.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+
+			data, _ := io.ReadAll(r)
+			if !bytes.Equal(data, tt.input) {
+				t.Error("Expected the stream to be returned unchanged")
+			}
+		})
+	}
+}
+
+func TestExtractTarRejectsEscapingEntries(t *testing.T) {
+	dir := t.TempDir()
+
+	err := extractTar(bytes.NewReader(tarStream(t, map[string]string{"../evil": "x"})), dir)
+	if err == nil || !strings.Contains(err.Error(), "escapes the output directory") {
+		t.Errorf("Expected escape error, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(dir), "evil")); err == nil {
+		t.Error("Expected nothing written outside the output directory")
+	}
+}
+
+func TestStreamDumpDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell and tar")
+	}
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar is not installed")
+	}
+
+	src := t.TempDir()
+	os.WriteFile(filepath.Join(src, "toc.dat"), []byte("toc"), 0600)
+	os.WriteFile(filepath.Join(src, "3001.dat.gz"), []byte("rows"), 0600)
+
+	output := filepath.Join(t.TempDir(), "orders.dir")
+	stats, err := streamDumpDir("tar", commandDump(exec.Command("tar", "-C", src, "-cf", "-", ".")), output, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(output, "3001.dat.gz"))
+	if err != nil || string(data) != "rows" {
+		t.Errorf("Expected extracted data file, got %q (%v)", data, err)
+	}
+	if stats.FileBytes != int64(len("toc")+len("rows")) {
+		t.Errorf("Expected %d bytes, got %d", len("toc")+len("rows"), stats.FileBytes)
+	}
+
+	sum, size, err := hashPath(output)
+	if err != nil || sum != stats.SHA256 || size != stats.FileBytes {
+		t.Errorf("Expected hashPath to match the dump stats, got %s %d (%v)", sum, size, err)
+	}
+}
+
+func TestStreamDumpDirFailureRemovesOutput(t *testing.T) {
+	fakeDocker(t)
+
+	output := filepath.Join(t.TempDir(), "orders.dir")
+	if _, err := streamDumpDir("pg_dump", commandDump(exec.Command(dockerBinary, "fail")), output, nil); err == nil {
+		t.Fatal("Expected an error from the failing dump")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(output)); len(entries) != 0 {
+		t.Errorf("Expected nothing left behind, got %v", entries)
+	}
+}
+
+func TestHashPathDirectoryDetectsChanges(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a"), []byte("one"), 0600)
+	os.WriteFile(filepath.Join(dir, "b"), []byte("two"), 0600)
+
+	before, _, err := hashPath(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Moving a byte between files keeps the total but must change the hash
+	os.WriteFile(filepath.Join(dir, "a"), []byte("onet"), 0600)
+	os.WriteFile(filepath.Join(dir, "b"), []byte("wo"), 0600)
+
+	after, _, _ := hashPath(dir)
+	if before == after {
+		t.Error("Expected a different hash after changing the files")
+	}
+}
@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/PandhuWibowo/go-devops-cutter/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// imageMatcher is implemented by drivers whose client tools must be at
+// least as new as the server, such as pg_dump refusing newer servers
+type imageMatcher interface {
+	// imageVersion reduces a ServerVersion result to the version client
+	// images are picked by, such as 16 for PostgreSQL 16.2, and returns the
+	// stock image for it. ok is false when the driver's default image fits.
+	imageVersion(serverVersion string) (version, image string, ok bool)
+}
+
+// clientImages picks the client image for a driver. --client-image wins,
+// then the config file's client_images entry for the server version, then
+// the stock image for that version, then the driver's default image.
+type clientImages struct {
+	override string
+	// mapping is the driver's client_images table from the config file,
+	// keyed by server version; its "default" entry replaces the driver's
+	// default image
+	mapping map[string]string
+}
+
+// addClientImageFlag registers --client-image
+func addClientImageFlag(cmd *cobra.Command, image *string) {
+	cmd.Flags().StringVar(image, "client-image", "", "Client image to run instead of the one matching the server version")
+}
+
+// loadClientImages returns the image choices for d from override and the
+// client_images table of the cutter config file
+func loadClientImages(override string, d BackupDriver) (clientImages, error) {
+	images := clientImages{override: override}
+	if override != "" {
+		return images, nil
+	}
+
+	path, err := config.DefaultPath()
+	if err != nil {
+		return images, err
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return images, err
+	}
+	images.mapping = cfg.ClientImages[d.Name()]
+	return images, nil
+}
+
+// initial returns the image used before the server version is known
+func (c clientImages) initial(d BackupDriver) string {
+	if c.override != "" {
+		return c.override
+	}
+	if image := c.mapping["default"]; image != "" {
+		return image
+	}
+	return d.ClientImage()
+}
+
+// forVersion returns the image for a server reporting serverVersion
+func (c clientImages) forVersion(d BackupDriver, serverVersion string) string {
+	if c.override != "" {
+		return c.override
+	}
+	matcher, ok := d.(imageMatcher)
+	if !ok {
+		return c.initial(d)
+	}
+	version, image, ok := matcher.imageVersion(serverVersion)
+	if !ok {
+		return c.initial(d)
+	}
+	if mapped := c.mapping[version]; mapped != "" {
+		return mapped
+	}
+	return image
+}
+
+// matchClientImage reads the server version with client and switches a
+// containerized client to the image for that version. version is empty
+// when it could not be read, and image when no container is involved.
+func matchClientImage(client dumpClient, d BackupDriver, p ConnParams, images clientImages) (dumpClient, string, string) {
+	image := client.clientImage()
+	version, err := d.ServerVersion(client.query, p)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not read server version: %v\n", err)
+		return client, "", image
+	}
+	if image == "" {
+		return client, version, ""
+	}
+
+	if matched := images.forVersion(d, version); matched != image {
+		fmt.Printf("Server is %s %s, using client image %s\n", d.DisplayName(), version, matched)
+		image = matched
+	}
+	return client.withImage(image), version, image
+}
+
+// leadingVersion matches the major and optional minor number a version
+// string starts with
+var leadingVersion = regexp.MustCompile(`^\s*(\d+)(?:\.(\d+))?`)
+
+// parseVersion returns the major and minor number at the start of version;
+// a missing minor number is 0
+func parseVersion(version string) (major, minor int, ok bool) {
+	m := leadingVersion.FindStringSubmatch(version)
+	if m == nil {
+		return 0, 0, false
+	}
+	major, _ = strconv.Atoi(m[1])
+	if m[2] != "" {
+		minor, _ = strconv.Atoi(m[2])
+	}
+	return major, minor, true
+}
@@ -0,0 +1,176 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version      string
+		major, minor int
+		ok           bool
+	}{
+		{"16.2", 16, 2, true},
+		{"16", 16, 0, true},
+		{"8.0.36", 8, 0, true},
+		{"10.11.6-MariaDB-1:10.11.6+maria~ubu2204", 10, 11, true},
+		{"16.2 (Debian 16.2-1.pgdg120+2)", 16, 2, true},
+		{"unknown", 0, 0, false},
+		{"", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		major, minor, ok := parseVersion(tt.version)
+		if major != tt.major || minor != tt.minor || ok != tt.ok {
+			t.Errorf("parseVersion(%q) = %d, %d, %v, want %d, %d, %v", tt.version, major, minor, ok, tt.major, tt.minor, tt.ok)
+		}
+	}
+}
+
+func TestDriverImageVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		driver  imageMatcher
+		server  string
+		version string
+		image   string
+		ok      bool
+	}{
+		{"Postgres", postgresDriver{}, "16.2 (Debian 16.2-1.pgdg120+2)", "16", "postgres:16-alpine", true},
+		{"Postgres too old", postgresDriver{}, "9.6.24", "", "", false},
+		{"MySQL", mysqlDriver{}, "8.4.0", "8.4", "mysql:8.4", true},
+		{"MySQL 5.7", mysqlDriver{}, "5.7.44-log", "5.7", "mysql:5.7", true},
+		{"MySQL too old", mysqlDriver{}, "5.6.51", "", "", false},
+		{"MariaDB behind mysql", mysqlDriver{}, "10.11.6-MariaDB", "", "", false},
+		{"MariaDB", mysqlDriver{mariadb: true}, "10.11.6-MariaDB-1:10.11.6+maria~ubu2204", "10.11", "mariadb:10.11", true},
+		{"MariaDB too old", mysqlDriver{mariadb: true}, "10.4.32-MariaDB", "", "", false},
+		{"MySQL behind mariadb", mysqlDriver{mariadb: true}, "8.0.36", "", "", false},
+		{"MongoDB", mongoDriver{}, "6.0.14", "6", "mongo:6", true},
+		{"MongoDB too old", mongoDriver{}, "4.4.29", "", "", false},
+		{"Redis", redisDriver{}, "7.2.4", "7", "redis:7-alpine", true},
+		{"Garbage", postgresDriver{}, "unknown", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, image, ok := tt.driver.imageVersion(tt.server)
+			if version != tt.version || image != tt.image || ok != tt.ok {
+				t.Errorf("Expected %q, %q, %v, got %q, %q, %v", tt.version, tt.image, tt.ok, version, image, ok)
+			}
+		})
+	}
+}
+
+func TestClientImagesForVersion(t *testing.T) {
+	mapping := map[string]string{"default": "registry.local/postgres:15", "14": "registry.local/postgres:14"}
+
+	tests := []struct {
+		name    string
+		images  clientImages
+		server  string
+		initial string
+		want    string
+	}{
+		{"Stock image", clientImages{}, "16.2", "postgres:15-alpine", "postgres:16-alpine"},
+		{"Too old keeps default", clientImages{}, "9.6", "postgres:15-alpine", "postgres:15-alpine"},
+		{"Mapped version", clientImages{mapping: mapping}, "14.11", "registry.local/postgres:15", "registry.local/postgres:14"},
+		{"Unmapped version", clientImages{mapping: mapping}, "16.2", "registry.local/postgres:15", "postgres:16-alpine"},
+		{"Mapped default", clientImages{mapping: mapping}, "9.6", "registry.local/postgres:15", "registry.local/postgres:15"},
+		{"Override wins", clientImages{override: "custom:1", mapping: mapping}, "14.11", "custom:1", "custom:1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.images.initial(postgresDriver{}); got != tt.initial {
+				t.Errorf("Expected initial image %s, got %s", tt.initial, got)
+			}
+			if got := tt.images.forVersion(postgresDriver{}, tt.server); got != tt.want {
+				t.Errorf("Expected image %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestClientImagesWithoutMatcher(t *testing.T) {
+	images := clientImages{mapping: map[string]string{"default": "custom/sqlite:1"}}
+
+	if got := images.forVersion(sqliteDriver{}, "3.45.1"); got != "custom/sqlite:1" {
+		t.Errorf("Expected mapped default image, got %s", got)
+	}
+}
+
+func TestLoadClientImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("CUTTER_CONFIG", path)
+	config := "client_images:\n  postgres:\n    default: registry.local/postgres:15\n    \"16\": registry.local/postgres:16\n"
+	if err := os.WriteFile(path, []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	images, err := loadClientImages("", postgresDriver{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := images.forVersion(postgresDriver{}, "16.1"); got != "registry.local/postgres:16" {
+		t.Errorf("Expected mapped image, got %s", got)
+	}
+
+	images, err = loadClientImages("", mysqlDriver{})
+	if err != nil || len(images.mapping) != 0 {
+		t.Errorf("Expected no mapping for mysql, got %v (%v)", images.mapping, err)
+	}
+
+	images, err = loadClientImages("custom:1", postgresDriver{})
+	if err != nil || images.initial(postgresDriver{}) != "custom:1" {
+		t.Errorf("Expected override, got %+v (%v)", images, err)
+	}
+}
+
+// versionClient is a dumpClient whose queries report a fixed server version
+type versionClient struct {
+	version string
+	err     error
+	image   string
+}
+
+func (c versionClient) query(args []string) (string, error)        { return c.version, c.err }
+func (c versionClient) run(args []string, input io.Reader) error   { return nil }
+func (c versionClient) dump(args []string, stdout io.Writer) error { return nil }
+
+func (c versionClient) withImage(image string) dumpClient {
+	c.image = image
+	return c
+}
+
+func (c versionClient) clientImage() string { return c.image }
+
+func TestMatchClientImage(t *testing.T) {
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "app", Database: "orders"}
+	container := versionClient{version: "16.2", image: "postgres:15-alpine"}
+
+	client, version, image := matchClientImage(container, postgresDriver{}, p, clientImages{})
+	if version != "16.2" || image != "postgres:16-alpine" {
+		t.Errorf("Expected 16.2 on postgres:16-alpine, got %s on %s", version, image)
+	}
+	if got := client.clientImage(); got != "postgres:16-alpine" {
+		t.Errorf("Expected client switched to postgres:16-alpine, got %q", got)
+	}
+
+	container.err = fmt.Errorf("connection refused")
+	client, version, image = matchClientImage(container, postgresDriver{}, p, clientImages{})
+	if version != "" || image != "postgres:15-alpine" {
+		t.Errorf("Expected default image without a version, got %q on %s", version, image)
+	}
+	if got := client.clientImage(); got != "postgres:15-alpine" {
+		t.Errorf("Expected client left unchanged, got %q", got)
+	}
+
+	client, version, image = matchClientImage(versionClient{version: "16.2"}, postgresDriver{}, p, clientImages{})
+	if version != "16.2" || image != "" || client.clientImage() != "" {
+		t.Errorf("Expected local tools to stay without an image, got %q on %q", version, image)
+	}
+}
@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ErrCanceled is returned when a command was stopped by SIGINT or SIGTERM,
+// as opposed to failing on its own. main exits with status 130 for it.
+var ErrCanceled = errors.New("canceled")
+
+// interruptContext returns a context canceled by the first SIGINT or
+// SIGTERM, with a cause wrapping ErrCanceled. Later signals get the default
+// behaviour again, so a second Ctrl-C kills cutter if cleanup hangs. stop
+// releases the signal handler.
+func interruptContext() (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			fmt.Fprintf(os.Stderr, "\nReceived %v, cleaning up...\n", sig)
+			cancel(fmt.Errorf("%w by %v", ErrCanceled, sig))
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(nil)
+	}
+}
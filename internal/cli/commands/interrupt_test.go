@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestInterruptContext(t *testing.T) {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	self, _ := os.FindProcess(os.Getpid())
+	if err := self.Signal(os.Interrupt); err != nil {
+		t.Skipf("cannot signal this process: %v", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the context to be canceled by SIGINT")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, ErrCanceled) || cause.Error() != "canceled by interrupt" {
+		t.Errorf("Expected 'canceled by interrupt', got %v", cause)
+	}
+}
+
+func TestInterruptContextStop(t *testing.T) {
+	ctx, stop := interruptContext()
+	stop()
+
+	if !errors.Is(ctx.Err(), context.Canceled) || errors.Is(context.Cause(ctx), ErrCanceled) {
+		t.Errorf("Expected a plain cancellation after stop, got %v", context.Cause(ctx))
+	}
+}
@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// localRunner is implemented by drivers whose client tools can run from
+// the local PATH instead of a container
+type localRunner interface {
+	// localTools lists the programs the driver runs; the first is the dump
+	// tool whose --version output is checked
+	localTools() []string
+	// localToolFits reports whether the dump tool whose --version line is
+	// tool can dump a server whose ServerVersion is server
+	localToolFits(tool, server string) bool
+}
+
+// errLocalToolsMissing is returned by openLocalClient when a client tool
+// is not on the PATH
+var errLocalToolsMissing = errors.New("not installed")
+
+// localClient runs database client tools installed on this machine. The
+// password reaches them through the environment variable the tools read,
+// never the command line.
+type localClient struct {
+	env []string
+	// version is the dump tool's --version line
+	version string
+	// ctx, when set, stops running tools once it is canceled
+	ctx context.Context
+}
+
+// openLocalClient returns a client running d's tools from the PATH after
+// checking that the installed dump tool can handle the server at p
+func openLocalClient(ctx context.Context, d BackupDriver, p ConnParams) (localClient, error) {
+	runner, ok := d.(localRunner)
+	if !ok {
+		return localClient{}, fmt.Errorf("%s client tools cannot run locally", d.DisplayName())
+	}
+	tools := runner.localTools()
+	for _, tool := range tools {
+		if _, err := exec.LookPath(tool); err != nil {
+			return localClient{}, fmt.Errorf("%s is %w", tool, errLocalToolsMissing)
+		}
+	}
+
+	client := localClient{ctx: ctx}
+	if p.Password != "" && d.PasswordEnv() != "" {
+		password := p.Password
+		if encoder, ok := d.(passwordEncoder); ok {
+			password = encoder.encodePassword(password)
+		}
+		client.env = []string{d.PasswordEnv() + "=" + password}
+	}
+
+	out, err := client.query([]string{tools[0], "--version"})
+	if err != nil {
+		return localClient{}, err
+	}
+	client.version, _, _ = strings.Cut(out, "\n")
+
+	// Without a server version the tools cannot be ruled out; the backup
+	// warns about the version when it queries it again
+	if server, err := d.ServerVersion(client.query, p); err == nil && !runner.localToolFits(client.version, server) {
+		return localClient{}, fmt.Errorf("local %s cannot dump %s %s", client.version, d.DisplayName(), server)
+	}
+	return client, nil
+}
+
+func (c localClient) command(args []string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if c.ctx == nil {
+		cmd = exec.Command(args[0], args[1:]...)
+	} else {
+		cmd = exec.CommandContext(c.ctx, args[0], args[1:]...)
+		// Wrapper shells start the actual tool, so stop the whole group
+		setProcessGroup(cmd)
+	}
+	cmd.Env = append(os.Environ(), c.env...)
+	return cmd
+}
+
+// query runs a client command and returns its trimmed stdout
+func (c localClient) query(args []string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := c.command(args)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// run executes a client command with stdin attached to input
+func (c localClient) run(args []string, input io.Reader) error {
+	cmd := c.command(args)
+	cmd.Stdin = input
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// dump runs a client command with its stdout connected to stdout
+func (c localClient) dump(args []string, stdout io.Writer) error {
+	cmd := c.command(args)
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// withImage returns c unchanged: the installed tools are used
+func (c localClient) withImage(image string) dumpClient {
+	return c
+}
+
+// clientImage is empty: no container is involved
+func (c localClient) clientImage() string {
+	return ""
+}
+
+// versionNumber matches a dotted version number
+var versionNumber = regexp.MustCompile(`\d+(?:\.\d+)+`)
+
+// toolVersion returns the version number in a --version line, preferring
+// the release after "Distrib" that older mysqldump builds print after
+// their own protocol version
+func toolVersion(line string) string {
+	if _, release, ok := strings.Cut(line, "Distrib "); ok {
+		line = release
+	}
+	return versionNumber.FindString(line)
+}
+
+// versionAtLeast reports whether version is release major.minor or newer
+func versionAtLeast(version string, major, minor int) bool {
+	gotMajor, gotMinor, ok := parseVersion(version)
+	return ok && (gotMajor > major || gotMajor == major && gotMinor >= minor)
+}
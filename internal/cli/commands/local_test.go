@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeTools installs shell scripts named after the keys of tools, printing
+// the values, in a directory put in front of PATH
+func fakeTools(t *testing.T, tools map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, script := range tools {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script+"\n"), 0700); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// fakePostgresTools installs pg_dump of dumpVersion and a psql reporting
+// serverVersion
+func fakePostgresTools(t *testing.T, dumpVersion, serverVersion string) {
+	fakeTools(t, map[string]string{
+		"pg_dump":    "echo 'pg_dump (PostgreSQL) " + dumpVersion + "'",
+		"psql":       "echo " + serverVersion,
+		"pg_restore": "exit 0",
+	})
+}
+
+func TestToolVersion(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"pg_dump (PostgreSQL) 16.2 (Ubuntu 16.2-1.pgdg22.04+1)", "16.2"},
+		{"mysqldump  Ver 8.0.36 for Linux on x86_64 (MySQL Community Server - GPL)", "8.0.36"},
+		{"mysqldump  Ver 10.13 Distrib 5.7.44, for Linux (x86_64)", "5.7.44"},
+		{"mysqldump  Ver 10.19 Distrib 10.11.6-MariaDB, for debian-linux-gnu (x86_64)", "10.11.6"},
+		{"mariadb-dump from 11.4.2-MariaDB, client 10.19 for Linux (x86_64)", "11.4.2"},
+		{"mongodump version: 100.9.4", "100.9.4"},
+		{"redis-cli 7.2.4", "7.2.4"},
+		{"3.45.1 2024-01-30 16:01:20 e876e51a0ed5c5b3126f52e532044363a014bc594cfefa87ffb5b82257ccalt1", "3.45.1"},
+		{"no version here", ""},
+	}
+
+	for _, tt := range tests {
+		if got := toolVersion(tt.line); got != tt.want {
+			t.Errorf("toolVersion(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestLocalToolFits(t *testing.T) {
+	tests := []struct {
+		name   string
+		driver localRunner
+		tool   string
+		server string
+		want   bool
+	}{
+		{"pg_dump newer", postgresDriver{}, "pg_dump (PostgreSQL) 16.2", "15.6", true},
+		{"pg_dump same major", postgresDriver{}, "pg_dump (PostgreSQL) 16.0", "16.2", true},
+		{"pg_dump older", postgresDriver{}, "pg_dump (PostgreSQL) 14.11", "16.2", false},
+		{"mysqldump same release", mysqlDriver{}, "mysqldump  Ver 8.0.36 for Linux", "8.0.35", true},
+		{"mysqldump newer minor", mysqlDriver{}, "mysqldump  Ver 8.4.0 for Linux", "8.0.36", true},
+		{"mysqldump other major", mysqlDriver{}, "mysqldump  Ver 8.0.36 for Linux", "5.7.44-log", false},
+		{"mysqldump from MariaDB", mysqlDriver{}, "mysqldump  Ver 10.19 Distrib 10.11.6-MariaDB", "8.0.36", false},
+		{"mysqldump for MariaDB server", mysqlDriver{}, "mysqldump  Ver 8.0.36 for Linux", "10.11.6-MariaDB", false},
+		{"mariadb-dump newer", mysqlDriver{mariadb: true}, "mariadb-dump from 11.4.2-MariaDB, client 10.19", "10.11.6-MariaDB", true},
+		{"mariadb-dump older", mysqlDriver{mariadb: true}, "mariadb-dump from 10.6.16-MariaDB, client 10.19", "10.11.6-MariaDB", false},
+		{"mariadb-dump for MySQL server", mysqlDriver{mariadb: true}, "mariadb-dump from 11.4.2-MariaDB, client 10.19", "8.0.36", false},
+		{"mongodump database tools", mongoDriver{}, "mongodump version: 100.9.4", "7.0.5", true},
+		{"mongodump bundled older", mongoDriver{}, "mongodump version: r4.2.24", "6.0.14", false},
+		{"redis-cli 7", redisDriver{}, "redis-cli 7.2.4", "6.2.14", true},
+		{"redis-cli 5", redisDriver{}, "redis-cli 5.0.14", "5.0.14", false},
+		{"sqlite3", sqliteDriver{}, "3.45.1 2024-01-30 16:01:20", "3.45.1", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.driver.localToolFits(tt.tool, tt.server); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestOpenLocalClient(t *testing.T) {
+	fakePostgresTools(t, "16.2", "15.6")
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "app", Password: "s3cr3t", Database: "orders"}
+
+	client, err := openLocalClient(context.Background(), postgresDriver{}, p)
+	if err != nil {
+		t.Fatalf("Expected local client, got error: %v", err)
+	}
+	if client.version != "pg_dump (PostgreSQL) 16.2" {
+		t.Errorf("Expected the pg_dump version line, got %q", client.version)
+	}
+	if got, err := client.query([]string{"sh", "-c", `printf %s "$PGPASSWORD"`}); err != nil || got != "s3cr3t" {
+		t.Errorf("Expected the password in PGPASSWORD, got %q (%v)", got, err)
+	}
+}
+
+func TestOpenLocalClientRejectsOlderTools(t *testing.T) {
+	fakePostgresTools(t, "14.11", "16.2")
+
+	_, err := openLocalClient(context.Background(), postgresDriver{}, ConnParams{Host: "localhost", Database: "orders"})
+	if err == nil || !strings.Contains(err.Error(), "cannot dump PostgreSQL 16.2") {
+		t.Errorf("Expected version mismatch error, got %v", err)
+	}
+}
+
+func TestOpenLocalClientMissingTools(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	_, err := openLocalClient(context.Background(), mysqlDriver{mariadb: true}, ConnParams{Host: "localhost", Database: "shop"})
+	if !errors.Is(err, errLocalToolsMissing) || !strings.Contains(err.Error(), "mariadb-dump") {
+		t.Errorf("Expected mariadb-dump to be missing, got %v", err)
+	}
+}
+
+func TestOpenClientRuntime(t *testing.T) {
+	original := dockerBinary
+	dockerBinary = "cutter-missing-docker"
+	t.Cleanup(func() { dockerBinary = original })
+	t.Setenv("PATH", t.TempDir())
+	noEngineAPI(t)
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "app", Database: "orders"}
+
+	fakePostgresTools(t, "16.2", "15.6")
+	client, _, cleanup, err := openClient(context.Background(), postgresDriver{}, p, clientOptions{runtime: "auto", image: "postgres:15-alpine"})
+	if err != nil {
+		t.Fatalf("Expected local client, got error: %v", err)
+	}
+	cleanup()
+	if _, ok := client.(localClient); !ok {
+		t.Errorf("Expected auto to pick the local tools, got %T", client)
+	}
+
+	_, _, _, err = openClient(context.Background(), postgresDriver{}, p, clientOptions{runtime: "docker", image: "postgres:15-alpine"})
+	if err == nil || err.Error() != "cutter-missing-docker is not installed" {
+		t.Errorf("Expected --runtime docker to ignore the local tools, got %v", err)
+	}
+
+	fakePostgresTools(t, "14.11", "16.2")
+	_, _, _, err = openClient(context.Background(), postgresDriver{}, p, clientOptions{runtime: "auto", image: "postgres:15-alpine"})
+	if err == nil || !strings.Contains(err.Error(), "no container runtime is installed (docker, podman, nerdctl) and the local client cannot be used") {
+		t.Errorf("Expected a fallback error naming both runtimes, got %v", err)
+	}
+
+	_, _, _, err = openClient(context.Background(), postgresDriver{}, p, clientOptions{runtime: "local", image: "postgres:15-alpine"})
+	if err == nil || !strings.HasPrefix(err.Error(), "--runtime local: local pg_dump (PostgreSQL) 14.11 cannot dump") {
+		t.Errorf("Expected --runtime local to refuse older tools, got %v", err)
+	}
+}
+
+func TestOpenClientRunsSQLiteLocally(t *testing.T) {
+	path := sqliteFixture(t, t.TempDir())
+	d := sqliteDriver{}
+
+	client, conn, cleanup, err := openClient(context.Background(), d, ConnParams{Path: path}, clientOptions{runtime: "local"})
+	if err != nil {
+		t.Fatalf("Expected local client, got error: %v", err)
+	}
+	defer cleanup()
+
+	var dump bytes.Buffer
+	if err := client.dump(d.DumpCommand(conn, DumpFilters{}, DumpFormat{Name: "sqlite"}), &dump); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rows := sqliteRows(t, dump.Bytes()); rows != "ada" {
+		t.Errorf("Expected the committed row, got %q", rows)
+	}
+}
+
+func TestLocalClientCancelStopsWrappedTools(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client := localClient{ctx: ctx}
+
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start := time.Now()
+	// The shell's child keeps stdout open, so only stopping the whole
+	// process group lets the command return
+	if _, err := client.query([]string{"sh", "-c", "sleep 30; echo done"}); err == nil {
+		t.Fatal("Expected an error from a canceled command")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Expected the canceled command to stop promptly, took %v", elapsed)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package commands
+
+import "os/exec"
+
+// setProcessGroup leaves cmd as is: Windows has no process groups to
+// signal, so canceling kills the process itself
+func setProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,175 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// manifestSuffix is appended to a backup path to name its manifest
+const manifestSuffix = ".manifest.json"
+
+// manifestFormat is bumped whenever backupManifest changes incompatibly
+const manifestFormat = 1
+
+// backupManifest records how a backup file was produced. It is written as
+// JSON next to the dump so the file can be verified and traced later.
+type backupManifest struct {
+	Format           int          `json:"format"`
+	File             string       `json:"file"`
+	SHA256           string       `json:"sha256"`
+	Size             int64        `json:"size"`
+	UncompressedSize int64        `json:"uncompressed_size"`
+	Compression      string       `json:"compression,omitempty"`
+	CompressionLevel int          `json:"compression_level,omitempty"`
+	CompressedByTool bool         `json:"compressed_by_tool,omitempty"`
+	DumpFormat       string       `json:"dump_format,omitempty"`
+	Jobs             int          `json:"jobs,omitempty"`
+	Encrypted        bool         `json:"encrypted"`
+	StartedAt        time.Time    `json:"started_at"`
+	FinishedAt       time.Time    `json:"finished_at"`
+	Engine           string       `json:"engine"`
+	ServerVersion    string       `json:"server_version,omitempty"`
+	ClientImage      string       `json:"client_image"`
+	ClientTool       string       `json:"client_tool,omitempty"`
+	ClientContainer  string       `json:"client_container,omitempty"`
+	ToolVersion      string       `json:"tool_version,omitempty"`
+	Host             string       `json:"host"`
+	Port             int          `json:"port"`
+	Database         string       `json:"database"`
+	Path             string       `json:"path,omitempty"`
+	Username         string       `json:"username"`
+	SSHJump          string       `json:"ssh_jump,omitempty"`
+	Filters          *DumpFilters `json:"filters,omitempty"`
+	Command          []string     `json:"command"`
+}
+
+// setCompression records the codec of a dump in format. A dump the tool
+// compresses itself uses pg_dump's default gzip and no level of cutter's.
+func (m *backupManifest) setCompression(format string, compression compressionOptions) {
+	switch {
+	case !compression.enabled():
+	case compressedByTool(format):
+		m.Compression = "gzip"
+		m.CompressedByTool = true
+	default:
+		m.Compression = compression.codec
+		m.CompressionLevel = compression.level
+	}
+}
+
+// manifestPath returns where the manifest of backup lives
+func manifestPath(backup string) string {
+	return backup + manifestSuffix
+}
+
+// writeManifest stores m as indented JSON beside backup in store
+func writeManifest(store backupStore, backup string, m *backupManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+
+	out, err := store.Create(manifestPath(backup))
+	if err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	if _, err := out.Write(append(data, '\n')); err != nil {
+		out.Abort()
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	if err := out.Commit(); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	return nil
+}
+
+// readManifest loads the manifest at path
+func readManifest(path string) (*backupManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+
+	var m backupManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %v", path, err)
+	}
+	if m.Format > manifestFormat {
+		return nil, fmt.Errorf("manifest %s uses format %d; this cutter understands up to %d", path, m.Format, manifestFormat)
+	}
+	return &m, nil
+}
+
+// hashFile returns the SHA-256 and size of the file at path
+func hashFile(path string) (string, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to open backup file: %v", err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read backup file: %v", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), size, nil
+}
+
+// verifyBackup re-hashes backup and compares it with its manifest
+func verifyBackup(backup string) (*backupManifest, error) {
+	m, err := readManifest(manifestPath(backup))
+	if err != nil {
+		return nil, err
+	}
+
+	sum, size, err := hashPath(backup)
+	if err != nil {
+		return m, err
+	}
+
+	if size != m.Size {
+		return m, fmt.Errorf("size mismatch: manifest records %d bytes, file has %d", m.Size, size)
+	}
+	if sum != m.SHA256 {
+		return m, fmt.Errorf("checksum mismatch: manifest records %s, file hashes to %s", m.SHA256, sum)
+	}
+	return m, nil
+}
+
+func newDBVerifyCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify <file>",
+		Short: "Check a backup file against its manifest",
+		Args:  cobra.ExactArgs(1),
+		Example: `  # Verify a backup before restoring it
+  cutter db verify mydb_20240101_120000.sql.gz`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			backup := strings.TrimSuffix(args[0], manifestSuffix)
+
+			m, err := verifyBackup(backup)
+			if err != nil {
+				return fmt.Errorf("verification failed for %s: %v", backup, err)
+			}
+
+			fmt.Printf("✓ Backup verified: %s\n", backup)
+			fmt.Printf("  SHA-256:  %s\n", m.SHA256)
+			fmt.Printf("  Size:     %d bytes (%d uncompressed)\n", m.Size, m.UncompressedSize)
+			if m.Path != "" {
+				fmt.Printf("  Database: %s (%s %s)\n", m.Path, m.Engine, m.ServerVersion)
+			} else {
+				fmt.Printf("  Database: %s on %s:%d (%s %s)\n", m.Database, m.Host, m.Port, m.Engine, m.ServerVersion)
+			}
+			fmt.Printf("  Created:  %s\n", m.FinishedAt.Local().Format(time.RFC3339))
+			return nil
+		},
+	}
+}
@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestBackup dumps content through streamDump and records a manifest
+func writeTestBackup(t *testing.T, content string) string {
+	t.Helper()
+	fakeDocker(t)
+
+	output := filepath.Join(t.TempDir(), "orders.sql.gz")
+	stats, err := streamDump("pg_dump", commandDump(exec.Command(dockerBinary, content)), localStore{dir: filepath.Dir(output)}, filepath.Base(output), compressionOptions{codec: "gzip"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	m := &backupManifest{
+		Format:           manifestFormat,
+		File:             filepath.Base(output),
+		SHA256:           stats.SHA256,
+		Size:             stats.FileBytes,
+		UncompressedSize: stats.RawBytes,
+		Compression:      "gzip",
+		StartedAt:        time.Now().UTC(),
+		FinishedAt:       time.Now().UTC(),
+		Engine:           "postgres",
+		ServerVersion:    "15.4",
+		ClientImage:      "postgres:15-alpine",
+		Host:             "db.internal",
+		Port:             5432,
+		Database:         "orders",
+		Username:         "app",
+		Filters:          &DumpFilters{SchemaOnly: true, ExcludeTables: []string{"audit_*"}},
+		Command:          []string{"pg_dump", "--host=db.internal"},
+	}
+	if err := writeManifest(localStore{dir: filepath.Dir(output)}, filepath.Base(output), m); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	return output
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	output := writeTestBackup(t, "SELECT 1;")
+
+	m, err := readManifest(manifestPath(output))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if m.Engine != "postgres" || m.ServerVersion != "15.4" || m.Port != 5432 {
+		t.Errorf("Expected manifest fields to survive, got %+v", m)
+	}
+	if m.Filters == nil || !m.Filters.SchemaOnly || len(m.Filters.ExcludeTables) != 1 {
+		t.Errorf("Expected filters to survive, got %+v", m.Filters)
+	}
+	if m.UncompressedSize != int64(len("SELECT 1;\n")) {
+		t.Errorf("Expected uncompressed size %d, got %d", len("SELECT 1;\n"), m.UncompressedSize)
+	}
+
+	info, _ := os.Stat(output)
+	if m.Size != info.Size() {
+		t.Errorf("Expected size %d, got %d", info.Size(), m.Size)
+	}
+}
+
+func TestManifestSetCompression(t *testing.T) {
+	tests := []struct {
+		format      string
+		compression compressionOptions
+		want        string
+		level       int
+		byTool      bool
+	}{
+		{"plain", compressionOptions{codec: "zstd", level: 9}, "zstd", 9, false},
+		{"plain", compressionOptions{codec: "none"}, "", 0, false},
+		{"custom", compressionOptions{codec: "gzip", level: 6}, "gzip", 0, true},
+		{"directory", compressionOptions{codec: "gzip"}, "gzip", 0, true},
+		{"custom", compressionOptions{codec: "none"}, "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format+" "+tt.compression.codec, func(t *testing.T) {
+			var m backupManifest
+			m.setCompression(tt.format, tt.compression)
+			if m.Compression != tt.want || m.CompressionLevel != tt.level || m.CompressedByTool != tt.byTool {
+				t.Errorf("Expected %q level %d by tool %v, got %q level %d by tool %v",
+					tt.want, tt.level, tt.byTool, m.Compression, m.CompressionLevel, m.CompressedByTool)
+			}
+		})
+	}
+}
+
+func TestVerifyBackup(t *testing.T) {
+	output := writeTestBackup(t, "SELECT 1;")
+
+	if _, err := verifyBackup(output); err != nil {
+		t.Errorf("Expected untouched backup to verify, got %v", err)
+	}
+
+	data, _ := os.ReadFile(output)
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(output, data, 0600)
+	if _, err := verifyBackup(output); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected checksum mismatch, got %v", err)
+	}
+
+	os.WriteFile(output, append(data, 0), 0600)
+	if _, err := verifyBackup(output); err == nil || !strings.Contains(err.Error(), "size mismatch") {
+		t.Errorf("Expected size mismatch, got %v", err)
+	}
+}
+
+func TestVerifyBackupWithoutManifest(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "orphan.sql")
+	os.WriteFile(output, []byte("SELECT 1;"), 0600)
+
+	if _, err := verifyBackup(output); err == nil || !strings.Contains(err.Error(), "failed to read manifest") {
+		t.Errorf("Expected missing manifest error, got %v", err)
+	}
+}
+
+func TestReadManifestRejectsNewerFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.sql"+manifestSuffix)
+	os.WriteFile(path, []byte(`{"format": 99}`), 0600)
+
+	if _, err := readManifest(path); err == nil || !strings.Contains(err.Error(), "format 99") {
+		t.Errorf("Expected format error, got %v", err)
+	}
+}
+
+func TestDBVerifyCmdAcceptsManifestPath(t *testing.T) {
+	output := writeTestBackup(t, "SELECT 1;")
+
+	cmd := newDBVerifyCmd()
+	if err := cmd.RunE(cmd, []string{manifestPath(output)}); err != nil {
+		t.Errorf("Expected verify through the manifest path to succeed, got %v", err)
+	}
+}
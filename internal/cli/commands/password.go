@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// passwordOptions are the mutually exclusive ways to supply a password
+type passwordOptions struct {
+	value string
+	file  string
+	env   string
+}
+
+// addPasswordFlags registers --password, --password-file and --password-env
+func addPasswordFlags(cmd *cobra.Command, opts *passwordOptions) {
+	cmd.Flags().StringVar(&opts.value, "password", "", "Database password (visible in process lists; prefer --password-file, --password-env or the prompt)")
+	cmd.Flags().StringVar(&opts.file, "password-file", "", "Read the database password from a file")
+	cmd.Flags().StringVar(&opts.env, "password-env", "", "Read the database password from the named environment variable")
+	cmd.MarkFlagsMutuallyExclusive("password", "password-file", "password-env")
+}
+
+// isTerminal reports whether stdin is interactive; tests replace it
+var isTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// readPassword reads a line from the terminal without echo; tests replace it
+var readPassword = func() (string, error) {
+	b, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return string(b), err
+}
+
+// resolvePassword finds the password for p. Sources are tried in order:
+// --password, --password-file, --password-env, $CUTTER_PASSWORD, the
+// driver's own credential file (~/.pgpass, ~/.my.cnf), and finally an
+// interactive prompt. Without a terminal and without any source the
+// password is left empty for servers using trust or peer authentication.
+func resolvePassword(opts passwordOptions, d BackupDriver, p ConnParams) (string, error) {
+	switch {
+	case d.PasswordEnv() == "":
+		// The engine has no passwords, so there is nothing to ask for
+		return "", nil
+	case opts.value != "":
+		fmt.Fprintln(os.Stderr, "Warning: --password is visible in process lists and shell history; prefer --password-file, --password-env or the prompt")
+		return opts.value, nil
+	case opts.file != "":
+		data, err := os.ReadFile(expandUserPath(opts.file))
+		if err != nil {
+			return "", fmt.Errorf("failed to read password file: %v", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case opts.env != "":
+		value, ok := os.LookupEnv(opts.env)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", opts.env)
+		}
+		return value, nil
+	}
+
+	if value := os.Getenv("CUTTER_PASSWORD"); value != "" {
+		return value, nil
+	}
+
+	if value, ok := d.StoredPassword(p); ok {
+		return value, nil
+	}
+
+	if !isTerminal() {
+		return "", nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Password for %s@%s:%d: ", p.Username, p.Host, p.Port)
+	value, err := readPassword()
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %v", err)
+	}
+	return value, nil
+}
+
+// writeEnvFile stores the password in a private docker --env-file so it
+// never appears on a command line. The caller must remove the file.
+func writeEnvFile(name, value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("password must not contain line breaks")
+	}
+
+	file, err := os.CreateTemp("", "cutter-env-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create env file: %v", err)
+	}
+
+	if err := file.Chmod(0600); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to secure env file: %v", err)
+	}
+
+	if _, err := fmt.Fprintf(file, "%s=%s\n", name, value); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write env file: %v", err)
+	}
+
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", fmt.Errorf("failed to write env file: %v", err)
+	}
+	return file.Name(), nil
+}
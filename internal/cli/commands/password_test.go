@@ -0,0 +1,207 @@
+package commands
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PandhuWibowo/go-devops-cutter/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// stubTerminal replaces the terminal hooks for the duration of a test
+func stubTerminal(t *testing.T, interactive bool, typed string, err error) *int {
+	t.Helper()
+
+	prompts := 0
+	origIsTerminal, origReadPassword := isTerminal, readPassword
+	isTerminal = func() bool { return interactive }
+	readPassword = func() (string, error) {
+		prompts++
+		return typed, err
+	}
+	t.Cleanup(func() {
+		isTerminal, readPassword = origIsTerminal, origReadPassword
+	})
+	return &prompts
+}
+
+// isolatePasswordSources hides the user's real credential files and env
+func isolatePasswordSources(t *testing.T) {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("PGPASSFILE", "")
+	t.Setenv("CUTTER_PASSWORD", "")
+}
+
+func TestResolvePasswordSources(t *testing.T) {
+	isolatePasswordSources(t)
+	stubTerminal(t, false, "", nil)
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	os.WriteFile(passwordFile, []byte("from-file\n"), 0600)
+	t.Setenv("MY_DB_PASSWORD", "from-env")
+
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "app", Database: "orders"}
+
+	tests := []struct {
+		name string
+		opts passwordOptions
+		want string
+	}{
+		{"Flag", passwordOptions{value: "from-flag"}, "from-flag"},
+		{"File", passwordOptions{file: passwordFile}, "from-file"},
+		{"Env", passwordOptions{env: "MY_DB_PASSWORD"}, "from-env"},
+		{"Nothing without terminal", passwordOptions{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolvePassword(tt.opts, postgresDriver{}, p)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolvePasswordErrors(t *testing.T) {
+	isolatePasswordSources(t)
+	stubTerminal(t, false, "", nil)
+
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "app"}
+
+	if _, err := resolvePassword(passwordOptions{file: filepath.Join(t.TempDir(), "missing")}, postgresDriver{}, p); err == nil {
+		t.Error("Expected error for missing password file")
+	}
+
+	os.Unsetenv("CUTTER_TEST_UNSET_PASSWORD")
+	_, err := resolvePassword(passwordOptions{env: "CUTTER_TEST_UNSET_PASSWORD"}, postgresDriver{}, p)
+	if err == nil || !strings.Contains(err.Error(), "CUTTER_TEST_UNSET_PASSWORD") {
+		t.Errorf("Expected unset variable error, got %v", err)
+	}
+}
+
+func TestResolvePasswordCutterEnv(t *testing.T) {
+	isolatePasswordSources(t)
+	prompts := stubTerminal(t, true, "typed", nil)
+	t.Setenv("CUTTER_PASSWORD", "from-cutter-env")
+
+	got, err := resolvePassword(passwordOptions{}, postgresDriver{}, ConnParams{})
+	if err != nil || got != "from-cutter-env" {
+		t.Errorf("Expected CUTTER_PASSWORD, got %q (%v)", got, err)
+	}
+	if *prompts != 0 {
+		t.Error("Expected no prompt when CUTTER_PASSWORD is set")
+	}
+}
+
+func TestResolvePasswordPgpass(t *testing.T) {
+	isolatePasswordSources(t)
+	prompts := stubTerminal(t, true, "typed", nil)
+
+	home, _ := os.UserHomeDir()
+	os.WriteFile(filepath.Join(home, ".pgpass"), []byte("localhost:5432:orders:app:from-pgpass\n"), 0600)
+
+	p := ConnParams{Host: "localhost", Port: 5432, Username: "app", Database: "orders"}
+	got, err := resolvePassword(passwordOptions{}, postgresDriver{}, p)
+	if err != nil || got != "from-pgpass" {
+		t.Errorf("Expected password from ~/.pgpass, got %q (%v)", got, err)
+	}
+	if *prompts != 0 {
+		t.Error("Expected no prompt when ~/.pgpass matches")
+	}
+}
+
+func TestResolvePasswordPrompt(t *testing.T) {
+	isolatePasswordSources(t)
+	prompts := stubTerminal(t, true, "typed", nil)
+
+	got, err := resolvePassword(passwordOptions{}, mysqlDriver{}, ConnParams{Host: "db", Port: 3306, Username: "root"})
+	if err != nil || got != "typed" {
+		t.Errorf("Expected prompted password, got %q (%v)", got, err)
+	}
+	if *prompts != 1 {
+		t.Errorf("Expected one prompt, got %d", *prompts)
+	}
+
+	got, err = resolvePassword(passwordOptions{}, sqliteDriver{}, ConnParams{Path: "/srv/app/data.db"})
+	if err != nil || got != "" || *prompts != 1 {
+		t.Errorf("Expected no prompt for a database without passwords, got %q (%v)", got, err)
+	}
+
+	stubTerminal(t, true, "", errors.New("interrupted"))
+	if _, err := resolvePassword(passwordOptions{}, mysqlDriver{}, ConnParams{}); err == nil {
+		t.Error("Expected error when the prompt fails")
+	}
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	path, err := writeEnvFile("PGPASSWORD", "s3cr=t value")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	defer os.Remove(path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Env file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "PGPASSWORD=s3cr=t value\n" {
+		t.Errorf("Unexpected env file content %q", content)
+	}
+
+	if _, err := writeEnvFile("PGPASSWORD", "line\nbreak"); err == nil {
+		t.Error("Expected error for password with a line break")
+	}
+}
+
+func TestPasswordFlagsMutuallyExclusive(t *testing.T) {
+	cmd := newDBBackupCmd()
+	cmd.SetArgs([]string{"--database", "db", "--username", "u", "--password", "x", "--password-env", "Y"})
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+
+	if err := cmd.Execute(); err == nil {
+		t.Error("Expected error when combining --password and --password-env")
+	}
+}
+
+func TestPrepareReadsProfilePasswordFileFromHome(t *testing.T) {
+	isolatePasswordSources(t)
+	stubTerminal(t, false, "", nil)
+	writeTestConfig(t, map[string]config.Profile{
+		"prod-orders": {Type: "postgres", Username: "app", Database: "orders", PasswordFile: "~/.secrets/db"},
+	})
+	t.Setenv("CUTTER_DSN", "")
+
+	home, _ := os.UserHomeDir()
+	os.MkdirAll(filepath.Join(home, ".secrets"), 0700)
+	if err := os.WriteFile(filepath.Join(home, ".secrets", "db"), []byte("from-profile-file\n"), 0600); err != nil {
+		t.Fatalf("Failed to write password file: %v", err)
+	}
+
+	var opts connectionOptions
+	cmd := &cobra.Command{}
+	addConnectionFlags(cmd, &opts, "Database name")
+	if err := cmd.ParseFlags([]string{"--profile", "prod-orders"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	if _, err := opts.prepare(cmd); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if opts.password != "from-profile-file" {
+		t.Errorf("Expected the password from ~/.secrets/db, got %q", opts.password)
+	}
+}
@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sync/atomic"
+
+	"filippo.io/age"
+)
+
+// unsafeFilenameChars matches everything we do not want in a generated
+// backup filename
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// safeFilename turns a database name into a single, harmless path element
+func safeFilename(name string) string {
+	cleaned := unsafeFilenameChars.ReplaceAllString(name, "_")
+	if cleaned == "" || cleaned == "." || cleaned == ".." {
+		return "backup"
+	}
+	return cleaned
+}
+
+// dumpStats describes a finished dump
+type dumpStats struct {
+	// RawBytes is what the dump tool produced, before compression
+	RawBytes int64
+	// FileBytes and SHA256 describe the file as written to disk
+	FileBytes int64
+	SHA256    string
+}
+
+// countingWriter counts the bytes passing through to w. The count may be
+// read while writes are in flight.
+type countingWriter struct {
+	w io.Writer
+	n atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+// dumpFunc runs a dump tool with its stdout connected to stdout
+type dumpFunc func(stdout io.Writer) error
+
+// streamDump runs dump and streams its stdout into name in store,
+// compressed as configured and encrypted to recipients when any are given,
+// so plaintext never reaches the destination. No shell is involved
+// locally, so the output path needs no quoting. progress, when not nil,
+// reports the byte counts while the dump runs.
+func streamDump(tool string, dump dumpFunc, store backupStore, name string, compression compressionOptions, recipients []age.Recipient, progress *progressReporter) (dumpStats, error) {
+	out, err := store.Create(name)
+	if err != nil {
+		return dumpStats{}, err
+	}
+
+	hash := sha256.New()
+	written := &countingWriter{w: io.MultiWriter(out, hash)}
+
+	var w io.Writer = written
+	var encrypted io.WriteCloser
+	if len(recipients) > 0 {
+		encrypted, err = age.Encrypt(w, recipients...)
+		if err != nil {
+			out.Abort()
+			return dumpStats{}, fmt.Errorf("failed to start encryption: %v", err)
+		}
+		w = encrypted
+	}
+
+	var compressed io.WriteCloser
+	if compression.enabled() {
+		compressed, err = newCompressWriter(w, compression)
+		if err != nil {
+			if encrypted != nil {
+				encrypted.Close()
+			}
+			out.Abort()
+			return dumpStats{}, err
+		}
+		w = compressed
+	}
+
+	raw := &countingWriter{w: w}
+	progress.track(raw.n.Load, written.n.Load)
+	err = dump(raw)
+	if err != nil {
+		err = fmt.Errorf("%s failed: %v", tool, err)
+	}
+
+	if compressed != nil {
+		if closeErr := compressed.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to finish compression: %v", closeErr)
+		}
+	}
+	if encrypted != nil {
+		if closeErr := encrypted.Close(); closeErr != nil && err == nil {
+			err = fmt.Errorf("failed to finish encryption: %v", closeErr)
+		}
+	}
+
+	if err != nil {
+		if abortErr := out.Abort(); abortErr != nil {
+			err = errors.Join(err, abortErr)
+		}
+	} else {
+		err = out.Commit()
+	}
+	progress.finish(err)
+
+	return dumpStats{
+		RawBytes:  raw.n.Load(),
+		FileBytes: written.n.Load(),
+		SHA256:    hex.EncodeToString(hash.Sum(nil)),
+	}, err
+}
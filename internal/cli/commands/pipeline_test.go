@@ -0,0 +1,223 @@
+package commands
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// commandDump runs cmd as the dumpFunc of streamDump and streamDumpDir,
+// passing its stderr through unless the test has set one
+func commandDump(cmd *exec.Cmd) dumpFunc {
+	return func(stdout io.Writer) error {
+		cmd.Stdout = stdout
+		if cmd.Stderr == nil {
+			cmd.Stderr = os.Stderr
+		}
+		return cmd.Run()
+	}
+}
+
+// fakeDocker installs a script standing in for docker that prints each of
+// its arguments on its own line, or exits with status 3 when asked to
+func fakeDocker(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake docker script needs a POSIX shell")
+	}
+
+	script := filepath.Join(t.TempDir(), "docker")
+	body := "#!/bin/sh\nfor arg in \"$@\"; do\n  [ \"$arg\" = fail ] && exit 3\n  printf '%s\\n' \"$arg\"\ndone\n"
+	if err := os.WriteFile(script, []byte(body), 0700); err != nil {
+		t.Fatalf("Failed to write fake docker: %v", err)
+	}
+
+	original := dockerBinary
+	dockerBinary = script
+	t.Cleanup(func() { dockerBinary = original })
+}
+
+func readDump(t *testing.T, path string, compressed bool) string {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open dump: %v", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if compressed {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			t.Fatalf("Expected gzip output, got %v", err)
+		}
+		r = gz
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("Failed to read dump: %v", err)
+	}
+	return string(data)
+}
+
+func TestStreamDumpKeepsHostileValuesIntact(t *testing.T) {
+	fakeDocker(t)
+
+	database := `it's "my" db; $(touch pwned) -x`
+	output := filepath.Join(t.TempDir(), `out put's "file"; rm -rf $HOME.sql.gz`)
+
+	client := dockerClient{image: "postgres:15-alpine"}
+	dump := client.command(false, (postgresDriver{}).DumpCommand(ConnParams{
+		Host: "db", Port: 5432, Username: "-U root", Database: database,
+	}, DumpFilters{}, DumpFormat{Name: "plain"}))
+	if _, err := streamDump("pg_dump", commandDump(dump), localStore{dir: filepath.Dir(output)}, filepath.Base(output), compressionOptions{codec: "gzip"}, nil, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(readDump(t, output, true)), "\n")
+	want := []string{"run", "--rm", "postgres:15-alpine", "pg_dump", "--host=db", "--port=5432",
+		"--username=-U root", "--dbname=dbname='" + `it\'s "my" db; $(touch pwned) -x` + "'"}
+	if strings.Join(lines, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected args %q, got %q", want, lines)
+	}
+
+	if _, err := os.Stat("pwned"); err == nil {
+		os.Remove("pwned")
+		t.Error("Database name was executed by a shell")
+	}
+}
+
+func TestStreamDumpUncompressed(t *testing.T) {
+	fakeDocker(t)
+
+	output := filepath.Join(t.TempDir(), "plain.sql")
+	stats, err := streamDump("pg_dump", commandDump(exec.Command(dockerBinary, "hello")), localStore{dir: filepath.Dir(output)}, filepath.Base(output), compressionOptions{codec: "none"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if stats.RawBytes != 6 || stats.FileBytes != 6 {
+		t.Errorf("Expected 6 bytes raw and on disk, got %d and %d", stats.RawBytes, stats.FileBytes)
+	}
+	// sha256 of "hello\n"
+	if stats.SHA256 != "5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03" {
+		t.Errorf("Expected checksum of the file, got %s", stats.SHA256)
+	}
+
+	if got := readDump(t, output, false); got != "hello\n" {
+		t.Errorf("Expected plain output, got %q", got)
+	}
+
+	info, err := os.Stat(output)
+	if err != nil {
+		t.Fatalf("Expected output file, got %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %o", info.Mode().Perm())
+	}
+}
+
+func TestStreamDumpPropagatesFailure(t *testing.T) {
+	fakeDocker(t)
+
+	output := filepath.Join(t.TempDir(), "failed.sql.gz")
+	_, err := streamDump("pg_dump", commandDump(exec.Command(dockerBinary, "partial", "fail")), localStore{dir: filepath.Dir(output)}, filepath.Base(output), compressionOptions{codec: "gzip"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("Expected exit status error, got %v", err)
+	}
+}
+
+func TestStreamDumpCanceled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake docker script needs a POSIX shell")
+	}
+
+	// The fake docker records rm calls and otherwise streams a partial
+	// dump and hangs like a long pg_dump would
+	dir := t.TempDir()
+	script := filepath.Join(dir, "docker")
+	body := "#!/bin/sh\n[ \"$1\" = rm ] && { echo \"$@\" > \"$0.rm\"; exit 0; }\necho 'CREATE TABLE users'\nexec sleep 30\n"
+	if err := os.WriteFile(script, []byte(body), 0700); err != nil {
+		t.Fatalf("Failed to write fake docker: %v", err)
+	}
+	original := dockerBinary
+	dockerBinary = script
+	t.Cleanup(func() { dockerBinary = original })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := dockerClient{image: "postgres:15-alpine", ctx: ctx}
+	dump := client.command(false, []string{"pg_dump"})
+	time.AfterFunc(200*time.Millisecond, cancel)
+
+	output := t.TempDir()
+	_, err := streamDump("pg_dump", commandDump(dump), localStore{dir: output}, "orders.sql", compressionOptions{codec: "none"}, nil, nil)
+	if err == nil {
+		t.Fatal("Expected an error from the canceled dump")
+	}
+
+	if entries, _ := os.ReadDir(output); len(entries) != 0 {
+		t.Errorf("Expected no output after cancel, got %v", entries)
+	}
+	rm, _ := os.ReadFile(script + ".rm")
+	if want := "rm --force " + dump.Args[4]; strings.TrimSpace(string(rm)) != want {
+		t.Errorf("Expected %q to remove the container, got %q", want, rm)
+	}
+}
+
+func TestSafeFilename(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"orders", "orders"},
+		{"my db", "my_db"},
+		{"../../etc/passwd", ".._.._etc_passwd"},
+		{`a'b"c;$(d)`, "a_b_c_d_"},
+		{"..", "backup"},
+		{"", "backup"},
+	}
+
+	for _, tt := range tests {
+		if got := safeFilename(tt.input); got != tt.want {
+			t.Errorf("safeFilename(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// failingAbortStore is a localStore whose partial files cannot be removed
+type failingAbortStore struct {
+	localStore
+}
+
+func (s failingAbortStore) Create(name string) (backupWriter, error) {
+	w, err := s.localStore.Create(name)
+	return failingAbortWriter{w}, err
+}
+
+type failingAbortWriter struct {
+	backupWriter
+}
+
+func (w failingAbortWriter) Abort() error {
+	w.backupWriter.Abort()
+	return errors.New("failed to remove partial file")
+}
+
+func TestStreamDumpKeepsDumpErrorWhenAbortFails(t *testing.T) {
+	fakeDocker(t)
+
+	store := failingAbortStore{localStore{dir: t.TempDir()}}
+	_, err := streamDump("pg_dump", commandDump(exec.Command(dockerBinary, "partial", "fail")), store, "orders.sql", compressionOptions{codec: "none"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "pg_dump failed: exit status 3") || !strings.Contains(err.Error(), "failed to remove partial file") {
+		t.Errorf("Expected both the dump and the cleanup error, got %v", err)
+	}
+}
@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/PandhuWibowo/go-devops-cutter/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// connectionFlags are the db flags that can be filled from the environment
+// or a profile when not given on the command line
+var connectionFlags = []string{"type", "host", "port", "username", "password-file", "password-env", "database",
+	"auth-database", "replica-set", "path", "ssh-jump", "client-image", "runtime",
+	"container", "docker-network", "pull-policy", "memory", "cpus",
+	"encrypt-recipient", "identity", "output", "s3-endpoint", "s3-region", "s3-insecure"}
+
+// flagGroups are connection flags that pick one of several alternatives.
+// Once a source sets any flag of a group, lower sources fill none of them.
+var flagGroups = [][]string{
+	{"password", "password-file", "password-env"},
+	{"container", "docker-network"},
+}
+
+// addProfileFlag registers the --profile flag shared by db subcommands
+func addProfileFlag(cmd *cobra.Command, profile *string) {
+	cmd.Flags().StringVar(profile, "profile", "", "Connection profile from the cutter config file")
+}
+
+// envVarForFlag maps a flag name to its CUTTER_* environment variable
+func envVarForFlag(name string) string {
+	return "CUTTER_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyConnectionDefaults fills unset connection flags on cmd. Explicit
+// flags win, then CUTTER_* environment variables, then the named profile
+// (or $CUTTER_PROFILE), then the flag defaults.
+func applyConnectionDefaults(cmd *cobra.Command, profileName string) error {
+	if profileName == "" {
+		profileName = os.Getenv("CUTTER_PROFILE")
+	}
+
+	profile, err := loadProfile(profileName)
+	if err != nil {
+		return err
+	}
+
+	profileValues := map[string]string{
+		"type":              profile.Type,
+		"host":              profile.Host,
+		"username":          profile.Username,
+		"password-file":     profile.PasswordFile,
+		"password-env":      profile.PasswordEnv,
+		"database":          profile.Database,
+		"auth-database":     profile.AuthDatabase,
+		"replica-set":       profile.ReplicaSet,
+		"path":              profile.Path,
+		"ssh-jump":          profile.SSHJump,
+		"client-image":      profile.ClientImage,
+		"container":         profile.Container,
+		"docker-network":    profile.DockerNetwork,
+		"encrypt-recipient": profile.EncryptRecipient,
+		"identity":          profile.Identity,
+		"output":            profile.Output,
+		"s3-endpoint":       profile.Storage.Endpoint,
+		"s3-region":         profile.Storage.Region,
+	}
+	if profile.Port != 0 {
+		profileValues["port"] = strconv.Itoa(profile.Port)
+	}
+	if profile.Storage.Insecure {
+		profileValues["s3-insecure"] = "true"
+	}
+
+	flags := cmd.Flags()
+	claimed := claimedGroups(cmd)
+	for _, name := range connectionFlags {
+		flag := flags.Lookup(name)
+		if flag == nil || flag.Changed || claimed[name] == "flag" {
+			continue
+		}
+
+		value, source := os.Getenv(envVarForFlag(name)), envVarForFlag(name)
+		if value == "" && claimed[name] != "env" {
+			value, source = profileValues[name], "profile "+profileName
+		}
+		if value == "" {
+			continue
+		}
+
+		if err := flags.Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s from %s: %v", name, source, err)
+		}
+	}
+
+	return nil
+}
+
+// loadProfile reads the named profile from the cutter config file. An
+// empty name gives an empty profile.
+func loadProfile(name string) (config.Profile, error) {
+	if name == "" {
+		return config.Profile{}, nil
+	}
+	path, err := config.DefaultPath()
+	if err != nil {
+		return config.Profile{}, err
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		return config.Profile{}, err
+	}
+	profile, err := cfg.Profile(name)
+	if err != nil {
+		return config.Profile{}, fmt.Errorf("%v in %s", err, path)
+	}
+	return profile, nil
+}
+
+// defaultLocation is where db list and db prune look without a location
+// argument: $CUTTER_OUTPUT, then the output of the named profile (or
+// $CUTTER_PROFILE), then the current directory
+func defaultLocation(profileName string) (string, error) {
+	if value := os.Getenv(envVarForFlag("output")); value != "" {
+		return value, nil
+	}
+	if profileName == "" {
+		profileName = os.Getenv("CUTTER_PROFILE")
+	}
+	profile, err := loadProfile(profileName)
+	if err != nil {
+		return "", err
+	}
+	if profile.Output != "" {
+		return profile.Output, nil
+	}
+	return ".", nil
+}
+
+// claimedGroups maps each flag of flagGroups to the highest source setting
+// any flag of its group: "flag" for the command line or "env" for CUTTER_*
+// environment variables
+func claimedGroups(cmd *cobra.Command) map[string]string {
+	claimed := map[string]string{}
+	for _, group := range flagGroups {
+		source := ""
+		for _, name := range group {
+			if flag := cmd.Flags().Lookup(name); flag != nil && flag.Changed {
+				source = "flag"
+				break
+			}
+			if os.Getenv(envVarForFlag(name)) != "" {
+				source = "env"
+			}
+		}
+		for _, name := range group {
+			claimed[name] = source
+		}
+	}
+	return claimed
+}
+
+// requireFlags reports the named flags that are still empty after
+// applyConnectionDefaults
+func requireFlags(cmd *cobra.Command, names ...string) error {
+	var missing []string
+	for _, name := range names {
+		if flag := cmd.Flags().Lookup(name); flag != nil && flag.Value.String() == "" {
+			missing = append(missing, strconv.Quote(name))
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("required flag(s) %s not set (pass them, use CUTTER_* environment variables or --profile)",
+			strings.Join(missing, ", "))
+	}
+	return nil
+}
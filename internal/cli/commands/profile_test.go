@@ -0,0 +1,320 @@
+package commands
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PandhuWibowo/go-devops-cutter/internal/config"
+)
+
+// writeTestConfig points CUTTER_CONFIG at a temporary file holding profiles
+func writeTestConfig(t *testing.T, profiles map[string]config.Profile) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	t.Setenv("CUTTER_CONFIG", path)
+	for _, name := range connectionFlags {
+		t.Setenv(envVarForFlag(name), "")
+	}
+	t.Setenv("CUTTER_PROFILE", "")
+	t.Setenv("CUTTER_PASSWORD", "")
+
+	cfg := &config.Config{Profiles: profiles}
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	return path
+}
+
+func TestEnvVarForFlag(t *testing.T) {
+	if got := envVarForFlag("ssh-jump"); got != "CUTTER_SSH_JUMP" {
+		t.Errorf("Expected CUTTER_SSH_JUMP, got %s", got)
+	}
+	if got := envVarForFlag("host"); got != "CUTTER_HOST" {
+		t.Errorf("Expected CUTTER_HOST, got %s", got)
+	}
+}
+
+func TestApplyConnectionDefaultsPrecedence(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{
+		"prod-orders": {
+			Type:     "mysql",
+			Host:     "profile-host",
+			Port:     3307,
+			Username: "profile-user",
+			Database: "orders",
+			SSHJump:  "bastion",
+		},
+	})
+	t.Setenv("CUTTER_HOST", "env-host")
+
+	cmd := newDBBackupCmd()
+	if err := cmd.ParseFlags([]string{"--username", "flag-user"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	if err := applyConnectionDefaults(cmd, "prod-orders"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := map[string]string{
+		"username": "flag-user", // explicit flag
+		"host":     "env-host",  // environment
+		"type":     "mysql",     // profile
+		"port":     "3307",      // profile
+		"database": "orders",    // profile
+		"ssh-jump": "bastion",   // profile
+		"output":   "",          // not set in the profile
+		"compress": "true",      // untouched default
+		"password": "",          // never stored in profiles
+	}
+	for name, value := range want {
+		if got := cmd.Flags().Lookup(name).Value.String(); got != value {
+			t.Errorf("Expected %s=%q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestApplyConnectionDefaultsProfileFromEnv(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{
+		"staging": {Database: "staging_db"},
+	})
+	t.Setenv("CUTTER_PROFILE", "staging")
+
+	cmd := newDBRestoreCmd()
+	if err := applyConnectionDefaults(cmd, ""); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := cmd.Flags().Lookup("database").Value.String(); got != "staging_db" {
+		t.Errorf("Expected database from CUTTER_PROFILE, got %q", got)
+	}
+}
+
+func TestDefaultLocation(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{
+		"prod-orders": {Output: "~/backups/"},
+		"staging":     {Database: "staging_db"},
+	})
+
+	tests := []struct {
+		profile  string
+		env      string
+		expected string
+	}{
+		{"", "", "."},
+		{"staging", "", "."},
+		{"prod-orders", "", "~/backups/"},
+		{"prod-orders", "s3://backups/orders/", "s3://backups/orders/"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("CUTTER_OUTPUT", tt.env)
+		got, err := defaultLocation(tt.profile)
+		if err != nil {
+			t.Fatalf("Expected no error for profile %q, got %v", tt.profile, err)
+		}
+		if got != tt.expected {
+			t.Errorf("Expected %q for profile %q and CUTTER_OUTPUT %q, got %q", tt.expected, tt.profile, tt.env, got)
+		}
+	}
+}
+
+func TestApplyConnectionDefaultsStorage(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{
+		"offsite": {
+			Output:  "s3://backups/orders/",
+			Storage: config.Storage{Endpoint: "minio.internal:9000", Insecure: true},
+		},
+	})
+
+	cmd := newDBBackupCmd()
+	if err := applyConnectionDefaults(cmd, "offsite"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := map[string]string{
+		"output":      "s3://backups/orders/",
+		"s3-endpoint": "minio.internal:9000",
+		"s3-insecure": "true",
+	}
+	for name, value := range want {
+		if got := cmd.Flags().Lookup(name).Value.String(); got != value {
+			t.Errorf("Expected %s=%q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestApplyConnectionDefaultsMongo(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{
+		"events": {Type: "mongodb", Host: "m1:27017,m2:27017", AuthDatabase: "admin", ReplicaSet: "rs0"},
+	})
+	t.Setenv("CUTTER_REPLICA_SET", "rs1")
+
+	cmd := newDBRestoreCmd()
+	if err := applyConnectionDefaults(cmd, "events"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	want := map[string]string{
+		"host":          "m1:27017,m2:27017",
+		"auth-database": "admin",
+		"replica-set":   "rs1",
+	}
+	for name, value := range want {
+		if got := cmd.Flags().Lookup(name).Value.String(); got != value {
+			t.Errorf("Expected %s=%q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestApplyConnectionDefaultsSQLite(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{
+		"app-data": {Type: "sqlite", Path: "/srv/app/data.db", SSHJump: "deploy@app1"},
+	})
+
+	cmd := newDBBackupCmd()
+	if err := applyConnectionDefaults(cmd, "app-data"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := cmd.Flags().Lookup("path").Value.String(); got != "/srv/app/data.db" {
+		t.Errorf("Expected path from profile, got %q", got)
+	}
+}
+
+func TestApplyConnectionDefaultsClientImage(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{
+		"legacy": {Type: "mariadb", Host: "10.0.1.9", ClientImage: "mariadb:10.6"},
+	})
+
+	cmd := newDBBackupCmd()
+	if err := applyConnectionDefaults(cmd, "legacy"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got := cmd.Flags().Lookup("client-image").Value.String(); got != "mariadb:10.6" {
+		t.Errorf("Expected client image from profile, got %q", got)
+	}
+}
+
+func TestApplyConnectionDefaultsContainer(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{
+		"local-shop": {Type: "postgres", Database: "shop", Container: "shop-db-1"},
+	})
+
+	tests := []struct {
+		name      string
+		args      []string
+		env       map[string]string
+		container string
+		network   string
+	}{
+		{"Profile", nil, nil, "shop-db-1", ""},
+		{"Network flag", []string{"--docker-network", "netx"}, nil, "", "netx"},
+		{"Network environment", nil, map[string]string{"CUTTER_DOCKER_NETWORK": "shop_default"}, "", "shop_default"},
+		{"Container flag over environment", []string{"--container", "db"}, map[string]string{"CUTTER_DOCKER_NETWORK": "shop_default"}, "db", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cmd := newDBBackupCmd()
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatalf("Failed to parse flags: %v", err)
+			}
+			if err := applyConnectionDefaults(cmd, "local-shop"); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got := cmd.Flags().Lookup("container").Value.String(); got != tt.container {
+				t.Errorf("Expected container %q, got %q", tt.container, got)
+			}
+			if got := cmd.Flags().Lookup("docker-network").Value.String(); got != tt.network {
+				t.Errorf("Expected network %q, got %q", tt.network, got)
+			}
+		})
+	}
+}
+
+func TestApplyConnectionDefaultsPasswordSource(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{
+		"prod-orders": {Type: "postgres", PasswordFile: "/etc/cutter/orders.pw", PasswordEnv: "ORDERS_PW"},
+	})
+
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want map[string]string
+	}{
+		{"Profile", nil, nil,
+			map[string]string{"password-file": "/etc/cutter/orders.pw", "password-env": "ORDERS_PW"}},
+		{"Flag", []string{"--password-env", "MYPW"}, nil,
+			map[string]string{"password-file": "", "password-env": "MYPW"}},
+		{"Password flag", []string{"--password", "s3cr3t"}, nil,
+			map[string]string{"password-file": "", "password-env": ""}},
+		{"Environment", nil, map[string]string{"CUTTER_PASSWORD_ENV": "MYPW"},
+			map[string]string{"password-file": "", "password-env": "MYPW"}},
+		{"CUTTER_PASSWORD", nil, map[string]string{"CUTTER_PASSWORD": "s3cr3t"},
+			map[string]string{"password-file": "", "password-env": ""}},
+		{"Flag over environment", []string{"--password-env", "MYPW"}, map[string]string{"CUTTER_PASSWORD_FILE": "/tmp/pw"},
+			map[string]string{"password-file": "", "password-env": "MYPW"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			cmd := newDBBackupCmd()
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatalf("Failed to parse flags: %v", err)
+			}
+			if err := applyConnectionDefaults(cmd, "prod-orders"); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			for name, value := range tt.want {
+				if got := cmd.Flags().Lookup(name).Value.String(); got != value {
+					t.Errorf("Expected %s=%q, got %q", name, value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyConnectionDefaultsUnknownProfile(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{})
+
+	cmd := newDBBackupCmd()
+	err := applyConnectionDefaults(cmd, "missing")
+	if err == nil || !strings.Contains(err.Error(), `profile "missing" not found`) {
+		t.Errorf("Expected profile not found error, got %v", err)
+	}
+}
+
+func TestApplyConnectionDefaultsInvalidEnv(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{})
+	t.Setenv("CUTTER_PORT", "not-a-port")
+
+	cmd := newDBBackupCmd()
+	err := applyConnectionDefaults(cmd, "")
+	if err == nil || !strings.Contains(err.Error(), "CUTTER_PORT") {
+		t.Errorf("Expected invalid CUTTER_PORT error, got %v", err)
+	}
+}
+
+func TestRequireFlags(t *testing.T) {
+	cmd := newDBBackupCmd()
+	cmd.ParseFlags([]string{"--username", "user"})
+
+	err := requireFlags(cmd, "database", "username")
+	if err == nil || !strings.Contains(err.Error(), `"database"`) || strings.Contains(err.Error(), `"username"`) {
+		t.Errorf("Expected only database to be reported missing, got %v", err)
+	}
+
+	cmd.ParseFlags([]string{"--database", "db"})
+	if err := requireFlags(cmd, "database", "username"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// progressModes are the accepted --progress values
+var progressModes = []string{"auto", "json", "none"}
+
+// progressIntervals is how often each reporter mode prints; tests shorten it
+var progressIntervals = map[string]time.Duration{
+	"line": time.Second,
+	"json": 10 * time.Second,
+}
+
+// stdoutIsTerminal reports whether stdout is interactive; tests replace it
+var stdoutIsTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// addProgressFlag registers --progress
+func addProgressFlag(cmd *cobra.Command, mode *string) {
+	cmd.Flags().StringVar(mode, "progress", "auto", "Progress reporting: auto (a live line when stdout is a terminal), json (periodic events on stderr) or none")
+}
+
+// resolveProgressMode turns a --progress value into line, json or none
+func resolveProgressMode(mode string) (string, error) {
+	switch mode {
+	case "auto", "":
+		if stdoutIsTerminal() {
+			return "line", nil
+		}
+		return "none", nil
+	case "json", "none":
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid --progress %q (use one of %s)", mode, strings.Join(progressModes, ", "))
+}
+
+// progressEvent is one JSON progress record
+type progressEvent struct {
+	Event          string  `json:"event"`
+	Time           string  `json:"time"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	RawBytes       int64   `json:"raw_bytes"`
+	WrittenBytes   int64   `json:"written_bytes"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	TotalBytes     int64   `json:"total_bytes,omitempty"`
+	Percent        float64 `json:"percent,omitempty"`
+	ETASeconds     float64 `json:"eta_seconds,omitempty"`
+	Error          string  `json:"error,omitempty"`
+}
+
+// progressReporter periodically reports how far a running dump has got.
+// A nil reporter reports nothing, so callers need not check the mode.
+type progressReporter struct {
+	mode string
+	out  io.Writer
+	// total is the expected dump size, 0 when unknown
+	total int64
+	start time.Time
+
+	raw, written func() int64
+	stop         chan struct{}
+	done         sync.WaitGroup
+}
+
+// newProgressReporter returns a reporter for mode (line or json), or nil
+// for none. total is the estimated raw dump size used for the ETA.
+func newProgressReporter(mode string, total int64) *progressReporter {
+	switch mode {
+	case "line":
+		return &progressReporter{mode: mode, out: os.Stdout, total: total}
+	case "json":
+		return &progressReporter{mode: mode, out: os.Stderr, total: total}
+	}
+	return nil
+}
+
+// track starts reporting the byte counts returned by raw (what the dump
+// tool produced) and written (what reached the destination)
+func (p *progressReporter) track(raw, written func() int64) {
+	if p == nil {
+		return
+	}
+	p.raw, p.written = raw, written
+	p.start = time.Now()
+	p.stop = make(chan struct{})
+
+	p.done.Add(1)
+	go func() {
+		defer p.done.Done()
+		ticker := time.NewTicker(progressIntervals[p.mode])
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.report("progress", nil)
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// finish stops the reporter and prints the final state
+func (p *progressReporter) finish(err error) {
+	if p == nil || p.stop == nil {
+		return
+	}
+	close(p.stop)
+	p.done.Wait()
+
+	if err != nil {
+		p.report("failed", err)
+	} else {
+		p.report("done", nil)
+	}
+}
+
+// snapshot computes the current progress
+func (p *progressReporter) snapshot(event string, err error) progressEvent {
+	elapsed := time.Since(p.start)
+	e := progressEvent{
+		Event:          event,
+		Time:           time.Now().UTC().Format(time.RFC3339),
+		ElapsedSeconds: elapsed.Seconds(),
+		RawBytes:       p.raw(),
+		WrittenBytes:   p.written(),
+		TotalBytes:     p.total,
+	}
+	if elapsed > 0 {
+		e.BytesPerSecond = float64(e.RawBytes) / elapsed.Seconds()
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	// The size is an estimate, so stop short of 100% until the dump ends
+	if p.total > 0 && event == "progress" {
+		e.Percent = min(99, 100*float64(e.RawBytes)/float64(p.total))
+		if e.BytesPerSecond > 0 && e.RawBytes < p.total {
+			e.ETASeconds = float64(p.total-e.RawBytes) / e.BytesPerSecond
+		}
+	}
+	return e
+}
+
+func (p *progressReporter) report(event string, err error) {
+	e := p.snapshot(event, err)
+
+	if p.mode == "json" {
+		data, _ := json.Marshal(e)
+		fmt.Fprintln(p.out, string(data))
+		return
+	}
+
+	line := fmt.Sprintf("%s dumped, %s written, %s/s, %s elapsed",
+		formatBytes(e.RawBytes), formatBytes(e.WrittenBytes), formatBytes(int64(e.BytesPerSecond)), formatClock(e.ElapsedSeconds))
+	if e.Percent > 0 {
+		line += fmt.Sprintf(", ~%.0f%%", e.Percent)
+	}
+	if e.ETASeconds > 0 {
+		line += ", ETA " + formatClock(e.ETASeconds)
+	}
+	// \r and erase-line redraw the same terminal line
+	fmt.Fprintf(p.out, "\r\033[K%s", line)
+	if event != "progress" {
+		fmt.Fprintln(p.out)
+	}
+}
+
+// formatBytes renders n with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatClock renders seconds as h:mm:ss
+func formatClock(seconds float64) string {
+	s := int64(seconds)
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
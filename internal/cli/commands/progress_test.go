@@ -0,0 +1,183 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolveProgressMode(t *testing.T) {
+	original := stdoutIsTerminal
+	t.Cleanup(func() { stdoutIsTerminal = original })
+
+	tests := []struct {
+		mode     string
+		terminal bool
+		want     string
+	}{
+		{"auto", true, "line"},
+		{"auto", false, "none"},
+		{"json", false, "json"},
+		{"none", true, "none"},
+	}
+	for _, tt := range tests {
+		stdoutIsTerminal = func() bool { return tt.terminal }
+		got, err := resolveProgressMode(tt.mode)
+		if err != nil || got != tt.want {
+			t.Errorf("Expected %s for %s (terminal=%v), got %s (%v)", tt.want, tt.mode, tt.terminal, got, err)
+		}
+	}
+
+	if _, err := resolveProgressMode("fancy"); err == nil || !strings.Contains(err.Error(), `invalid --progress "fancy"`) {
+		t.Errorf("Expected invalid mode error, got %v", err)
+	}
+}
+
+// fastProgress makes reporters tick every few milliseconds
+func fastProgress(t *testing.T) {
+	t.Helper()
+	original := progressIntervals
+	progressIntervals = map[string]time.Duration{"line": 5 * time.Millisecond, "json": 5 * time.Millisecond}
+	t.Cleanup(func() { progressIntervals = original })
+}
+
+func readProgressEvents(t *testing.T, out string) []progressEvent {
+	t.Helper()
+
+	var events []progressEvent
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		var e progressEvent
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Expected one JSON event per line, got %q: %v", line, err)
+		}
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestProgressReporterJSON(t *testing.T) {
+	fastProgress(t)
+
+	var out bytes.Buffer
+	p := newProgressReporter("json", 1000)
+	p.out = &out
+
+	var raw atomic.Int64
+	raw.Store(250)
+	p.track(raw.Load, func() int64 { return raw.Load() / 2 })
+	time.Sleep(30 * time.Millisecond)
+	raw.Store(1000)
+	p.finish(nil)
+
+	events := readProgressEvents(t, out.String())
+	if len(events) < 2 {
+		t.Fatalf("Expected periodic events and a final one, got %+v", events)
+	}
+
+	first := events[0]
+	if first.Event != "progress" || first.RawBytes != 250 || first.WrittenBytes != 125 || first.TotalBytes != 1000 {
+		t.Errorf("Unexpected progress event %+v", first)
+	}
+	if first.Percent != 25 || first.ETASeconds <= 0 {
+		t.Errorf("Expected 25%% with an ETA, got %+v", first)
+	}
+
+	last := events[len(events)-1]
+	if last.Event != "done" || last.RawBytes != 1000 || last.ETASeconds != 0 {
+		t.Errorf("Unexpected final event %+v", last)
+	}
+}
+
+func TestProgressReporterFailure(t *testing.T) {
+	var out bytes.Buffer
+	p := newProgressReporter("json", 0)
+	p.out = &out
+
+	p.track(func() int64 { return 10 }, func() int64 { return 5 })
+	p.finish(errors.New("pg_dump failed"))
+
+	events := readProgressEvents(t, out.String())
+	last := events[len(events)-1]
+	if last.Event != "failed" || last.Error != "pg_dump failed" || last.Percent != 0 {
+		t.Errorf("Unexpected final event %+v", last)
+	}
+}
+
+func TestProgressReporterLine(t *testing.T) {
+	fastProgress(t)
+
+	var out bytes.Buffer
+	p := newProgressReporter("line", 4<<30)
+	p.out = &out
+
+	p.track(func() int64 { return 1 << 30 }, func() int64 { return 256 << 20 })
+	time.Sleep(20 * time.Millisecond)
+	p.finish(nil)
+
+	got := out.String()
+	for _, want := range []string{"\r\033[K", "1.0 GiB dumped", "256.0 MiB written", "~25%", "ETA "} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in progress output %q", want, got)
+		}
+	}
+	if !strings.HasSuffix(got, "\n") {
+		t.Error("Expected the final line to end with a newline")
+	}
+}
+
+func TestNilProgressReporter(t *testing.T) {
+	if newProgressReporter("none", 100) != nil {
+		t.Fatal("Expected no reporter for none")
+	}
+
+	var p *progressReporter
+	p.track(func() int64 { return 0 }, func() int64 { return 0 })
+	p.finish(nil)
+}
+
+func TestStreamDumpReportsProgress(t *testing.T) {
+	fakeDocker(t)
+
+	var out bytes.Buffer
+	p := newProgressReporter("json", 0)
+	p.out = &out
+
+	output := filepath.Join(t.TempDir(), "orders.sql")
+	if _, err := streamDump("pg_dump", commandDump(exec.Command(dockerBinary, "SELECT 1;")), localStore{dir: filepath.Dir(output)}, filepath.Base(output), compressionOptions{codec: "none"}, nil, p); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	events := readProgressEvents(t, out.String())
+	last := events[len(events)-1]
+	if last.Event != "done" || last.RawBytes != int64(len("SELECT 1;\n")) || last.WrittenBytes != last.RawBytes {
+		t.Errorf("Unexpected final event %+v", last)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:             "0 B",
+		1023:          "1023 B",
+		1536:          "1.5 KiB",
+		5 << 20:       "5.0 MiB",
+		3 << 40:       "3.0 TiB",
+		1<<30 + 1<<29: "1.5 GiB",
+	}
+	for n, want := range tests {
+		if got := formatBytes(n); got != want {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
+
+func TestFormatClock(t *testing.T) {
+	if got := formatClock(3725.9); got != "1:02:05" {
+		t.Errorf("Expected 1:02:05, got %s", got)
+	}
+}
@@ -0,0 +1,409 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// backupNamePattern matches the names runDBBackup generates:
+// <database>_<YYYYMMDD_HHMMSS>.<sql|dump|dir|tar|archive|rdb>[...]
+var backupNamePattern = regexp.MustCompile(`^(.+)_(\d{8}_\d{6})\.(sqlite|sql|dump|dir|tar|archive|rdb)`)
+
+// retentionPolicy decides which backups of one database survive a prune.
+// The keep rules are combined: a backup kept by any of them stays. Without
+// keep rules every backup is kept, subject to maxAge and maxSize. The
+// newest backup is never removed.
+type retentionPolicy struct {
+	keepLast    int
+	keepDaily   int
+	keepWeekly  int
+	keepMonthly int
+	maxAge      time.Duration
+	maxSize     int64
+}
+
+func (p retentionPolicy) hasKeepRules() bool {
+	return p.keepLast > 0 || p.keepDaily > 0 || p.keepWeekly > 0 || p.keepMonthly > 0
+}
+
+func (p retentionPolicy) empty() bool {
+	return !p.hasKeepRules() && p.maxAge == 0 && p.maxSize == 0
+}
+
+// datedBackup is a stored backup whose name carries its database and time
+type datedBackup struct {
+	storedBackup
+	Database string
+	Time     time.Time
+}
+
+// pruneDecision says what happens to one backup and why
+type pruneDecision struct {
+	datedBackup
+	Delete bool
+	Reason string
+}
+
+// parseBackupName extracts the database and timestamp from a generated name
+func parseBackupName(name string) (string, time.Time, bool) {
+	m := backupNamePattern.FindStringSubmatch(name)
+	if m == nil || strings.HasSuffix(name, manifestSuffix) {
+		return "", time.Time{}, false
+	}
+	t, err := time.ParseInLocation("20060102_150405", m[2], time.Local)
+	if err != nil {
+		return "", time.Time{}, false
+	}
+	return m[1], t, true
+}
+
+// groupBackups sorts backups by database, newest first. Files whose name
+// does not follow the generated pattern are left out and never pruned.
+func groupBackups(files []storedBackup) map[string][]datedBackup {
+	groups := map[string][]datedBackup{}
+	for _, file := range files {
+		database, t, ok := parseBackupName(file.Name)
+		if !ok {
+			continue
+		}
+		groups[database] = append(groups[database], datedBackup{storedBackup: file, Database: database, Time: t})
+	}
+
+	for _, backups := range groups {
+		sort.Slice(backups, func(i, j int) bool { return backups[i].Time.After(backups[j].Time) })
+	}
+	return groups
+}
+
+// planPrune applies policy to the backups of one database, newest first
+func planPrune(backups []datedBackup, policy retentionPolicy, now time.Time) []pruneDecision {
+	decisions := make([]pruneDecision, len(backups))
+	for i, b := range backups {
+		decisions[i] = pruneDecision{datedBackup: b, Reason: "newest"}
+	}
+	if len(backups) == 0 {
+		return decisions
+	}
+
+	if policy.hasKeepRules() {
+		reasons := make([]string, len(backups))
+		keepBuckets(backups, reasons, policy.keepLast, "last", func(t time.Time) string { return "" })
+		keepBuckets(backups, reasons, policy.keepDaily, "daily", func(t time.Time) string { return t.Format("2006-01-02") })
+		keepBuckets(backups, reasons, policy.keepWeekly, "weekly", func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		})
+		keepBuckets(backups, reasons, policy.keepMonthly, "monthly", func(t time.Time) string { return t.Format("2006-01") })
+
+		for i := 1; i < len(decisions); i++ {
+			if reasons[i] == "" {
+				decisions[i].Delete, decisions[i].Reason = true, "outside retention"
+			} else {
+				decisions[i].Reason = reasons[i]
+			}
+		}
+	} else {
+		for i := 1; i < len(decisions); i++ {
+			decisions[i].Reason = "no keep rule"
+		}
+	}
+
+	if policy.maxAge > 0 {
+		for i := 1; i < len(decisions); i++ {
+			if !decisions[i].Delete && now.Sub(decisions[i].Time) > policy.maxAge {
+				decisions[i].Delete, decisions[i].Reason = true, "older than "+formatAge(policy.maxAge)
+			}
+		}
+	}
+
+	if policy.maxSize > 0 {
+		var total int64
+		for _, d := range decisions {
+			if !d.Delete {
+				total += d.Size
+			}
+		}
+		for i := len(decisions) - 1; i > 0 && total > policy.maxSize; i-- {
+			if !decisions[i].Delete {
+				decisions[i].Delete, decisions[i].Reason = true, "over size limit"
+				total -= decisions[i].Size
+			}
+		}
+	}
+
+	return decisions
+}
+
+// keepBuckets keeps the newest backup of each of the first count distinct
+// buckets, labelling it with rule; a constant bucket keeps the newest count
+func keepBuckets(backups []datedBackup, reasons []string, count int, rule string, bucket func(time.Time) string) {
+	if count <= 0 {
+		return
+	}
+
+	seen := map[string]bool{}
+	kept := 0
+	for i, b := range backups {
+		if kept == count {
+			return
+		}
+
+		key := bucket(b.Time)
+		if key != "" && seen[key] {
+			continue
+		}
+		seen[key] = true
+		kept++
+
+		if reasons[i] == "" {
+			reasons[i] = rule
+		} else {
+			reasons[i] += ", " + rule
+		}
+	}
+}
+
+// parseAge accepts Go durations plus d (days) and w (weeks) suffixes
+func parseAge(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(value, suffix); ok {
+			days, err := strconv.Atoi(n)
+			if err != nil || days < 0 {
+				return 0, fmt.Errorf("invalid age %q", value)
+			}
+			return time.Duration(days) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q (use e.g. 30d, 12w or 72h)", value)
+	}
+	return d, nil
+}
+
+// formatAge prints an age in whole days when possible
+func formatAge(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+// parseSize accepts byte counts with optional KB/MB/GB/TB or KiB/MiB/GiB/TiB
+// suffixes
+func parseSize(value string) (int64, error) {
+	units := []struct {
+		suffix string
+		factor int64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+		{"B", 1},
+	}
+
+	number, factor := strings.TrimSpace(value), int64(1)
+	for _, u := range units {
+		if n, ok := strings.CutSuffix(strings.ToUpper(number), strings.ToUpper(u.suffix)); ok {
+			number, factor = strings.TrimSpace(n), u.factor
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q (use e.g. 500MB or 10GiB)", value)
+	}
+	return int64(n * float64(factor)), nil
+}
+
+func newDBPruneCmd() *cobra.Command {
+	var (
+		policy   retentionPolicy
+		maxAge   string
+		maxSize  string
+		database string
+		dryRun   bool
+		yes      bool
+		storage  storageOptions
+		profile  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "prune [location]",
+		Short: "Delete old backups according to a retention policy",
+		Args:  cobra.MaximumNArgs(1),
+		Example: `  # Preview a grandfather-father-son policy in the current directory
+  cutter db prune --keep-daily 7 --keep-weekly 4 --keep-monthly 12 --dry-run
+
+  # Keep the last 10 backups of one database, never more than 50GB
+  cutter db prune ~/backups --database orders --keep-last 10 --max-size 50GB --yes
+
+  # Apply the same policy to an object storage prefix
+  cutter db prune s3://backups/orders/ --keep-daily 14 --max-age 90d`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filterSource := databaseSource(cmd, profile)
+			if err := applyConnectionDefaults(cmd, profile); err != nil {
+				return err
+			}
+
+			var err error
+			if maxAge != "" {
+				if policy.maxAge, err = parseAge(maxAge); err != nil {
+					return err
+				}
+			}
+			if maxSize != "" {
+				if policy.maxSize, err = parseSize(maxSize); err != nil {
+					return err
+				}
+			}
+			if policy.empty() {
+				return fmt.Errorf("no retention rule given (use --keep-last, --keep-daily, --keep-weekly, --keep-monthly, --max-age or --max-size)")
+			}
+
+			location, err := defaultLocation(profile)
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				location = args[0]
+			}
+			if !isObjectStorage(location) && !strings.HasSuffix(location, "/") {
+				location += string(os.PathSeparator)
+			}
+			store, _, err := openStore(location, storage)
+			if err != nil {
+				return err
+			}
+
+			if database != "" {
+				fmt.Printf("Only pruning backups of database %s (from %s)\n\n", database, filterSource)
+			}
+			return runDBPrune(store, policy, database, dryRun, yes)
+		},
+	}
+
+	cmd.Flags().IntVar(&policy.keepLast, "keep-last", 0, "Keep the N most recent backups")
+	cmd.Flags().IntVar(&policy.keepDaily, "keep-daily", 0, "Keep the newest backup of each of the last N days")
+	cmd.Flags().IntVar(&policy.keepWeekly, "keep-weekly", 0, "Keep the newest backup of each of the last N weeks")
+	cmd.Flags().IntVar(&policy.keepMonthly, "keep-monthly", 0, "Keep the newest backup of each of the last N months")
+	cmd.Flags().StringVar(&maxAge, "max-age", "", "Delete backups older than this (e.g. 30d, 12w, 72h)")
+	cmd.Flags().StringVar(&maxSize, "max-size", "", "Delete the oldest backups until each database uses at most this much (e.g. 50GB)")
+	cmd.Flags().StringVar(&database, "database", "", "Only prune backups of this database")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Print what would be deleted without deleting anything")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip the confirmation prompt")
+	addStorageFlags(cmd, &storage)
+	addProfileFlag(cmd, &profile)
+
+	return cmd
+}
+
+// databaseSource names where --database will come from once
+// applyConnectionDefaults has run, so that prune can show why it only looks
+// at one database
+func databaseSource(cmd *cobra.Command, profile string) string {
+	if cmd.Flags().Changed("database") {
+		return "--database"
+	}
+	if os.Getenv(envVarForFlag("database")) != "" {
+		return envVarForFlag("database")
+	}
+	if profile == "" {
+		profile = os.Getenv("CUTTER_PROFILE")
+	}
+	return "profile " + profile
+}
+
+// runDBPrune plans the deletions for every database in store and carries
+// them out unless dryRun is set
+func runDBPrune(store backupStore, policy retentionPolicy, database string, dryRun, yes bool) error {
+	files, err := store.List()
+	if err != nil {
+		return err
+	}
+	groups := groupBackups(files)
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		if database == "" || name == database {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) == 0 && database != "" {
+		fmt.Printf("No backups of %s found in %s\n", database, store.Location(""))
+		return nil
+	}
+	if len(names) == 0 {
+		fmt.Printf("No backups found in %s\n", store.Location(""))
+		return nil
+	}
+
+	now := time.Now()
+	var doomed []pruneDecision
+	for _, name := range names {
+		decisions := planPrune(groups[name], policy, now)
+
+		deleting := 0
+		for _, d := range decisions {
+			if d.Delete {
+				deleting++
+			}
+		}
+		fmt.Printf("%s: keeping %d, deleting %d\n", name, len(decisions)-deleting, deleting)
+
+		for _, d := range decisions {
+			action := "keep  "
+			if d.Delete {
+				action = "delete"
+				doomed = append(doomed, d)
+			}
+			fmt.Printf("  %s %s (%s)\n", action, d.Name, d.Reason)
+		}
+	}
+
+	if len(doomed) == 0 {
+		fmt.Println("\nNothing to prune")
+		return nil
+	}
+	if dryRun {
+		fmt.Printf("\nDry run: %d backup(s) would be deleted\n", len(doomed))
+		return nil
+	}
+
+	ok, err := confirm(os.Stdin, yes, fmt.Sprintf("Delete %d backup(s) from %s?", len(doomed), store.Location("")))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("prune aborted by user")
+	}
+
+	present := map[string]bool{}
+	for _, file := range files {
+		present[file.Name] = true
+	}
+
+	for _, d := range doomed {
+		if err := store.Remove(d.Name); err != nil {
+			return fmt.Errorf("failed to delete %s: %v", store.Location(d.Name), err)
+		}
+		if present[manifestPath(d.Name)] {
+			if err := store.Remove(manifestPath(d.Name)); err != nil {
+				return fmt.Errorf("failed to delete %s: %v", store.Location(manifestPath(d.Name)), err)
+			}
+		}
+	}
+
+	fmt.Printf("\n✓ Deleted %d backup(s)\n", len(doomed))
+	return nil
+}
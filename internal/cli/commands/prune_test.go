@@ -0,0 +1,254 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PandhuWibowo/go-devops-cutter/internal/config"
+)
+
+// dailyBackups returns one backup per day for count days before now,
+// newest first, each size bytes
+func dailyBackups(now time.Time, count int, size int64) []datedBackup {
+	backups := make([]datedBackup, count)
+	for i := range backups {
+		t := now.AddDate(0, 0, -i)
+		backups[i] = datedBackup{
+			storedBackup: storedBackup{Name: fmt.Sprintf("orders_%s.sql.gz", t.Format("20060102_150405")), Size: size},
+			Database:     "orders",
+			Time:         t,
+		}
+	}
+	return backups
+}
+
+// kept returns the names planPrune keeps
+func kept(decisions []pruneDecision) []string {
+	var names []string
+	for _, d := range decisions {
+		if !d.Delete {
+			names = append(names, d.Time.Format("0102"))
+		}
+	}
+	return names
+}
+
+func TestPlanPrune(t *testing.T) {
+	// Sunday 31 March 2024
+	now := time.Date(2024, 3, 31, 2, 0, 0, 0, time.Local)
+	backups := dailyBackups(now, 100, 100)
+
+	tests := []struct {
+		name   string
+		policy retentionPolicy
+		want   string
+	}{
+		{"Keep last", retentionPolicy{keepLast: 3}, "0331 0330 0329"},
+		{"Keep daily", retentionPolicy{keepDaily: 2}, "0331 0330"},
+		{"Keep weekly", retentionPolicy{keepWeekly: 3}, "0331 0324 0317"},
+		{"Keep monthly", retentionPolicy{keepMonthly: 3}, "0331 0229 0131"},
+		{"Combined", retentionPolicy{keepDaily: 2, keepWeekly: 2, keepMonthly: 2}, "0331 0330 0324 0229"},
+		{"Max age only", retentionPolicy{maxAge: 3 * 24 * time.Hour}, "0331 0330 0329 0328"},
+		{"Max size only", retentionPolicy{maxSize: 250}, "0331 0330"},
+		{"Max age overrides keep", retentionPolicy{keepMonthly: 3, maxAge: 7 * 24 * time.Hour}, "0331"},
+		{"Newest always kept", retentionPolicy{maxSize: 1}, "0331"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := strings.Join(kept(planPrune(backups, tt.policy, now)), " ")
+			if got != tt.want {
+				t.Errorf("Expected to keep %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPlanPruneReasons(t *testing.T) {
+	now := time.Date(2024, 3, 31, 2, 0, 0, 0, time.Local)
+	decisions := planPrune(dailyBackups(now, 3, 100), retentionPolicy{keepLast: 2}, now)
+
+	want := []string{"newest", "last", "outside retention"}
+	for i, d := range decisions {
+		if d.Reason != want[i] {
+			t.Errorf("Expected reason %q for %s, got %q", want[i], d.Name, d.Reason)
+		}
+	}
+}
+
+func TestParseBackupName(t *testing.T) {
+	tests := []struct {
+		name     string
+		database string
+		ok       bool
+	}{
+		{"orders_20240101_120000.sql", "orders", true},
+		{"my_app_db_20240101_120000.sql.gz.age", "my_app_db", true},
+		{"orders_20240101_120000.sql.gz" + manifestSuffix, "", false},
+		{"orders.sql", "", false},
+		{"orders_20241301_120000.sql", "", false},
+	}
+
+	for _, tt := range tests {
+		database, ts, ok := parseBackupName(tt.name)
+		if ok != tt.ok || database != tt.database {
+			t.Errorf("parseBackupName(%q) = %q, %v; want %q, %v", tt.name, database, ok, tt.database, tt.ok)
+		}
+		if ok && ts.Format("20060102_150405") != "20240101_120000" {
+			t.Errorf("parseBackupName(%q) time = %v", tt.name, ts)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"2w", 14 * 24 * time.Hour, false},
+		{"72h", 72 * time.Hour, false},
+		{"xd", 0, true},
+		{"-1h", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAge(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseAge(%q) = %v, %v; want %v, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{"1024", 1024, false},
+		{"500MB", 500e6, false},
+		{"10GiB", 10 << 30, false},
+		{"1.5gb", 1.5e9, false},
+		{"12 KiB", 12 << 10, false},
+		{"lots", 0, true},
+		{"-5MB", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSize(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseSize(%q) = %d, %v; want %d, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRunDBPrune(t *testing.T) {
+	store := localStore{dir: t.TempDir()}
+	now := time.Now()
+
+	var names []string
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("orders_%s.sql.gz", now.AddDate(0, 0, -i).Format("20060102_150405"))
+		names = append(names, name)
+		os.WriteFile(filepath.Join(store.dir, name), []byte("dump"), 0600)
+		os.WriteFile(filepath.Join(store.dir, manifestPath(name)), []byte("{}"), 0600)
+	}
+	os.WriteFile(filepath.Join(store.dir, "users_20200101_000000.sql"), []byte("dump"), 0600)
+	os.WriteFile(filepath.Join(store.dir, "handmade.sql"), []byte("dump"), 0600)
+
+	policy := retentionPolicy{keepLast: 2}
+
+	if err := runDBPrune(store, policy, "orders", true, false); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if files, _ := store.List(); len(files) != 10 {
+		t.Errorf("Expected dry run to delete nothing, got %d files", len(files))
+	}
+
+	if err := runDBPrune(store, policy, "orders", false, true); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	var remaining []string
+	files, _ := store.List()
+	for _, f := range files {
+		remaining = append(remaining, f.Name)
+	}
+	want := []string{"handmade.sql", names[1], manifestPath(names[1]), names[0], manifestPath(names[0]), "users_20200101_000000.sql"}
+	if strings.Join(remaining, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected %v to remain, got %v", want, remaining)
+	}
+}
+
+func TestDBPruneCmdRequiresRule(t *testing.T) {
+	writeTestConfig(t, nil)
+
+	cmd := newDBPruneCmd()
+	cmd.SetArgs([]string{t.TempDir()})
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "no retention rule") {
+		t.Errorf("Expected missing rule error, got %v", err)
+	}
+}
+
+func TestDatabaseSource(t *testing.T) {
+	writeTestConfig(t, map[string]config.Profile{"prod-orders": {Database: "orders"}})
+
+	tests := []struct {
+		args     []string
+		env      string
+		profile  string
+		expected string
+	}{
+		{[]string{"--database", "orders"}, "users", "prod-orders", "--database"},
+		{nil, "users", "prod-orders", "CUTTER_DATABASE"},
+		{nil, "", "prod-orders", "profile prod-orders"},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("CUTTER_DATABASE", tt.env)
+		cmd := newDBPruneCmd()
+		if err := cmd.ParseFlags(tt.args); err != nil {
+			t.Fatalf("Failed to parse flags: %v", err)
+		}
+		if got := databaseSource(cmd, tt.profile); got != tt.expected {
+			t.Errorf("Expected %q for %v with CUTTER_DATABASE %q, got %q", tt.expected, tt.args, tt.env, got)
+		}
+	}
+}
+
+func TestRunDBPruneObjectStorage(t *testing.T) {
+	opts := fakeS3(t, "backups")
+	store, _, err := openStore("s3://backups/orders/", opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		w, err := store.Create(fmt.Sprintf("orders_%s.sql.gz", now.AddDate(0, 0, -i).Format("20060102_150405")))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		w.Write([]byte("dump"))
+		if err := w.Commit(); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	if err := runDBPrune(store, retentionPolicy{keepLast: 1}, "", false, true); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	files, _ := store.List()
+	if len(files) != 1 || !strings.Contains(files[0].Name, now.Format("20060102")) {
+		t.Errorf("Expected only the newest object to remain, got %+v", files)
+	}
+}
@@ -0,0 +1,280 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// runtimes are the --runtime values. auto runs locally installed client
+// tools when they fit the server and the first container CLI found
+// otherwise.
+var runtimes = []string{"auto", "local", "docker", "podman", "nerdctl"}
+
+// containerCLIs are the container runtimes in the order auto tries them
+var containerCLIs = []string{"docker", "podman", "nerdctl"}
+
+// hostOS is the operating system cutter runs on
+var hostOS = runtime.GOOS
+
+// rootlessHostLoopback is this machine's loopback as seen from RootlessKit's
+// network namespace, which rootless Docker and nerdctl use as the host
+// network
+const rootlessHostLoopback = "10.0.2.2"
+
+// addRuntimeFlag registers --runtime
+func addRuntimeFlag(cmd *cobra.Command, runtime *string) {
+	cmd.Flags().StringVar(runtime, "runtime", "auto",
+		"Where client tools run: auto, local, docker, podman or nerdctl (env: CUTTER_RUNTIME)")
+}
+
+// checkRuntime rejects unknown --runtime values
+func checkRuntime(runtime string) error {
+	if !slices.Contains(runtimes, runtime) {
+		return fmt.Errorf("unsupported runtime: %s (use one of %s)", runtime, strings.Join(runtimes, ", "))
+	}
+	return nil
+}
+
+// pullPolicies are the --pull-policy values
+var pullPolicies = []string{"always", "missing", "never"}
+
+// containerSettings are the --docker-network, --pull-policy, --memory and
+// --cpus values
+type containerSettings struct {
+	network    string
+	pullPolicy string
+	memory     string
+	cpus       float64
+}
+
+// addContainerFlags registers the client container flags
+func addContainerFlags(cmd *cobra.Command, s *containerSettings) {
+	cmd.Flags().StringVar(&s.network, "docker-network", "",
+		"Attach the client container to this network, such as a docker-compose one, and reach --host by its name there (env: CUTTER_DOCKER_NETWORK)")
+	cmd.Flags().StringVar(&s.pullPolicy, "pull-policy", "missing",
+		"When to pull the client image: always, missing or never (env: CUTTER_PULL_POLICY)")
+	cmd.Flags().StringVar(&s.memory, "memory", "",
+		"Memory limit of the client container, such as 512m or 2g (env: CUTTER_MEMORY)")
+	cmd.Flags().Float64Var(&s.cpus, "cpus", 0,
+		"CPU limit of the client container, such as 1.5 (env: CUTTER_CPUS)")
+}
+
+// check rejects unknown pull policies and malformed limits
+func (s containerSettings) check() error {
+	if !slices.Contains(pullPolicies, s.pullPolicy) {
+		return fmt.Errorf("unsupported pull policy: %s (use one of %s)", s.pullPolicy, strings.Join(pullPolicies, ", "))
+	}
+	if _, err := parseMemory(s.memory); err != nil {
+		return err
+	}
+	if s.cpus < 0 {
+		return fmt.Errorf("invalid --cpus %v: must not be negative", s.cpus)
+	}
+	return nil
+}
+
+// parseMemory turns a --memory value, a number of bytes with an optional
+// b, k, m or g suffix in binary units, into bytes; empty is 0
+func parseMemory(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	number, unit := strings.ToLower(value), int64(1)
+	if i := strings.IndexAny(number, "bkmg"); i >= 0 && i == len(number)-1 {
+		unit = map[byte]int64{'b': 1, 'k': 1 << 10, 'm': 1 << 20, 'g': 1 << 30}[number[i]]
+		number = number[:i]
+	}
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n <= 0 || n > math.MaxInt64/unit {
+		return 0, fmt.Errorf("invalid --memory %q (use bytes or a size such as 512m or 2g)", value)
+	}
+	return n * unit, nil
+}
+
+// containerRuntime is a container engine and how its containers reach the
+// network of this machine
+type containerRuntime struct {
+	// name is docker, podman or nerdctl
+	name   string
+	binary string
+	// engine, when set, drives the engine through its API instead of binary
+	engine *engineAPI
+	// rootless is set when the engine runs without root privileges
+	rootless bool
+	// vm is set when containers run in a virtual machine, as with Docker
+	// Desktop or podman machine, whose host network is not this machine's
+	vm bool
+}
+
+// detectContainerRuntime finds the container runtime for a --runtime
+// value; auto takes the first one installed. Docker is used through its
+// Engine API when the socket answers and through the CLI otherwise. The
+// engine is asked whether it is rootless or runs in a virtual machine,
+// which decides its networking.
+func detectContainerRuntime(name string) (containerRuntime, error) {
+	candidates := containerCLIs
+	if name != "auto" {
+		candidates = []string{name}
+	}
+
+	for _, candidate := range candidates {
+		if candidate == "docker" {
+			if api, ok := openEngineAPI(); ok {
+				return inspectEngineAPI(api)
+			}
+		}
+		binary := candidate
+		if candidate == "docker" {
+			binary = dockerBinary
+		}
+		if _, err := exec.LookPath(binary); err != nil {
+			continue
+		}
+		return inspectContainerRuntime(candidate, binary)
+	}
+
+	if name != "auto" {
+		binary := name
+		if name == "docker" {
+			binary = dockerBinary
+		}
+		return containerRuntime{}, fmt.Errorf("%s is not installed", binary)
+	}
+	return containerRuntime{}, fmt.Errorf("no container runtime is installed (%s)", strings.Join(containerCLIs, ", "))
+}
+
+// inspectContainerRuntime asks the engine behind binary how it runs
+func inspectContainerRuntime(name, binary string) (containerRuntime, error) {
+	r := containerRuntime{name: name, binary: binary, vm: hostOS != "linux"}
+
+	// podman-docker installs a docker command that runs podman
+	if name == "docker" {
+		version, err := exec.Command(binary, "--version").Output()
+		if err != nil {
+			return r, fmt.Errorf("%s --version failed: %v", binary, err)
+		}
+		if strings.HasPrefix(strings.ToLower(string(version)), "podman") {
+			r.name = "podman"
+		}
+	}
+
+	format := "{{.OperatingSystem}}|{{json .SecurityOptions}}"
+	if r.name == "podman" {
+		format = "{{.Host.Security.Rootless}}|{{.Host.ServiceIsRemote}}"
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(binary, "info", "--format", format)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return r, fmt.Errorf("%s is installed but its engine is not reachable: %v: %s", binary, err, strings.TrimSpace(stderr.String()))
+	}
+
+	first, second, _ := strings.Cut(strings.TrimSpace(string(out)), "|")
+	if r.name == "podman" {
+		// A remote service is podman machine or another host
+		r.rootless = first == "true"
+		r.vm = r.vm || second == "true"
+	} else {
+		r.rootless = strings.Contains(second, "name=rootless")
+		r.vm = r.vm || first == "Docker Desktop"
+	}
+	return r, nil
+}
+
+// inspectEngineAPI asks the engine behind api how it runs. Podman's
+// Docker-compatible service names itself in the version components.
+func inspectEngineAPI(api *engineAPI) (containerRuntime, error) {
+	r := containerRuntime{name: "docker", binary: dockerBinary, engine: api, vm: hostOS != "linux"}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var version struct {
+		Components []struct{ Name string }
+	}
+	if err := api.call(ctx, http.MethodGet, "/version", nil, nil, &version); err != nil {
+		return r, fmt.Errorf("Docker Engine API request failed: %v", err)
+	}
+	for _, component := range version.Components {
+		if strings.HasPrefix(component.Name, "Podman") {
+			r.name = "podman"
+		}
+	}
+
+	var info struct {
+		OperatingSystem string
+		SecurityOptions []string
+	}
+	if err := api.call(ctx, http.MethodGet, "/info", nil, nil, &info); err != nil {
+		return r, fmt.Errorf("Docker Engine API request failed: %v", err)
+	}
+	r.rootless = slices.ContainsFunc(info.SecurityOptions, func(option string) bool {
+		return strings.Contains(option, "name=rootless")
+	})
+	r.vm = r.vm || info.OperatingSystem == "Docker Desktop"
+	return r, nil
+}
+
+// displayName describes the runtime in progress messages
+func (r containerRuntime) displayName() string {
+	name := map[string]string{"docker": "Docker", "podman": "Podman", "nerdctl": "nerdctl"}[r.name]
+	if r.rootless {
+		return "rootless " + name
+	}
+	return name
+}
+
+// network returns the container setup that lets a client reach the
+// database at p, and p as addressed from inside the container. A
+// tunneled p is the SSH tunnel on this machine's loopback.
+func (r containerRuntime) network(p ConnParams) (containerSpec, ConnParams) {
+	onLoopback := p.Tunneled || isLoopbackHost(p.Host)
+
+	switch {
+	case r.vm:
+		// The VM's host network is not this machine's, but the runtime
+		// forwards its host alias to this machine's loopback
+		if r.name == "podman" {
+			if onLoopback {
+				p.Host = "host.containers.internal"
+			}
+			return containerSpec{}, p
+		}
+		if onLoopback {
+			p.Host = "host.docker.internal"
+		}
+		return containerSpec{addHosts: []string{"host.docker.internal:host-gateway"}}, p
+	case r.rootless && r.name != "podman":
+		// The host network of rootless Docker and nerdctl is RootlessKit's
+		// namespace, which reaches this machine's loopback at a fixed
+		// address when RootlessKit's host loopback is enabled
+		if onLoopback {
+			p.Host = rootlessHostLoopback
+		}
+		return containerSpec{network: "host"}, p
+	default:
+		// Rootful engines and rootless Podman share this machine's
+		// network, loopback included
+		return containerSpec{network: "host"}, p
+	}
+}
+
+// isLoopbackHost reports whether host names this machine's loopback
+func isLoopbackHost(host string) bool {
+	switch strings.Trim(strings.ToLower(host), "[]") {
+	case "localhost", "127.0.0.1", "::1":
+		return true
+	}
+	return false
+}
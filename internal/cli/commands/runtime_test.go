@@ -0,0 +1,196 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+// fakeContainerCLI installs a container CLI named name that prints
+// version for --version and info for info
+func fakeContainerCLI(t *testing.T, name, version, info string) {
+	noEngineAPI(t)
+	fakeTools(t, map[string]string{
+		name: `case "$1" in --version) echo '` + version + `' ;; info) echo '` + info + `' ;; esac`,
+	})
+}
+
+func TestCheckRuntime(t *testing.T) {
+	for _, runtime := range runtimes {
+		if err := checkRuntime(runtime); err != nil {
+			t.Errorf("Expected %s to be accepted, got %v", runtime, err)
+		}
+	}
+	if err := checkRuntime("lxc"); err == nil || !strings.Contains(err.Error(), "unsupported runtime: lxc") {
+		t.Errorf("Expected unsupported runtime error, got %v", err)
+	}
+}
+
+func TestParseMemory(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+		ok    bool
+	}{
+		{"", 0, true},
+		{"1048576", 1 << 20, true},
+		{"512m", 512 << 20, true},
+		{"2G", 2 << 30, true},
+		{"64k", 64 << 10, true},
+		{"100b", 100, true},
+		{"1.5g", 0, false},
+		{"0", 0, false},
+		{"-1m", 0, false},
+		{"lots", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, err := parseMemory(tt.value)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parseMemory(%q) = %d, %v, want %d, ok %v", tt.value, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestContainerSettingsCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings containerSettings
+		err      string
+	}{
+		{"Defaults", containerSettings{pullPolicy: "missing"}, ""},
+		{"Limits", containerSettings{pullPolicy: "never", memory: "1g", cpus: 0.5}, ""},
+		{"Unknown policy", containerSettings{pullPolicy: "sometimes"}, "unsupported pull policy: sometimes"},
+		{"Bad memory", containerSettings{pullPolicy: "always", memory: "huge"}, `invalid --memory "huge"`},
+		{"Negative cpus", containerSettings{pullPolicy: "always", cpus: -1}, "invalid --cpus -1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.settings.check()
+			if tt.err == "" && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestDetectContainerRuntime(t *testing.T) {
+	tests := []struct {
+		name    string
+		cli     string
+		version string
+		info    string
+		want    containerRuntime
+	}{
+		{"Docker", "docker", "Docker version 27.0.3, build 7d4bcd8", `Ubuntu 24.04 LTS|["name=apparmor","name=seccomp,profile=builtin"]`,
+			containerRuntime{name: "docker", binary: "docker"}},
+		{"Rootless Docker", "docker", "Docker version 27.0.3, build 7d4bcd8", `Ubuntu 24.04 LTS|["name=seccomp,profile=builtin","name=rootless"]`,
+			containerRuntime{name: "docker", binary: "docker", rootless: true}},
+		{"Docker Desktop", "docker", "Docker version 27.0.3, build 7d4bcd8", `Docker Desktop|["name=seccomp,profile=unconfined"]`,
+			containerRuntime{name: "docker", binary: "docker", vm: true}},
+		{"Podman behind docker", "docker", "podman version 5.0.2", "true|false",
+			containerRuntime{name: "podman", binary: "docker", rootless: true}},
+		{"Podman", "podman", "podman version 5.0.2", "true|false",
+			containerRuntime{name: "podman", binary: "podman", rootless: true}},
+		{"Podman machine", "podman", "podman version 5.0.2", "false|true",
+			containerRuntime{name: "podman", binary: "podman", vm: true}},
+		{"nerdctl", "nerdctl", "nerdctl version 1.7.6", `Ubuntu 24.04 LTS|["name=seccomp,profile=default","name=rootless"]`,
+			containerRuntime{name: "nerdctl", binary: "nerdctl", rootless: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PATH", t.TempDir())
+			original := hostOS
+			hostOS = "linux"
+			t.Cleanup(func() { hostOS = original })
+			fakeContainerCLI(t, tt.cli, tt.version, tt.info)
+
+			got, err := detectContainerRuntime("auto")
+			if err != nil {
+				t.Fatalf("Expected a runtime, got error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDetectContainerRuntimeOnMac(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	original := hostOS
+	hostOS = "darwin"
+	t.Cleanup(func() { hostOS = original })
+	fakeContainerCLI(t, "nerdctl", "nerdctl version 1.7.6", `Ubuntu 24.04 LTS|[]`)
+
+	got, err := detectContainerRuntime("nerdctl")
+	if err != nil || !got.vm {
+		t.Errorf("Expected a VM runtime, got %+v (%v)", got, err)
+	}
+}
+
+func TestDetectContainerRuntimeErrors(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	noEngineAPI(t)
+
+	if _, err := detectContainerRuntime("auto"); err == nil || err.Error() != "no container runtime is installed (docker, podman, nerdctl)" {
+		t.Errorf("Expected no runtime error, got %v", err)
+	}
+	if _, err := detectContainerRuntime("podman"); err == nil || err.Error() != "podman is not installed" {
+		t.Errorf("Expected podman to be missing, got %v", err)
+	}
+
+	fakeTools(t, map[string]string{"podman": `echo "Cannot connect to Podman" >&2; exit 125`})
+	if _, err := detectContainerRuntime("podman"); err == nil || !strings.Contains(err.Error(), "not reachable") || !strings.Contains(err.Error(), "Cannot connect to Podman") {
+		t.Errorf("Expected an unreachable engine error, got %v", err)
+	}
+}
+
+func TestContainerRuntimeNetwork(t *testing.T) {
+	remote := ConnParams{Host: "db.internal", Port: 5432}
+	local := ConnParams{Host: "localhost", Port: 5432}
+	tunneled := ConnParams{Host: "127.0.0.1", Port: 40000, Tunneled: true}
+
+	tests := []struct {
+		name    string
+		runtime containerRuntime
+		p       ConnParams
+		opts    string
+		host    string
+	}{
+		{"Docker remote", containerRuntime{name: "docker"}, remote, "--network host", "db.internal"},
+		{"Docker tunnel", containerRuntime{name: "docker"}, tunneled, "--network host", "127.0.0.1"},
+		{"Rootless Podman tunnel", containerRuntime{name: "podman", rootless: true}, tunneled, "--network host", "127.0.0.1"},
+		{"Rootless Docker remote", containerRuntime{name: "docker", rootless: true}, remote, "--network host", "db.internal"},
+		{"Rootless Docker tunnel", containerRuntime{name: "docker", rootless: true}, tunneled, "--network host", "10.0.2.2"},
+		{"Rootless nerdctl localhost", containerRuntime{name: "nerdctl", rootless: true}, local, "--network host", "10.0.2.2"},
+		{"Docker Desktop remote", containerRuntime{name: "docker", vm: true}, remote, "--add-host=host.docker.internal:host-gateway", "db.internal"},
+		{"Docker Desktop tunnel", containerRuntime{name: "docker", vm: true}, tunneled, "--add-host=host.docker.internal:host-gateway", "host.docker.internal"},
+		{"Podman machine localhost", containerRuntime{name: "podman", vm: true}, local, "", "host.containers.internal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, p := tt.runtime.network(tt.p)
+			if got := strings.Join(spec.cliArgs(), " "); got != tt.opts {
+				t.Errorf("Expected options %q, got %q", tt.opts, got)
+			}
+			if p.Host != tt.host || p.Port != tt.p.Port {
+				t.Errorf("Expected %s:%d, got %s:%d", tt.host, tt.p.Port, p.Host, p.Port)
+			}
+		})
+	}
+}
+
+func TestContainerRuntimeDisplayName(t *testing.T) {
+	if got := (containerRuntime{name: "podman", rootless: true}).displayName(); got != "rootless Podman" {
+		t.Errorf("Expected rootless Podman, got %s", got)
+	}
+	if got := (containerRuntime{name: "nerdctl"}).displayName(); got != "nerdctl" {
+		t.Errorf("Expected nerdctl, got %s", got)
+	}
+}
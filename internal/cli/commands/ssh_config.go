@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// sshHostConfig holds the ~/.ssh/config settings cutter understands for a host
+type sshHostConfig struct {
+	hostName      string
+	port          int
+	user          string
+	identityFiles []string
+	proxyJump     string
+}
+
+// sshConfigBlock is one "Host pattern..." section
+type sshConfigBlock struct {
+	patterns []string
+	settings sshHostConfig
+}
+
+// sshConfig is a parsed OpenSSH client configuration. Only Host blocks and
+// the HostName, Port, User, IdentityFile and ProxyJump keywords are used;
+// everything else, including Match blocks, is ignored.
+type sshConfig struct {
+	blocks []sshConfigBlock
+}
+
+// loadSSHConfig reads an ssh_config file. A missing file yields an empty config.
+func loadSSHConfig(path string) (*sshConfig, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return &sshConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	defer file.Close()
+
+	cfg, err := parseSSHConfig(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// parseSSHConfig parses ssh_config syntax from r
+func parseSSHConfig(r io.Reader) (*sshConfig, error) {
+	cfg := &sshConfig{}
+	// Settings before the first Host line apply to every host
+	current := &sshConfigBlock{patterns: []string{"*"}}
+	skipping := false
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		keyword, value := splitSSHConfigLine(line)
+		if value == "" {
+			return nil, fmt.Errorf("line %d: missing value for %s", lineNo, keyword)
+		}
+
+		switch keyword {
+		case "host":
+			cfg.blocks = append(cfg.blocks, *current)
+			current = &sshConfigBlock{patterns: strings.Fields(value)}
+			skipping = false
+			continue
+		case "match":
+			cfg.blocks = append(cfg.blocks, *current)
+			current = &sshConfigBlock{}
+			skipping = true
+			continue
+		}
+
+		if skipping {
+			continue
+		}
+
+		settings := &current.settings
+		switch keyword {
+		case "hostname":
+			if settings.hostName == "" {
+				settings.hostName = value
+			}
+		case "port":
+			port, err := strconv.Atoi(value)
+			if err != nil || port <= 0 || port > 65535 {
+				return nil, fmt.Errorf("line %d: invalid port %q", lineNo, value)
+			}
+			if settings.port == 0 {
+				settings.port = port
+			}
+		case "user":
+			if settings.user == "" {
+				settings.user = value
+			}
+		case "identityfile":
+			settings.identityFiles = append(settings.identityFiles, value)
+		case "proxyjump":
+			if settings.proxyJump == "" {
+				settings.proxyJump = value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	cfg.blocks = append(cfg.blocks, *current)
+	return cfg, nil
+}
+
+// splitSSHConfigLine splits "Keyword value" or "Keyword=value" and
+// lower-cases the keyword
+func splitSSHConfigLine(line string) (string, string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return strings.ToLower(line), ""
+	}
+
+	keyword := strings.ToLower(line[:i])
+	value := strings.TrimLeft(line[i:], " \t")
+	value = strings.TrimPrefix(value, "=")
+	value = strings.TrimSpace(value)
+	value = strings.Trim(value, `"`)
+	return keyword, value
+}
+
+// lookup returns the settings for alias. As in OpenSSH, the first value
+// obtained for each keyword wins while identity files accumulate.
+func (c *sshConfig) lookup(alias string) sshHostConfig {
+	var result sshHostConfig
+	if c == nil {
+		return result
+	}
+
+	for _, block := range c.blocks {
+		if !matchSSHHostPatterns(block.patterns, alias) {
+			continue
+		}
+
+		s := block.settings
+		if result.hostName == "" {
+			result.hostName = s.hostName
+		}
+		if result.port == 0 {
+			result.port = s.port
+		}
+		if result.user == "" {
+			result.user = s.user
+		}
+		if result.proxyJump == "" {
+			result.proxyJump = s.proxyJump
+		}
+		result.identityFiles = append(result.identityFiles, s.identityFiles...)
+	}
+
+	return result
+}
+
+// matchSSHHostPatterns reports whether host matches a Host line. A negated
+// pattern (!pattern) that matches excludes the host outright.
+func matchSSHHostPatterns(patterns []string, host string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.TrimPrefix(pattern, "!")
+
+		ok, err := path.Match(strings.ToLower(pattern), strings.ToLower(host))
+		if err != nil || !ok {
+			continue
+		}
+		if negated {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// expandHomePath expands a leading ~ in identity file paths
+func expandHomePath(p, home string) string {
+	if p == "~" {
+		return home
+	}
+	if strings.HasPrefix(p, "~/") {
+		return filepath.Join(home, p[2:])
+	}
+	return p
+}
@@ -0,0 +1,167 @@
+package commands
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSSHConfig = `
+# Global defaults
+IdentityFile ~/.ssh/id_global
+
+Host corp-bastion
+  HostName bastion.corp.example.com
+  User alice
+  Port 2222
+  IdentityFile ~/.ssh/id_corp
+
+Host vpc-bastion
+  HostName=10.0.0.5
+  User ec2-user
+  ProxyJump corp-bastion
+
+Host *.internal !secret.internal
+  User internal-user
+
+Match host *
+  User ignored
+
+Host *
+  User fallback
+  Port 22
+`
+
+func TestParseSSHConfigLookup(t *testing.T) {
+	cfg, err := parseSSHConfig(strings.NewReader(testSSHConfig))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	corp := cfg.lookup("corp-bastion")
+	if corp.hostName != "bastion.corp.example.com" || corp.user != "alice" || corp.port != 2222 {
+		t.Errorf("Unexpected corp-bastion settings: %+v", corp)
+	}
+	if len(corp.identityFiles) != 2 || corp.identityFiles[0] != "~/.ssh/id_global" || corp.identityFiles[1] != "~/.ssh/id_corp" {
+		t.Errorf("Expected accumulated identity files, got %v", corp.identityFiles)
+	}
+
+	vpc := cfg.lookup("vpc-bastion")
+	if vpc.hostName != "10.0.0.5" || vpc.proxyJump != "corp-bastion" || vpc.port != 22 {
+		t.Errorf("Unexpected vpc-bastion settings: %+v", vpc)
+	}
+
+	if got := cfg.lookup("db.internal").user; got != "internal-user" {
+		t.Errorf("Expected wildcard user, got %q", got)
+	}
+	if got := cfg.lookup("secret.internal").user; got != "fallback" {
+		t.Errorf("Expected negated pattern to fall through, got %q", got)
+	}
+	if got := cfg.lookup("unknown").hostName; got != "" {
+		t.Errorf("Expected no HostName for unknown host, got %q", got)
+	}
+}
+
+func TestParseSSHConfigInvalidPort(t *testing.T) {
+	_, err := parseSSHConfig(strings.NewReader("Host x\n  Port abc\n"))
+	if err == nil {
+		t.Error("Expected error for invalid port")
+	}
+}
+
+func TestLoadSSHConfigMissingFile(t *testing.T) {
+	cfg, err := loadSSHConfig(filepath.Join(t.TempDir(), "config"))
+	if err != nil {
+		t.Fatalf("Expected no error for missing file, got %v", err)
+	}
+	if got := cfg.lookup("anything"); got.hostName != "" || got.user != "" {
+		t.Errorf("Expected empty settings, got %+v", got)
+	}
+}
+
+func TestParseSSHTargetWithAlias(t *testing.T) {
+	cfg, _ := parseSSHConfig(strings.NewReader(testSSHConfig))
+
+	target, err := parseSSHTarget("corp-bastion", cfg)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if target.String() != "alice@bastion.corp.example.com:2222" {
+		t.Errorf("Unexpected target %s", target)
+	}
+
+	// Explicit user and port override the config
+	target, _ = parseSSHTarget("bob@corp-bastion:2200", cfg)
+	if target.String() != "bob@bastion.corp.example.com:2200" {
+		t.Errorf("Unexpected target %s", target)
+	}
+}
+
+func TestParseSSHJumpChain(t *testing.T) {
+	cfg, _ := parseSSHConfig(strings.NewReader(testSSHConfig))
+
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{"devops@a.example.com", []string{"devops@a.example.com:22"}},
+		{"a.example.com:2200, devops@b.example.com", []string{"fallback@a.example.com:2200", "devops@b.example.com:22"}},
+		{"vpc-bastion", []string{"alice@bastion.corp.example.com:2222", "ec2-user@10.0.0.5:22"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			hops, err := parseSSHJumpChain(tt.spec, cfg)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			var got []string
+			for _, hop := range hops {
+				got = append(got, hop.String())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseSSHJumpChainErrors(t *testing.T) {
+	loop, _ := parseSSHConfig(strings.NewReader("Host a\n  ProxyJump b\nHost b\n  ProxyJump a\n"))
+
+	if _, err := parseSSHJumpChain("a", loop); err == nil {
+		t.Error("Expected error for ProxyJump loop")
+	}
+	if _, err := parseSSHJumpChain("a.example.com,,b.example.com", nil); err == nil {
+		t.Error("Expected error for empty hop")
+	}
+}
+
+func TestMatchSSHHostPatterns(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		host     string
+		want     bool
+	}{
+		{[]string{"*"}, "anything", true},
+		{[]string{"db-?"}, "db-1", true},
+		{[]string{"db-?"}, "db-10", false},
+		{[]string{"*.example.com", "!bad.example.com"}, "bad.example.com", false},
+		{[]string{"Bastion"}, "bastion", true},
+	}
+
+	for _, tt := range tests {
+		if got := matchSSHHostPatterns(tt.patterns, tt.host); got != tt.want {
+			t.Errorf("matchSSHHostPatterns(%v, %q) = %v, want %v", tt.patterns, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestExpandHomePath(t *testing.T) {
+	if got := expandHomePath("~/.ssh/id_rsa", "/home/me"); got != "/home/me/.ssh/id_rsa" {
+		t.Errorf("Unexpected expansion %q", got)
+	}
+	if got := expandHomePath("/etc/key", "/home/me"); got != "/etc/key" {
+		t.Errorf("Unexpected expansion %q", got)
+	}
+}
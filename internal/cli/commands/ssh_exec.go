@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshHostClient runs client tools on the last --ssh-jump hop itself, for
+// file databases that only that host can read. Each argv is quoted for the
+// remote login shell, so none of it is interpreted there.
+type sshHostClient struct {
+	clients []*ssh.Client
+	// ctx, when set, stops running commands once it is canceled
+	ctx context.Context
+}
+
+// openSSHHost connects through sshJump and returns a client for the last hop
+func openSSHHost(ctx context.Context, sshJump string) (*sshHostClient, error) {
+	fmt.Printf("Connecting to %s...\n", sshJump)
+
+	clients, err := dialSSHChain(sshJump)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Println("✓ SSH connection established")
+	return &sshHostClient{clients: clients, ctx: ctx}, nil
+}
+
+// close disconnects from every hop
+func (c *sshHostClient) close() {
+	closeSSHClients(c.clients)
+}
+
+// exec runs args on the host with the given standard streams
+func (c *sshHostClient) exec(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	session, err := c.clients[len(c.clients)-1].NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open SSH session: %v", err)
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	if c.ctx != nil {
+		stop := context.AfterFunc(c.ctx, func() {
+			// Ask the remote command to exit; closing the session also
+			// hangs up on it should the server ignore signals
+			session.Signal(ssh.SIGTERM)
+			session.Close()
+		})
+		defer stop()
+	}
+
+	return session.Run(shellQuoteArgs(args))
+}
+
+// query runs a client command and returns its trimmed stdout
+func (c *sshHostClient) query(args []string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	if err := c.exec(args, nil, &stdout, &stderr); err != nil {
+		return "", fmt.Errorf("%s failed: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// run executes a client command with stdin attached to input
+func (c *sshHostClient) run(args []string, input io.Reader) error {
+	return c.exec(args, input, os.Stdout, os.Stderr)
+}
+
+// dump runs a client command with its stdout connected to stdout
+func (c *sshHostClient) dump(args []string, stdout io.Writer) error {
+	return c.exec(args, nil, stdout, os.Stderr)
+}
+
+// withImage returns c unchanged: the host's own tools are used
+func (c *sshHostClient) withImage(image string) dumpClient {
+	return c
+}
+
+// clientImage is empty: the host's own tools are used
+func (c *sshHostClient) clientImage() string {
+	return ""
+}
+
+// shellQuoteArgs joins args into a POSIX shell command line that runs
+// exactly that argv
+func shellQuoteArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,82 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestShellQuoteArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"sqlite3", "-version"}, `'sqlite3' '-version'`},
+		{[]string{"cat", "it's"}, `'cat' 'it'\''s'`},
+		{[]string{"echo", ""}, `'echo' ''`},
+	}
+
+	for _, tt := range tests {
+		if got := shellQuoteArgs(tt.args); got != tt.want {
+			t.Errorf("shellQuoteArgs(%q) = %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestSSHHostClientRunsArgvRemotely(t *testing.T) {
+	server := startTestSSHServer(t, setupSSHHome(t))
+	trustHostKey(t, server.addr, server.hostKey.PublicKey())
+
+	host, err := openSSHHost(context.Background(), "tester@"+server.addr)
+	if err != nil {
+		t.Fatalf("Expected connection, got error: %v", err)
+	}
+	defer host.close()
+
+	hostile := `it's "my" file; $(echo pwned) *`
+	out, err := host.query([]string{"printf", "%s|", "a b", hostile})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if want := "a b|" + hostile + "|"; out != want {
+		t.Errorf("Expected %q, got %q", want, out)
+	}
+
+	_, err = host.query([]string{"sh", "-c", "echo broken >&2; exit 4"})
+	if err == nil || !strings.Contains(err.Error(), "status 4") || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Expected exit status and stderr in error, got %v", err)
+	}
+}
+
+func TestOpenClientRunsFileDatabaseToolsOnSSHHost(t *testing.T) {
+	server := startTestSSHServer(t, setupSSHHome(t))
+	trustHostKey(t, server.addr, server.hostKey.PublicKey())
+	path := sqliteFixture(t, t.TempDir())
+
+	// docker is never needed when the tools run on the SSH host
+	original := dockerBinary
+	dockerBinary = "cutter-missing-docker"
+	t.Cleanup(func() { dockerBinary = original })
+
+	d := sqliteDriver{}
+	client, conn, cleanup, err := openClient(context.Background(), d, ConnParams{Path: path},
+		clientOptions{sshJump: "tester@" + server.addr, runtime: "auto", image: d.ClientImage()})
+	if err != nil {
+		t.Fatalf("Expected client, got error: %v", err)
+	}
+	defer cleanup()
+
+	var dump bytes.Buffer
+	if err := client.dump(d.DumpCommand(conn, DumpFilters{}, DumpFormat{Name: "sqlite"}), &dump); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if rows := sqliteRows(t, dump.Bytes()); rows != "ada" {
+		t.Errorf("Expected the committed row, got %q", rows)
+	}
+
+	tables, err := d.CountTables(client.query, conn)
+	if err != nil || tables != 1 {
+		t.Errorf("Expected 1 table, got %d (%v)", tables, err)
+	}
+}
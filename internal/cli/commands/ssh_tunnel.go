@@ -0,0 +1,544 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// sshDialTimeout bounds the connect and the SSH handshake with each hop
+var sshDialTimeout = 15 * time.Second
+
+// defaultIdentityFiles are tried, in order, when present in ~/.ssh
+var defaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// systemKnownHostsFile is consulted in addition to ~/.ssh/known_hosts
+var systemKnownHostsFile = "/etc/ssh/ssh_known_hosts"
+
+// sshJumpUsage is the help text shared by every --ssh-jump flag
+const sshJumpUsage = "SSH jump host (user@host[:port] or ~/.ssh/config alias; comma-separate multiple hops)"
+
+// maxSSHHops guards against ProxyJump loops in ~/.ssh/config
+const maxSSHHops = 10
+
+// sshTunnel represents an active SSH tunnel
+type sshTunnel struct {
+	// clients holds one connection per hop; the last one forwards traffic
+	clients   []*ssh.Client
+	listener  net.Listener
+	localPort int
+	wg        sync.WaitGroup
+}
+
+// sshTarget is a resolved jump host
+type sshTarget struct {
+	user          string
+	host          string
+	port          int
+	identityFiles []string
+}
+
+func (t sshTarget) addr() string {
+	return net.JoinHostPort(t.host, strconv.Itoa(t.port))
+}
+
+func (t sshTarget) String() string {
+	return fmt.Sprintf("%s@%s", t.user, t.addr())
+}
+
+// parseSSHTarget parses user@host or user@host:port, where host may be a
+// Host alias from cfg. Explicit user and port win over the config; the
+// user then defaults to the current login and the port to 22.
+func parseSSHTarget(spec string, cfg *sshConfig) (sshTarget, error) {
+	var target sshTarget
+
+	hostPart := spec
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		target.user = spec[:i]
+		hostPart = spec[i+1:]
+	}
+
+	if host, port, err := net.SplitHostPort(hostPart); err == nil {
+		p, err := strconv.Atoi(port)
+		if err != nil || p <= 0 || p > 65535 {
+			return sshTarget{}, fmt.Errorf("invalid SSH port in %q", spec)
+		}
+		target.host = host
+		target.port = p
+	} else {
+		target.host = strings.Trim(hostPart, "[]")
+	}
+
+	if target.host == "" {
+		return sshTarget{}, fmt.Errorf("invalid SSH jump host %q (expected user@host or user@host:port)", spec)
+	}
+
+	alias := cfg.lookup(target.host)
+	if alias.hostName != "" {
+		target.host = alias.hostName
+	}
+	if target.port == 0 {
+		target.port = alias.port
+	}
+	if target.user == "" {
+		target.user = alias.user
+	}
+	target.identityFiles = alias.identityFiles
+
+	if target.port == 0 {
+		target.port = 22
+	}
+	if target.user == "" {
+		current, err := user.Current()
+		if err != nil {
+			return sshTarget{}, fmt.Errorf("no SSH user given and current user is unknown: %v", err)
+		}
+		target.user = current.Username
+	}
+
+	return target, nil
+}
+
+// parseSSHJumpChain resolves a comma-separated list of hops, like ssh -J.
+// A hop whose config entry has a ProxyJump is preceded by that chain.
+func parseSSHJumpChain(spec string, cfg *sshConfig) ([]sshTarget, error) {
+	var hops []sshTarget
+
+	var resolve func(spec string, depth int) error
+	resolve = func(spec string, depth int) error {
+		for _, hop := range strings.Split(spec, ",") {
+			hop = strings.TrimSpace(hop)
+			if hop == "" {
+				return fmt.Errorf("empty hop in SSH jump chain %q", spec)
+			}
+
+			alias := hop
+			if i := strings.LastIndex(alias, "@"); i >= 0 {
+				alias = alias[i+1:]
+			}
+			if proxy := cfg.lookup(alias).proxyJump; proxy != "" && !strings.EqualFold(proxy, "none") {
+				if depth >= maxSSHHops {
+					return fmt.Errorf("SSH jump chain for %q is too deep (ProxyJump loop?)", hop)
+				}
+				if err := resolve(proxy, depth+1); err != nil {
+					return err
+				}
+			}
+
+			target, err := parseSSHTarget(hop, cfg)
+			if err != nil {
+				return err
+			}
+			hops = append(hops, target)
+			if len(hops) > maxSSHHops {
+				return fmt.Errorf("SSH jump chain has more than %d hops", maxSSHHops)
+			}
+		}
+		return nil
+	}
+
+	if err := resolve(spec, 0); err != nil {
+		return nil, err
+	}
+	return hops, nil
+}
+
+// createSSHTunnel creates an SSH tunnel to the database through one or more
+// comma-separated jump hosts
+func createSSHTunnel(sshJump, dbHost string, dbPort int) (*sshTunnel, error) {
+	fmt.Printf("Creating SSH tunnel through %s...\n", sshJump)
+
+	clients, err := dialSSHChain(sshJump)
+	if err != nil {
+		return nil, err
+	}
+	tunnel := &sshTunnel{clients: clients}
+	last := tunnel.clients[len(tunnel.clients)-1]
+
+	// Prove the last hop can actually reach the database before reporting
+	// the tunnel as ready
+	remoteAddr := net.JoinHostPort(dbHost, strconv.Itoa(dbPort))
+	probe, err := last.Dial("tcp", remoteAddr)
+	if err != nil {
+		tunnel.closeClients()
+		return nil, fmt.Errorf("jump host cannot reach %s: %v", remoteAddr, err)
+	}
+	probe.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tunnel.closeClients()
+		return nil, fmt.Errorf("failed to listen on local port: %v", err)
+	}
+	tunnel.listener = listener
+	tunnel.localPort = listener.Addr().(*net.TCPAddr).Port
+
+	tunnel.wg.Add(1)
+	go tunnel.serve(last, remoteAddr)
+
+	fmt.Printf("  Local port %d -> %s\n", tunnel.localPort, remoteAddr)
+	fmt.Println("✓ SSH tunnel established")
+
+	return tunnel, nil
+}
+
+// dialSSHChain connects to every hop of sshJump in turn and returns one
+// client per hop; the last one reaches the final host
+func dialSSHChain(sshJump string) ([]*ssh.Client, error) {
+	// Validate SSH jump host format (user@host or user@host:port)
+	if sshJump == "" {
+		return nil, fmt.Errorf("SSH jump host cannot be empty")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("cannot locate home directory: %v", err)
+	}
+
+	cfg, err := loadSSHConfig(filepath.Join(home, ".ssh", "config"))
+	if err != nil {
+		return nil, err
+	}
+
+	hops, err := parseSSHJumpChain(sshJump, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := knownHostsCallback(home)
+	if err != nil {
+		return nil, err
+	}
+
+	// One agent connection serves every hop. It is only needed to
+	// authenticate, so it is closed once the chain is dialed.
+	var keys agent.Agent
+	if conn := dialSSHAgent(); conn != nil {
+		defer conn.Close()
+		keys = agent.NewClient(conn)
+	}
+
+	var clients []*ssh.Client
+	for i, hop := range hops {
+		config, err := sshClientConfig(hop, home, hostKeyCallback, keys)
+		if err != nil {
+			closeSSHClients(clients)
+			return nil, err
+		}
+
+		var client *ssh.Client
+		if i == 0 {
+			client, err = dialDirect(hop, config)
+		} else {
+			client, err = dialThrough(clients[i-1], hop, config)
+		}
+		if err != nil {
+			closeSSHClients(clients)
+			return nil, explainSSHError(hop, err)
+		}
+
+		if len(hops) > 1 {
+			fmt.Printf("  Hop %d: %s\n", i+1, hop)
+		}
+		clients = append(clients, client)
+	}
+	return clients, nil
+}
+
+// dialDirect opens an SSH connection to the first hop
+func dialDirect(hop sshTarget, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := net.DialTimeout("tcp", hop.addr(), sshDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return handshakeSSH(conn, hop.addr(), config)
+}
+
+// dialThrough opens an SSH connection to hop over a forwarded connection
+// from an already established client
+func dialThrough(via *ssh.Client, hop sshTarget, config *ssh.ClientConfig) (*ssh.Client, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sshDialTimeout)
+	defer cancel()
+
+	conn, err := via.DialContext(ctx, "tcp", hop.addr())
+	if err != nil {
+		return nil, err
+	}
+	return handshakeSSH(conn, hop.addr(), config)
+}
+
+// handshakeSSH runs the SSH handshake over conn and closes conn when it
+// fails or outlasts sshDialTimeout. Forwarded connections do not support
+// deadlines, so a timer does the closing.
+func handshakeSSH(conn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	timer := time.AfterFunc(sshDialTimeout, func() { conn.Close() })
+	clientConn, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !timer.Stop() {
+		if err == nil {
+			clientConn.Close()
+		}
+		return nil, fmt.Errorf("SSH handshake timed out after %v", sshDialTimeout)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, chans, reqs), nil
+}
+
+// serve accepts local connections and forwards each one to remoteAddr
+// until the listener is closed
+func (t *sshTunnel) serve(client *ssh.Client, remoteAddr string) {
+	defer t.wg.Done()
+
+	for {
+		local, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		remote, err := client.Dial("tcp", remoteAddr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "SSH tunnel: failed to reach %s: %v\n", remoteAddr, err)
+			local.Close()
+			continue
+		}
+
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			pipeConns(local, remote)
+		}()
+	}
+}
+
+// pipeConns copies data in both directions and closes both ends once
+// either side is done
+func pipeConns(a, b net.Conn) {
+	var once sync.Once
+	closeBoth := func() {
+		a.Close()
+		b.Close()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(a, b)
+		once.Do(closeBoth)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(b, a)
+		once.Do(closeBoth)
+	}()
+	wg.Wait()
+}
+
+// close terminates the SSH tunnel
+func (t *sshTunnel) close() error {
+	if len(t.clients) == 0 {
+		return nil
+	}
+
+	fmt.Println("Closing SSH tunnel...")
+
+	if t.listener != nil {
+		t.listener.Close()
+	}
+	err := t.closeClients()
+	t.wg.Wait()
+
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("failed to close SSH tunnel: %v", err)
+	}
+	return nil
+}
+
+// closeClients closes every hop, innermost first, and returns the first error
+func (t *sshTunnel) closeClients() error {
+	err := closeSSHClients(t.clients)
+	t.clients = nil
+	return err
+}
+
+// closeSSHClients closes the clients of a jump chain, innermost first, and
+// returns the first error
+func closeSSHClients(clients []*ssh.Client) error {
+	var first error
+	for i := len(clients) - 1; i >= 0; i-- {
+		if err := clients[i].Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// sshClientConfig builds a client config for one hop that verifies the host
+// key with hostKeyCallback and authenticates with keys, the ssh-agent when
+// one runs, and the hop's identity files
+func sshClientConfig(target sshTarget, home string, hostKeyCallback ssh.HostKeyCallback, keys agent.Agent) (*ssh.ClientConfig, error) {
+	signers := identitySigners(target, home)
+	if keys == nil && len(signers) == 0 {
+		return nil, fmt.Errorf("no SSH credentials found for %s (start ssh-agent or create ~/.ssh/id_ed25519)", target)
+	}
+
+	// All keys must be offered through a single publickey method because
+	// the ssh package only tries each method type once
+	auth := ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		var all []ssh.Signer
+		if keys != nil {
+			if s, err := keys.Signers(); err == nil {
+				all = append(all, s...)
+			}
+		}
+		return append(all, signers...), nil
+	})
+
+	return &ssh.ClientConfig{
+		User:              target.user,
+		Auth:              []ssh.AuthMethod{auth},
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: knownHostKeyAlgorithms(hostKeyCallback, target.addr()),
+	}, nil
+}
+
+// knownHostsCallback loads the user's and the system's known_hosts files
+func knownHostsCallback(home string) (ssh.HostKeyCallback, error) {
+	var files []string
+	for _, path := range []string{
+		filepath.Join(home, ".ssh", "known_hosts"),
+		systemKnownHostsFile,
+	} {
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no known_hosts file found; connect once with ssh to verify and record the jump host key")
+	}
+
+	callback, err := knownhosts.New(files...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts: %v", err)
+	}
+	return callback, nil
+}
+
+// knownHostKeyAlgorithms returns the key algorithms recorded for addr so
+// the server is asked for a key type we can actually verify
+func knownHostKeyAlgorithms(callback ssh.HostKeyCallback, addr string) []string {
+	placeholder := &net.TCPAddr{IP: net.IPv4zero}
+	err := callback(addr, placeholder, unknownHostKey{})
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		algorithms = append(algorithms, hostKeyAlgorithmsFor(known.Key.Type())...)
+	}
+	return algorithms
+}
+
+// hostKeyAlgorithmsFor expands RSA keys to the signature algorithms servers
+// negotiate for them
+func hostKeyAlgorithmsFor(keyType string) []string {
+	if keyType == ssh.KeyAlgoRSA {
+		return []string{ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA}
+	}
+	return []string{keyType}
+}
+
+// unknownHostKey never matches a known_hosts entry; it is used to probe
+// which keys are recorded for a host
+type unknownHostKey struct{}
+
+func (unknownHostKey) Type() string                        { return "cutter-probe" }
+func (unknownHostKey) Marshal() []byte                     { return []byte("cutter-probe") }
+func (unknownHostKey) Verify([]byte, *ssh.Signature) error { return errors.New("probe key") }
+func (unknownHostKey) VerifyWithAlgorithm(string, []byte, *ssh.Signature) error {
+	return errors.New("probe key")
+}
+
+// identitySigners loads the unencrypted identity files configured for the
+// hop, or the default ones when none are configured. Encrypted keys are
+// skipped; load them into ssh-agent instead.
+func identitySigners(target sshTarget, home string) []ssh.Signer {
+	paths := target.identityFiles
+	explicit := len(paths) > 0
+	if !explicit {
+		for _, name := range defaultIdentityFiles {
+			paths = append(paths, filepath.Join(home, ".ssh", name))
+		}
+	}
+
+	var signers []ssh.Signer
+	for _, path := range paths {
+		path = expandHomePath(path, home)
+		pemBytes, err := os.ReadFile(path)
+		if err != nil {
+			if explicit {
+				fmt.Fprintf(os.Stderr, "Warning: cannot read identity file %s: %v\n", path, err)
+			}
+			continue
+		}
+
+		signer, err := ssh.ParsePrivateKey(pemBytes)
+		if err != nil {
+			var missing *ssh.PassphraseMissingError
+			if !errors.As(err, &missing) {
+				fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", path, err)
+			}
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	return signers
+}
+
+// dialSSHAgent connects to the running ssh-agent, or returns nil when no
+// agent is running. The caller must close the connection.
+func dialSSHAgent() net.Conn {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil
+	}
+	return conn
+}
+
+// explainSSHError turns host key failures into actionable messages
+func explainSSHError(target sshTarget, err error) error {
+	var keyErr *knownhosts.KeyError
+	if errors.As(err, &keyErr) {
+		if len(keyErr.Want) == 0 {
+			return fmt.Errorf("host key for %s is not in known_hosts; verify it and add it with: ssh -p %d %s@%s",
+				target.host, target.port, target.user, target.host)
+		}
+		return fmt.Errorf("HOST KEY MISMATCH for %s: the key differs from %s:%d, refusing to connect",
+			target.host, keyErr.Want[0].Filename, keyErr.Want[0].Line)
+	}
+	return fmt.Errorf("failed to connect to %s: %v", target.addr(), err)
+}
@@ -0,0 +1,573 @@
+package commands
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testSSHServer is a minimal in-process SSH server that accepts one client
+// key and serves direct-tcpip (local forwarding) channels and sessions
+// running exec requests with the local sh
+type testSSHServer struct {
+	addr    string
+	hostKey ssh.Signer
+}
+
+func startTestSSHServer(t *testing.T, clientKey ssh.PublicKey) *testSSHServer {
+	t.Helper()
+
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate host key: %v", err)
+	}
+	hostKey, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatalf("Failed to create host signer: %v", err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) == string(clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveTestSSHConn(conn, config)
+		}
+	}()
+
+	return &testSSHServer{addr: listener.Addr().String(), hostKey: hostKey}
+}
+
+func serveTestSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChan := range chans {
+		if newChan.ChannelType() == "session" {
+			go serveTestSSHSession(newChan)
+			continue
+		}
+		if newChan.ChannelType() != "direct-tcpip" {
+			newChan.Reject(ssh.UnknownChannelType, "unsupported")
+			continue
+		}
+
+		var payload struct {
+			Host     string
+			Port     uint32
+			OrigHost string
+			OrigPort uint32
+		}
+		ssh.Unmarshal(newChan.ExtraData(), &payload)
+
+		target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+		if err != nil {
+			newChan.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+
+		channel, chanReqs, err := newChan.Accept()
+		if err != nil {
+			target.Close()
+			continue
+		}
+		go ssh.DiscardRequests(chanReqs)
+		go func() {
+			defer channel.Close()
+			defer target.Close()
+			go io.Copy(target, channel)
+			io.Copy(channel, target)
+		}()
+	}
+}
+
+// serveTestSSHSession runs the command of an exec request with sh -c and
+// reports its exit status
+func serveTestSSHSession(newChan ssh.NewChannel) {
+	channel, reqs, err := newChan.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+
+	for req := range reqs {
+		if req.Type != "exec" {
+			req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		ssh.Unmarshal(req.Payload, &payload)
+		req.Reply(true, nil)
+
+		cmd := exec.Command("sh", "-c", payload.Command)
+		cmd.Stdin = channel
+		cmd.Stdout = channel
+		cmd.Stderr = channel.Stderr()
+		status := 0
+		if err := cmd.Run(); err != nil {
+			status = 1
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				status = exitErr.ExitCode()
+			}
+		}
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+		return
+	}
+}
+
+// startEchoServer returns the address of a TCP server that echoes input
+func startEchoServer(t *testing.T) (string, int) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+// setupSSHHome points HOME at a fresh directory holding an unencrypted
+// identity file and returns its public key
+func setupSSHHome(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	originalSystemFile := systemKnownHostsFile
+	systemKnownHostsFile = filepath.Join(home, "ssh_known_hosts")
+	t.Cleanup(func() { systemKnownHostsFile = originalSystemFile })
+
+	sshDir := filepath.Join(home, ".ssh")
+	if err := os.MkdirAll(sshDir, 0700); err != nil {
+		t.Fatalf("Failed to create .ssh: %v", err)
+	}
+
+	_, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate client key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatalf("Failed to marshal client key: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(sshDir, "id_ed25519"), pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Failed to write identity: %v", err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(clientPriv)
+	if err != nil {
+		t.Fatalf("Failed to create client signer: %v", err)
+	}
+	return signer.PublicKey()
+}
+
+// trustHostKey appends a known_hosts entry that trusts key for host
+func trustHostKey(t *testing.T, host string, key ssh.PublicKey) {
+	t.Helper()
+
+	home, _ := os.UserHomeDir()
+	file, err := os.OpenFile(filepath.Join(home, ".ssh", "known_hosts"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("Failed to open known_hosts: %v", err)
+	}
+	defer file.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(host)}, key)
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+}
+
+// randomHostKey returns a public key no test server uses
+func randomHostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	return signer.PublicKey()
+}
+
+func TestParseSSHTarget(t *testing.T) {
+	tests := []struct {
+		spec     string
+		wantUser string
+		wantHost string
+		wantPort int
+		wantErr  bool
+	}{
+		{"devops@jumphost.com", "devops", "jumphost.com", 22, false},
+		{"devops@jumphost.com:2222", "devops", "jumphost.com", 2222, false},
+		{"devops@[2001:db8::1]:2222", "devops", "2001:db8::1", 2222, false},
+		{"devops@jumphost.com:0", "", "", 0, true},
+		{"devops@", "", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseSSHTarget(tt.spec, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.user != tt.wantUser || got.host != tt.wantHost || got.port != tt.wantPort {
+				t.Errorf("Expected %s@%s:%d, got %s@%s:%d", tt.wantUser, tt.wantHost, tt.wantPort, got.user, got.host, got.port)
+			}
+		})
+	}
+}
+
+func TestParseSSHTargetDefaultUser(t *testing.T) {
+	got, err := parseSSHTarget("jumphost.com", nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if got.user == "" {
+		t.Error("Expected user to default to the current login")
+	}
+}
+
+func TestCreateSSHTunnelForwardsTraffic(t *testing.T) {
+	echoHost, echoPort := startEchoServer(t)
+
+	server := startTestSSHServer(t, setupSSHHome(t))
+	trustHostKey(t, server.addr, server.hostKey.PublicKey())
+
+	tunnel, err := createSSHTunnel("tester@"+server.addr, echoHost, echoPort)
+	if err != nil {
+		t.Fatalf("Expected tunnel, got error: %v", err)
+	}
+	defer tunnel.close()
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(tunnel.localPort)))
+	if err != nil {
+		t.Fatalf("Failed to connect to tunnel: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Failed to write through tunnel: %v", err)
+	}
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		t.Fatalf("Failed to read through tunnel: %v", err)
+	}
+	if string(reply) != "ping" {
+		t.Errorf("Expected echo 'ping', got %q", reply)
+	}
+}
+
+func TestCreateSSHTunnelMultiHop(t *testing.T) {
+	echoHost, echoPort := startEchoServer(t)
+
+	clientKey := setupSSHHome(t)
+	bastion := startTestSSHServer(t, clientKey)
+	inner := startTestSSHServer(t, clientKey)
+	trustHostKey(t, bastion.addr, bastion.hostKey.PublicKey())
+	trustHostKey(t, inner.addr, inner.hostKey.PublicKey())
+
+	tunnel, err := createSSHTunnel("tester@"+bastion.addr+",tester@"+inner.addr, echoHost, echoPort)
+	if err != nil {
+		t.Fatalf("Expected tunnel, got error: %v", err)
+	}
+	defer tunnel.close()
+
+	if len(tunnel.clients) != 2 {
+		t.Errorf("Expected 2 hops, got %d", len(tunnel.clients))
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(tunnel.localPort)))
+	if err != nil {
+		t.Fatalf("Failed to connect to tunnel: %v", err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("pong"))
+	reply := make([]byte, 4)
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "pong" {
+		t.Errorf("Expected echo 'pong', got %q (%v)", reply, err)
+	}
+}
+
+// startTestSSHAgent moves the identity file of setupSSHHome into an
+// in-process ssh-agent on SSH_AUTH_SOCK and returns a counter of its
+// connections and a channel receiving one value per closed connection
+func startTestSSHAgent(t *testing.T) (func() int, <-chan struct{}) {
+	t.Helper()
+
+	home, _ := os.UserHomeDir()
+	identity := filepath.Join(home, ".ssh", "id_ed25519")
+	pemBytes, err := os.ReadFile(identity)
+	if err != nil {
+		t.Fatalf("Failed to read identity: %v", err)
+	}
+	key, err := ssh.ParseRawPrivateKey(pemBytes)
+	if err != nil {
+		t.Fatalf("Failed to parse identity: %v", err)
+	}
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatalf("Failed to add key to agent: %v", err)
+	}
+	os.Remove(identity)
+
+	// Unix socket paths are short, so stay out of the long test directory
+	dir, err := os.MkdirTemp("", "agent")
+	if err != nil {
+		t.Fatalf("Failed to create socket directory: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socket := filepath.Join(dir, "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	var mu sync.Mutex
+	accepted := 0
+	closed := make(chan struct{}, 16)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			accepted++
+			mu.Unlock()
+			go func() {
+				agent.ServeAgent(keyring, conn)
+				conn.Close()
+				closed <- struct{}{}
+			}()
+		}
+	}()
+
+	return func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return accepted
+	}, closed
+}
+
+func TestCreateSSHTunnelSharesAgent(t *testing.T) {
+	echoHost, echoPort := startEchoServer(t)
+
+	clientKey := setupSSHHome(t)
+	connections, closed := startTestSSHAgent(t)
+	bastion := startTestSSHServer(t, clientKey)
+	inner := startTestSSHServer(t, clientKey)
+	trustHostKey(t, bastion.addr, bastion.hostKey.PublicKey())
+	trustHostKey(t, inner.addr, inner.hostKey.PublicKey())
+
+	tunnel, err := createSSHTunnel("tester@"+bastion.addr+",tester@"+inner.addr, echoHost, echoPort)
+	if err != nil {
+		t.Fatalf("Expected tunnel through the agent's key, got error: %v", err)
+	}
+	defer tunnel.close()
+
+	if got := connections(); got != 1 {
+		t.Errorf("Expected one agent connection for both hops, got %d", got)
+	}
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Error("Expected the agent connection to be closed")
+	}
+}
+
+func TestCreateSSHTunnelConfigAlias(t *testing.T) {
+	echoHost, echoPort := startEchoServer(t)
+
+	server := startTestSSHServer(t, setupSSHHome(t))
+	trustHostKey(t, server.addr, server.hostKey.PublicKey())
+
+	host, port, _ := net.SplitHostPort(server.addr)
+	config := "Host bastion\n  HostName " + host + "\n  Port " + port + "\n  User tester\n"
+	home, _ := os.UserHomeDir()
+	if err := os.WriteFile(filepath.Join(home, ".ssh", "config"), []byte(config), 0600); err != nil {
+		t.Fatalf("Failed to write ssh config: %v", err)
+	}
+
+	tunnel, err := createSSHTunnel("bastion", echoHost, echoPort)
+	if err != nil {
+		t.Fatalf("Expected tunnel through alias, got error: %v", err)
+	}
+	tunnel.close()
+}
+
+func TestCreateSSHTunnelUnknownHostKey(t *testing.T) {
+	echoHost, echoPort := startEchoServer(t)
+
+	server := startTestSSHServer(t, setupSSHHome(t))
+
+	// Trust a different host so known_hosts exists but lacks this server
+	trustHostKey(t, "other.example.com", randomHostKey(t))
+
+	_, err := createSSHTunnel("tester@"+server.addr, echoHost, echoPort)
+	if err == nil || !strings.Contains(err.Error(), "not in known_hosts") {
+		t.Errorf("Expected unknown host key error, got %v", err)
+	}
+}
+
+func TestCreateSSHTunnelHostKeyMismatch(t *testing.T) {
+	echoHost, echoPort := startEchoServer(t)
+
+	server := startTestSSHServer(t, setupSSHHome(t))
+	trustHostKey(t, server.addr, randomHostKey(t))
+
+	_, err := createSSHTunnel("tester@"+server.addr, echoHost, echoPort)
+	if err == nil || !strings.Contains(err.Error(), "HOST KEY MISMATCH") {
+		t.Errorf("Expected host key mismatch error, got %v", err)
+	}
+}
+
+func TestCreateSSHTunnelUnreachableDatabase(t *testing.T) {
+	server := startTestSSHServer(t, setupSSHHome(t))
+	trustHostKey(t, server.addr, server.hostKey.PublicKey())
+
+	// Grab a free port and release it so nothing is listening there
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := listener.Addr().(*net.TCPAddr).Port
+	listener.Close()
+
+	_, err := createSSHTunnel("tester@"+server.addr, "127.0.0.1", closedPort)
+	if err == nil || !strings.Contains(err.Error(), "cannot reach") {
+		t.Errorf("Expected unreachable database error, got %v", err)
+	}
+}
+
+// startSilentServer accepts TCP connections and never answers, like a host
+// whose sshd hangs before the handshake
+func startSilentServer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	var (
+		mu    sync.Mutex
+		conns []net.Conn
+	)
+	t.Cleanup(func() {
+		listener.Close()
+		mu.Lock()
+		defer mu.Unlock()
+		for _, conn := range conns {
+			conn.Close()
+		}
+	})
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, conn)
+			mu.Unlock()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestCreateSSHTunnelHandshakeTimeout(t *testing.T) {
+	timeout := sshDialTimeout
+	sshDialTimeout = 200 * time.Millisecond
+	defer func() { sshDialTimeout = timeout }()
+
+	clientKey := setupSSHHome(t)
+	bastion := startTestSSHServer(t, clientKey)
+	trustHostKey(t, bastion.addr, bastion.hostKey.PublicKey())
+	silent := startSilentServer(t)
+
+	tests := []struct {
+		name  string
+		chain string
+	}{
+		{"first hop", "tester@" + silent},
+		{"later hop", "tester@" + bastion.addr + ",tester@" + silent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			_, err := createSSHTunnel(tt.chain, "127.0.0.1", 5432)
+			if err == nil || !strings.Contains(err.Error(), "timed out") {
+				t.Errorf("Expected handshake timeout, got %v", err)
+			}
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Errorf("Expected the handshake to give up quickly, took %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestCreateSSHTunnelMissingKnownHosts(t *testing.T) {
+	setupSSHHome(t)
+
+	_, err := createSSHTunnel("tester@127.0.0.1:1", "localhost", 5432)
+	if err == nil || !strings.Contains(err.Error(), "known_hosts") {
+		t.Errorf("Expected missing known_hosts error, got %v", err)
+	}
+}
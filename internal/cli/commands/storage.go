@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// storedBackup is one file in a backupStore
+type storedBackup struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// backupWriter receives a backup stream. Commit makes the written data
+// visible under its name; Abort discards it and reports the destination's
+// own failure when that is what broke the stream.
+type backupWriter interface {
+	io.Writer
+	Commit() error
+	Abort() error
+}
+
+// backupStore is a place backups are written to, listed from and removed
+// from: a local directory or an S3-compatible bucket prefix
+type backupStore interface {
+	// Location names name in the store for display
+	Location(name string) string
+	Create(name string) (backupWriter, error)
+	Open(name string) (io.ReadCloser, error)
+	// List returns the files in the store sorted by name
+	List() ([]storedBackup, error)
+	Remove(name string) error
+}
+
+// storageOptions configures object storage destinations
+type storageOptions struct {
+	endpoint string
+	region   string
+	insecure bool
+}
+
+// addStorageFlags registers the object storage flags shared by the db commands
+func addStorageFlags(cmd *cobra.Command, opts *storageOptions) {
+	cmd.Flags().StringVar(&opts.endpoint, "s3-endpoint", "s3.amazonaws.com", "S3-compatible endpoint for s3:// locations (host[:port])")
+	cmd.Flags().StringVar(&opts.region, "s3-region", "", "Region for s3:// locations")
+	cmd.Flags().BoolVar(&opts.insecure, "s3-insecure", false, "Use plain HTTP for the S3 endpoint (local MinIO)")
+}
+
+// isObjectStorage reports whether location is an s3:// URL
+func isObjectStorage(location string) bool {
+	return strings.HasPrefix(location, "s3://")
+}
+
+// openStore resolves a --output value or db list location into a store and
+// a file name. The name is empty when location only names a directory or
+// prefix (a trailing slash, an existing directory or a bare bucket). A
+// leading ~ in a local location is the home directory.
+func openStore(location string, opts storageOptions) (backupStore, string, error) {
+	if isObjectStorage(location) {
+		bucket, key, _ := strings.Cut(strings.TrimPrefix(location, "s3://"), "/")
+		if bucket == "" {
+			return nil, "", fmt.Errorf("invalid S3 location %q: missing bucket", location)
+		}
+
+		prefix, name := key, ""
+		if key != "" && !strings.HasSuffix(key, "/") {
+			prefix, name = key[:strings.LastIndex(key, "/")+1], key[strings.LastIndex(key, "/")+1:]
+		}
+
+		store, err := newS3Store(opts, bucket, prefix)
+		return store, name, err
+	}
+
+	if location == "" {
+		return localStore{dir: "."}, "", nil
+	}
+	dirOnly := strings.HasSuffix(location, string(os.PathSeparator))
+	location = expandUserPath(location)
+	if info, err := os.Stat(location); (err == nil && info.IsDir()) || dirOnly {
+		return localStore{dir: location}, "", nil
+	}
+	return localStore{dir: filepath.Dir(location)}, filepath.Base(location), nil
+}
+
+// isBackupName reports whether name looks like a dump rather than a
+// manifest or an unrelated file
+func isBackupName(name string) bool {
+	return backupExtPattern.MatchString(name) && !strings.HasSuffix(name, manifestSuffix)
+}
+
+// partialMarker is part of the temporary names backups are written under
+// until they are complete. List skips them, so an interrupted backup is
+// never mistaken for a finished one.
+const partialMarker = ".partial-"
+
+// partialName returns the pattern of temporary names for name, for
+// os.CreateTemp and os.MkdirTemp
+func partialName(name string) string {
+	return "." + name + partialMarker + "*"
+}
+
+// localStore keeps backups in a directory on this machine
+type localStore struct {
+	dir string
+}
+
+func (s localStore) Location(name string) string {
+	if s.dir == "." {
+		return name
+	}
+	return filepath.Join(s.dir, name)
+}
+
+func (s localStore) Create(name string) (backupWriter, error) {
+	// CreateTemp already restricts the file to 0600
+	file, err := os.CreateTemp(s.dir, partialName(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create output file: %v", err)
+	}
+	return localWriter{File: file, path: filepath.Join(s.dir, name)}, nil
+}
+
+func (s localStore) Open(name string) (io.ReadCloser, error) {
+	file, err := os.Open(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open backup file: %v", err)
+	}
+	return file, nil
+}
+
+func (s localStore) List() ([]storedBackup, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %v", s.dir, err)
+	}
+
+	var backups []storedBackup
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), partialMarker) {
+			continue
+		}
+		// Unpacked directory-format dumps are the only directories listed
+		if entry.IsDir() && backupFormat(entry.Name()) != "directory" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		size := info.Size()
+		if entry.IsDir() {
+			size = dirSize(filepath.Join(s.dir, entry.Name()))
+		}
+		backups = append(backups, storedBackup{Name: entry.Name(), Size: size, ModTime: info.ModTime()})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name < backups[j].Name })
+	return backups, nil
+}
+
+func (s localStore) Remove(name string) error {
+	path := filepath.Join(s.dir, name)
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		return os.RemoveAll(path)
+	}
+	return os.Remove(path)
+}
+
+// localWriter writes into a temporary file next to path and renames it
+// into place on Commit, so path only ever holds a complete backup
+type localWriter struct {
+	*os.File
+	path string
+}
+
+func (w localWriter) Commit() error {
+	if err := w.Sync(); err != nil {
+		w.Abort()
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		os.Remove(w.Name())
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+	if err := os.Rename(w.Name(), w.path); err != nil {
+		os.Remove(w.Name())
+		return fmt.Errorf("failed to write output file: %v", err)
+	}
+	return nil
+}
+
+func (w localWriter) Abort() error {
+	w.Close()
+	os.Remove(w.Name())
+	return nil
+}
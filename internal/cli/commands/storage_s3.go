@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3PartSize is the multipart chunk buffered in memory while uploading.
+// S3 allows 10,000 parts, so this caps a single backup at about 312 GiB.
+// Tests lower it.
+var s3PartSize uint64 = 32 << 20
+
+// s3Transport overrides the HTTP transport; nil uses the default. Tests
+// set it to trust their TLS server.
+var s3Transport http.RoundTripper
+
+// s3Store keeps backups under a prefix of an S3-compatible bucket
+type s3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// newS3Store connects to the configured endpoint. Credentials come from
+// the usual places: AWS_ACCESS_KEY_ID/AWS_SECRET_ACCESS_KEY, MINIO_ROOT_USER/
+// MINIO_ROOT_PASSWORD, ~/.aws/credentials, then instance metadata.
+func newS3Store(opts storageOptions, bucket, prefix string) (*s3Store, error) {
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{},
+	})
+
+	client, err := minio.New(opts.endpoint, &minio.Options{
+		Creds:     creds,
+		Secure:    !opts.insecure,
+		Region:    opts.region,
+		Transport: s3Transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint %s: %v", opts.endpoint, err)
+	}
+
+	return &s3Store{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+func (s *s3Store) Location(name string) string {
+	return "s3://" + s.bucket + "/" + s.prefix + name
+}
+
+// Create starts a streaming multipart upload; nothing is staged on disk
+func (s *s3Store) Create(name string) (backupWriter, error) {
+	reader, writer := io.Pipe()
+	w := &s3Writer{pipe: writer, done: make(chan error, 1)}
+
+	go func() {
+		_, err := s.client.PutObject(context.Background(), s.bucket, s.prefix+name, reader, -1,
+			minio.PutObjectOptions{PartSize: s3PartSize, ContentType: "application/octet-stream"})
+		// Record the result before unblocking the dump, so Abort can tell
+		// an upload failure from a dump failure
+		w.done <- err
+		reader.CloseWithError(err)
+	}()
+
+	return w, nil
+}
+
+func (s *s3Store) Open(name string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(context.Background(), s.bucket, s.prefix+name, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", s.Location(name), err)
+	}
+	return object, nil
+}
+
+func (s *s3Store) List() ([]storedBackup, error) {
+	var backups []storedBackup
+	for object := range s.client.ListObjects(context.Background(), s.bucket, minio.ListObjectsOptions{Prefix: s.prefix}) {
+		if object.Err != nil {
+			return nil, fmt.Errorf("failed to list %s: %v", s.Location(""), object.Err)
+		}
+
+		name := strings.TrimPrefix(object.Key, s.prefix)
+		if name == "" || strings.HasSuffix(name, "/") {
+			continue
+		}
+		backups = append(backups, storedBackup{Name: name, Size: object.Size, ModTime: object.LastModified})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name < backups[j].Name })
+	return backups, nil
+}
+
+func (s *s3Store) Remove(name string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, s.prefix+name, minio.RemoveObjectOptions{})
+}
+
+// s3Writer feeds an in-flight PutObject through a pipe
+type s3Writer struct {
+	pipe *io.PipeWriter
+	done chan error
+}
+
+func (w *s3Writer) Write(p []byte) (int, error) {
+	return w.pipe.Write(p)
+}
+
+// Commit ends the stream and waits for the upload to complete
+func (w *s3Writer) Commit() error {
+	w.pipe.Close()
+	if err := <-w.done; err != nil {
+		return fmt.Errorf("upload failed: %v", err)
+	}
+	return nil
+}
+
+// Abort fails the stream so the multipart upload is abandoned
+func (w *s3Writer) Abort() error {
+	select {
+	case err := <-w.done:
+		// The upload ended on its own and broke the stream
+		if err != nil {
+			return fmt.Errorf("upload failed: %v", err)
+		}
+		return nil
+	default:
+	}
+
+	w.pipe.CloseWithError(fmt.Errorf("backup aborted"))
+	<-w.done
+	return nil
+}
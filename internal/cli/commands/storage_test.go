@@ -0,0 +1,305 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+)
+
+func TestOpenStoreLocal(t *testing.T) {
+	dir := t.TempDir()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tests := []struct {
+		location string
+		dir      string
+		name     string
+	}{
+		{"", ".", ""},
+		{dir, dir, ""},
+		{dir + "/", dir + "/", ""},
+		{filepath.Join(dir, "orders.sql.gz"), dir, "orders.sql.gz"},
+		{"backups/new/", "backups/new/", ""},
+		{"~/backups/", filepath.Join(home, "backups"), ""},
+		{"~/orders.sql.gz", home, "orders.sql.gz"},
+	}
+
+	for _, tt := range tests {
+		store, name, err := openStore(tt.location, storageOptions{})
+		if err != nil {
+			t.Fatalf("openStore(%q) error = %v", tt.location, err)
+		}
+		local, ok := store.(localStore)
+		if !ok || local.dir != tt.dir || name != tt.name {
+			t.Errorf("openStore(%q) = %#v, %q; want dir %q, name %q", tt.location, store, name, tt.dir, tt.name)
+		}
+	}
+}
+
+func TestOpenStoreS3(t *testing.T) {
+	tests := []struct {
+		location string
+		prefix   string
+		name     string
+	}{
+		{"s3://backups", "", ""},
+		{"s3://backups/", "", ""},
+		{"s3://backups/orders/", "orders/", ""},
+		{"s3://backups/orders/daily/mydb.sql.gz", "orders/daily/", "mydb.sql.gz"},
+	}
+
+	for _, tt := range tests {
+		store, name, err := openStore(tt.location, storageOptions{endpoint: "minio.internal:9000"})
+		if err != nil {
+			t.Fatalf("openStore(%q) error = %v", tt.location, err)
+		}
+		s3, ok := store.(*s3Store)
+		if !ok || s3.bucket != "backups" || s3.prefix != tt.prefix || name != tt.name {
+			t.Errorf("openStore(%q) = %#v, %q; want prefix %q, name %q", tt.location, store, name, tt.prefix, tt.name)
+		}
+	}
+
+	if _, _, err := openStore("s3:///orders/", storageOptions{endpoint: "minio.internal:9000"}); err == nil {
+		t.Error("Expected error for missing bucket")
+	}
+}
+
+func TestIsBackupName(t *testing.T) {
+	tests := map[string]bool{
+		"orders_20240101_120000.sql":                     true,
+		"orders_20240101_120000.sql.gz.age":              true,
+		"orders_20240101_120000.sql.gz" + manifestSuffix: false,
+		"orders_20240101_120000.dump":                    true,
+		"orders_20240101_120000.dir.tar.age":             true,
+		"orders_20240101_120000.dir":                     true,
+		"notes.txt":                                      false,
+	}
+
+	for name, want := range tests {
+		if got := isBackupName(name); got != want {
+			t.Errorf("isBackupName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestLocalStore(t *testing.T) {
+	store := localStore{dir: t.TempDir()}
+
+	w, err := store.Create("b.sql")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	io.WriteString(w, "SELECT 1;")
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	os.WriteFile(filepath.Join(store.dir, "a.sql"), nil, 0600)
+	os.Mkdir(filepath.Join(store.dir, "nested"), 0700)
+
+	files, err := store.List()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(files) != 2 || files[0].Name != "a.sql" || files[1].Name != "b.sql" || files[1].Size != 9 {
+		t.Errorf("Expected a.sql and b.sql (9 bytes), got %+v", files)
+	}
+
+	if err := store.Remove("a.sql"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if files, _ := store.List(); len(files) != 1 {
+		t.Errorf("Expected one file after remove, got %+v", files)
+	}
+}
+
+func TestLocalStoreWritesAtomically(t *testing.T) {
+	store := localStore{dir: t.TempDir()}
+	final := filepath.Join(store.dir, "orders.sql")
+
+	w, _ := store.Create("orders.sql")
+	io.WriteString(w, "SELECT 1;")
+	if _, err := os.Stat(final); !os.IsNotExist(err) {
+		t.Errorf("Expected no %s before Commit, got %v", final, err)
+	}
+	if files, _ := store.List(); len(files) != 0 {
+		t.Errorf("Expected the partial file to be hidden from List, got %+v", files)
+	}
+	if err := w.Commit(); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if data, _ := os.ReadFile(final); string(data) != "SELECT 1;" {
+		t.Errorf("Expected the committed file, got %q", data)
+	}
+
+	w, _ = store.Create("broken.sql")
+	io.WriteString(w, "SELECT")
+	w.Abort()
+
+	entries, _ := os.ReadDir(store.dir)
+	if len(entries) != 1 || entries[0].Name() != "orders.sql" {
+		t.Errorf("Expected only orders.sql after Abort, got %v", entries)
+	}
+}
+
+func TestLocalStoreDirectoryDumps(t *testing.T) {
+	store := localStore{dir: t.TempDir()}
+	dump := filepath.Join(store.dir, "orders_20240101_120000.dir")
+	os.Mkdir(dump, 0700)
+	os.WriteFile(filepath.Join(dump, "toc.dat"), []byte("toc"), 0600)
+	os.WriteFile(filepath.Join(dump, "3001.dat.gz"), []byte("rows"), 0600)
+
+	files, err := store.List()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(files) != 1 || files[0].Size != 7 {
+		t.Fatalf("Expected the dump directory with 7 bytes, got %+v", files)
+	}
+
+	if err := store.Remove(files[0].Name); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if _, err := os.Stat(dump); !os.IsNotExist(err) {
+		t.Errorf("Expected the dump directory to be removed, got %v", err)
+	}
+}
+
+// fakeS3 starts an in-memory S3 server with bucket and returns options
+// pointing at it
+func fakeS3(t *testing.T, bucket string) storageOptions {
+	t.Helper()
+
+	// TLS keeps the client from using aws-chunked part uploads, which the
+	// fake server does not decode
+	server := httptest.NewTLSServer(gofakes3.New(s3mem.New()).Server())
+	t.Cleanup(server.Close)
+
+	original := s3Transport
+	s3Transport = server.Client().Transport
+	t.Cleanup(func() { s3Transport = original })
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test-secret")
+
+	opts := storageOptions{endpoint: strings.TrimPrefix(server.URL, "https://"), region: "us-east-1"}
+	store, err := newS3Store(opts, bucket, "")
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if err := store.client.MakeBucket(context.Background(), bucket, minio.MakeBucketOptions{}); err != nil {
+		t.Fatalf("Failed to create bucket: %v", err)
+	}
+	return opts
+}
+
+func TestS3StoreStreamsMultipartUpload(t *testing.T) {
+	opts := fakeS3(t, "backups")
+	if _, err := exec.LookPath("head"); err != nil {
+		t.Skip("head is not available")
+	}
+
+	original := s3PartSize
+	s3PartSize = 5 << 20
+	t.Cleanup(func() { s3PartSize = original })
+
+	store, name, err := openStore("s3://backups/orders/", opts)
+	if err != nil || name != "" {
+		t.Fatalf("Expected prefix store, got %q (%v)", name, err)
+	}
+
+	// Twelve megabytes of dump output spans three parts
+	stats, err := streamDump("pg_dump", commandDump(exec.Command("head", "-c", "12582912", "/dev/zero")), store, "orders.sql", compressionOptions{codec: "none"}, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := writeManifest(store, "orders.sql", &backupManifest{Format: manifestFormat, SHA256: stats.SHA256}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	files, err := store.List()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(files) != 2 || files[0].Name != "orders.sql" || files[0].Size != 12<<20 {
+		t.Errorf("Expected orders.sql (12 MiB) and its manifest, got %+v", files)
+	}
+
+	s3 := store.(*s3Store)
+	object, err := s3.client.GetObject(context.Background(), "backups", "orders/orders.sql", minio.GetObjectOptions{})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	data, _ := io.ReadAll(object)
+	if len(data) != 12<<20 || !bytes.Equal(data, make([]byte, 12<<20)) {
+		t.Errorf("Expected uploaded dump to match, got %d bytes", len(data))
+	}
+
+	if store.Location("orders.sql") != "s3://backups/orders/orders.sql" {
+		t.Errorf("Unexpected location %s", store.Location("orders.sql"))
+	}
+
+	if err := store.Remove("orders.sql"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestS3StoreOpen(t *testing.T) {
+	opts := fakeS3(t, "backups")
+	fakeDocker(t)
+
+	store, _, err := openStore("s3://backups/orders/", opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := streamDump("pg_dump", commandDump(exec.Command(dockerBinary, "SELECT 1;")), store, "orders.sql.zst", compressionOptions{codec: "zstd"}, nil, nil); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if got := storedCompression(store, "orders.sql.zst"); got != "zstd" {
+		t.Errorf("Expected zstd from the object's magic bytes, got %s", got)
+	}
+}
+
+func TestS3StoreAbortLeavesNoObject(t *testing.T) {
+	opts := fakeS3(t, "backups")
+	fakeDocker(t)
+
+	store, _, err := openStore("s3://backups/", opts)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if _, err := streamDump("pg_dump", commandDump(exec.Command(dockerBinary, "partial", "fail")), store, "broken.sql", compressionOptions{codec: "none"}, nil, nil); err == nil {
+		t.Fatal("Expected failing dump to return an error")
+	}
+
+	files, err := store.List()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(files) != 0 {
+		t.Errorf("Expected no object after a failed dump, got %+v", files)
+	}
+}
+
+func TestS3StoreUploadError(t *testing.T) {
+	opts := fakeS3(t, "backups")
+	fakeDocker(t)
+
+	store, _, _ := openStore("s3://missing-bucket/", opts)
+	_, err := streamDump("pg_dump", commandDump(exec.Command(dockerBinary, "SELECT 1;")), store, "x.sql", compressionOptions{codec: "none"}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "upload failed") {
+		t.Errorf("Expected upload error, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Profile is a named set of connection settings for the db commands.
+// Passwords are never stored; a profile may only point at a password
+// file or environment variable.
+type Profile struct {
+	Type         string `yaml:"type,omitempty"`
+	Host         string `yaml:"host,omitempty"`
+	Port         int    `yaml:"port,omitempty"`
+	Username     string `yaml:"username,omitempty"`
+	PasswordFile string `yaml:"password_file,omitempty"`
+	PasswordEnv  string `yaml:"password_env,omitempty"`
+	Database     string `yaml:"database,omitempty"`
+	// Path only applies to SQLite, AuthDatabase and ReplicaSet to MongoDB
+	Path         string `yaml:"path,omitempty"`
+	AuthDatabase string `yaml:"auth_database,omitempty"`
+	ReplicaSet   string `yaml:"replica_set,omitempty"`
+	SSHJump      string `yaml:"ssh_jump,omitempty"`
+	// ClientImage overrides the client image picked for the server version
+	ClientImage string `yaml:"client_image,omitempty"`
+	// Container is a running container the client tools are exec'd in;
+	// DockerNetwork is the network client containers join
+	Container     string `yaml:"container,omitempty"`
+	DockerNetwork string `yaml:"docker_network,omitempty"`
+	// EncryptRecipient is the age public key or recipients file backups
+	// are encrypted to; Identity is the matching key file for restores
+	EncryptRecipient string `yaml:"encrypt_recipient,omitempty"`
+	Identity         string `yaml:"identity,omitempty"`
+	// Output is the default backup destination, a directory or s3:// prefix
+	Output  string  `yaml:"output,omitempty"`
+	Storage Storage `yaml:"storage,omitempty"`
+}
+
+// Storage configures the S3-compatible endpoint used for s3:// outputs.
+// Credentials are never stored; they come from the standard AWS or MinIO
+// environment variables and credential files.
+type Storage struct {
+	Endpoint string `yaml:"endpoint,omitempty"`
+	Region   string `yaml:"region,omitempty"`
+	Insecure bool   `yaml:"insecure,omitempty"`
+}
+
+// Config is the content of the cutter config file
+type Config struct {
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+	// ClientImages maps an engine's server versions to the client image
+	// used for them, for example postgres: {"16": "registry.internal/postgres:16"};
+	// a "default" entry replaces the engine's default image
+	ClientImages map[string]map[string]string `yaml:"client_images,omitempty"`
+}
+
+// DefaultPath returns the config file location: $CUTTER_CONFIG if set,
+// otherwise $XDG_CONFIG_HOME/cutter/config.yaml, falling back to
+// ~/.config/cutter/config.yaml
+func DefaultPath() (string, error) {
+	if path := os.Getenv("CUTTER_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "cutter", "config.yaml"), nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate home directory: %v", err)
+	}
+	return filepath.Join(home, ".config", "cutter", "config.yaml"), nil
+}
+
+// Load reads the config file at path. A missing file yields an empty config.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Config{Profiles: map[string]Profile{}}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]Profile{}
+	}
+	return &cfg, nil
+}
+
+// Save writes the config to path, readable only by the current user
+func (c *Config) Save(path string) error {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode config: %v", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	// Write to a temporary file first so a failed write never truncates
+	// the existing config
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+	return nil
+}
+
+// Profile returns the named profile
+func (c *Config) Profile(name string) (Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %q not found", name)
+	}
+	return p, nil
+}
+
+// ProfileNames returns the profile names in sorted order
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("CUTTER_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", "/tmp/xdg")
+
+	path, err := DefaultPath()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if path != "/tmp/xdg/cutter/config.yaml" {
+		t.Errorf("Expected XDG path, got %s", path)
+	}
+
+	t.Setenv("CUTTER_CONFIG", "/etc/cutter.yaml")
+	path, _ = DefaultPath()
+	if path != "/etc/cutter.yaml" {
+		t.Errorf("Expected CUTTER_CONFIG to win, got %s", path)
+	}
+
+	t.Setenv("CUTTER_CONFIG", "")
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "/home/tester")
+	path, _ = DefaultPath()
+	if path != "/home/tester/.config/cutter/config.yaml" {
+		t.Errorf("Expected ~/.config path, got %s", path)
+	}
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	cfg, err := Load(filepath.Join(t.TempDir(), "config.yaml"))
+	if err != nil {
+		t.Fatalf("Expected no error for missing file, got %v", err)
+	}
+	if len(cfg.Profiles) != 0 {
+		t.Errorf("Expected no profiles, got %d", len(cfg.Profiles))
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cutter", "config.yaml")
+
+	cfg := &Config{Profiles: map[string]Profile{
+		"prod-orders": {
+			Type:     "postgres",
+			Host:     "10.0.1.50",
+			Port:     5432,
+			Username: "orders",
+			Database: "orders",
+			SSHJump:  "corp-bastion,vpc-bastion",
+		},
+		"local-shop": {
+			Type:          "postgres",
+			Host:          "db",
+			Database:      "shop",
+			DockerNetwork: "shop_default",
+		},
+	}, ClientImages: map[string]map[string]string{
+		"postgres": {"default": "registry.local/postgres:15", "16": "registry.local/postgres:16"},
+	}}
+
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Config file not written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	got, err := loaded.Profile("prod-orders")
+	if err != nil {
+		t.Fatalf("Expected profile, got %v", err)
+	}
+	if got != cfg.Profiles["prod-orders"] {
+		t.Errorf("Expected %+v, got %+v", cfg.Profiles["prod-orders"], got)
+	}
+	if got, _ := loaded.Profile("local-shop"); got != cfg.Profiles["local-shop"] {
+		t.Errorf("Expected %+v, got %+v", cfg.Profiles["local-shop"], got)
+	}
+	if image := loaded.ClientImages["postgres"]["16"]; image != "registry.local/postgres:16" {
+		t.Errorf("Expected client image mapping to round-trip, got %q", image)
+	}
+}
+
+func TestLoadInvalidYAML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	os.WriteFile(path, []byte("profiles: [unterminated"), 0600)
+
+	if _, err := Load(path); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+}
+
+func TestProfileNotFound(t *testing.T) {
+	cfg := &Config{Profiles: map[string]Profile{}}
+
+	_, err := cfg.Profile("missing")
+	if err == nil || err.Error() != `profile "missing" not found` {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
+func TestProfileNamesSorted(t *testing.T) {
+	cfg := &Config{Profiles: map[string]Profile{"b": {}, "a": {}, "c": {}}}
+
+	names := cfg.ProfileNames()
+	if len(names) != 3 || names[0] != "a" || names[1] != "b" || names[2] != "c" {
+		t.Errorf("Expected sorted names, got %v", names)
+	}
+}